@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/cmd"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/distributed"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/ocm"
@@ -37,29 +39,60 @@ var rootCmd = &cobra.Command{
 	Use:   "ocm-api-load",
 	Short: "A set of load tests for OCM's clusters-service, based on vegeta.",
 	Long:  longHelp,
-	RunE:  run,
+	// The Elasticsearch flags are shared with the subcommands, like compare.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return configES()
+	},
+	RunE: run,
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 	//Flags with defaults
-	rootCmd.Flags().StringVar(&configFile, "config-file", "config.yaml", "config file")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config-file", "config.yaml", "config file")
 	rootCmd.Flags().String("ocm-token-url", "https://sso.redhat.com/auth/realms/redhat-external/protocol/openid-connect/token", "Token URL")
 	rootCmd.Flags().String("gateway-url", "https://api.integration.openshift.com", "Gateway url to perform the test against")
 	rootCmd.Flags().String("test-id", uuid.NewV4().String(), "Unique ID to identify the test run. UUID is recommended")
 	rootCmd.Flags().String("output-path", "results", "Output directory for result and report files")
+	rootCmd.Flags().String("resume", "", "Test ID of an interrupted run to resume, skipping the tests it completed.")
 	rootCmd.Flags().Int("duration", 1, "Duration of each individual run in minutes.")
 	rootCmd.Flags().String("rate", "1/s", "Rate of the attack. Format example 5/s. (Available units 'ns', 'us', 'ms', 's', 'm', 'h')")
 	rootCmd.Flags().BoolP("verbose", "v", false, "set this flag to activate verbose logging.")
 	rootCmd.Flags().Int("cooldown", 10, "Cooldown time between tests in seconds.")
 	rootCmd.Flags().StringSlice("test-names", []string{}, "Names for the tests to be run.")
 	rootCmd.Flags().String("log-file", "", "Log file for output.")
+	//Result capture Flags
+	rootCmd.Flags().String("body-capture", "all", "Response bodies to keep in the results. (all, none, errors, truncate, sample)")
+	rootCmd.Flags().Int("body-max-bytes", 0, "Maximum size in bytes of every response body kept in the results. (0 means no limit)")
+	rootCmd.Flags().Int("body-sample-percent", 10, "Percentage of response bodies kept when body-capture is 'sample'.")
+	rootCmd.Flags().StringSlice("header-allow-list", []string{}, "Response headers kept in the results. Empty list keeps all.")
+	//Attacker tuning Flags
+	rootCmd.Flags().Int("workers", 10, "Initial number of workers of each attack.")
+	rootCmd.Flags().Int("max-workers", 0, "Maximum number of workers of each attack, so a slow server shows up as latency. (0 means no limit)")
+	rootCmd.Flags().Duration("timeout", 0, "Timeout of every request. (0 means no timeout)")
+	rootCmd.Flags().Int("redirects", 10, "Maximum number of redirects followed. (-1 means none)")
+	rootCmd.Flags().String("throttle-policy", "continue", "What tests do when the gateway throttles them. (continue, backoff)")
+	rootCmd.Flags().Duration("throttle-backoff", tests.DefaultThrottleBackoff, "Wait after a throttled request when the gateway does not say how long to wait.")
+	rootCmd.Flags().Bool("keep-alive", true, "Reuse TCP connections across requests.")
+	rootCmd.Flags().Bool("http2", true, "Use HTTP/2 when the server supports it.")
+	rootCmd.Flags().String("local-address", "", "Local IP address the requests are sent from.")
+	rootCmd.Flags().String("http-proxy", "", "HTTP proxy of the OCM, token and Elasticsearch requests. (defaults to the HTTP_PROXY and HTTPS_PROXY environment variables)")
+	rootCmd.Flags().StringSlice("no-proxy", []string{}, "Hosts, domains and CIDR ranges reached without the proxy.")
+	//Soak run Flags
+	rootCmd.Flags().String("soak", "", "Run the tests in a loop until this deadline, a duration such as 48h or an RFC 3339 time. Restarting with the same test-id resumes the run.")
+	rootCmd.Flags().Duration("soak-summary-interval", tests.DefaultSoakSummaryInterval, "Time between two interim summaries of a soak run.")
+	//Distributed run Flags
+	rootCmd.Flags().StringSlice("agents", []string{}, "Agents the run is split across, as host:port. The run is then executed by them instead of locally.")
+	rootCmd.Flags().String("agent-token", "", "Token sent to the agents, their --token")
+	rootCmd.Flags().Duration("agent-start-delay", distributed.DefaultStartDelay, "Time given to the agents to receive the start request before starting the run.")
 	//Elasticsearch Flags
-	rootCmd.Flags().String("elastic-server", "", "Elasticsearch cluster URL")
-	rootCmd.Flags().String("elastic-user", "", "Elasticsearch User for authentication")
-	rootCmd.Flags().Bool("elastic-insecure-skip-verify", false, "Elasticsearch skip tls verifcation during authentication")
-	rootCmd.Flags().String("elastic-password", "", "Elasticsearch Password for authentication")
-	rootCmd.Flags().String("elastic-index", "", "Elasticsearch index to store the documents")
+	rootCmd.PersistentFlags().String("elastic-server", "", "Elasticsearch cluster URL")
+	rootCmd.PersistentFlags().String("elastic-user", "", "Elasticsearch User for authentication")
+	rootCmd.PersistentFlags().Bool("elastic-insecure-skip-verify", false, "Elasticsearch skip tls verifcation during authentication")
+	rootCmd.PersistentFlags().String("elastic-password", "", "Elasticsearch Password for authentication")
+	rootCmd.PersistentFlags().String("elastic-index", "", "Elasticsearch index to store the documents")
+	rootCmd.Flags().Bool("baseline-lookup", false, "Compare the run with the latest passing run in Elasticsearch with the same tests and rates")
+	rootCmd.Flags().Bool("fail-on-regression", false, "Exit with an error when the baseline comparison finds a regression")
 	//Ramping Flags
 	rootCmd.Flags().String("ramp-type", "", "Type of ramp to use for all tests. (linear, exponential)")
 	rootCmd.Flags().Int("start-rate", 0, "Starting request per second rate. (E.g.: 5 would be 5 req/s)")
@@ -76,6 +109,8 @@ func init() {
 	rootCmd.Flags().String("aws-access-secret", "", "AWS access secret")
 	rootCmd.Flags().String("aws-account-id", "", "AWS Account ID, is the 12-digit account number.")
 	rootCmd.AddCommand(cmd.NewVersionCommand())
+	rootCmd.AddCommand(cmd.NewCompareCommand())
+	rootCmd.AddCommand(cmd.NewAgentCommand())
 }
 
 func initConfig() {
@@ -84,6 +119,7 @@ func initConfig() {
 		viper.AddConfigPath(".")
 	}
 	viper.SetConfigFile(configFile)
+	viper.BindPFlags(rootCmd.PersistentFlags())
 	viper.BindPFlags(rootCmd.Flags())
 
 	viper.AutomaticEnv()
@@ -185,11 +221,6 @@ func run(cmd *cobra.Command, args []string) error {
 	}
 	logger.Info(cmd.Context(), "Using output directory: %s", viper.GetString("output-path"))
 
-	connections, err := ocm.BuildConnections(cmd.Context(), logger)
-	if err != nil {
-		return err
-	}
-
 	configTests()
 
 	err = configAWS()
@@ -197,19 +228,54 @@ func run(cmd *cobra.Command, args []string) error {
 		logger.Fatal(cmd.Context(), "Configuring AWS: %s", err)
 	}
 
-	err = configES()
+	if len(viper.GetStringSlice("agents")) > 0 {
+		if viper.GetString("soak") != "" {
+			logger.Fatal(cmd.Context(), "soak runs cannot be distributed across agents")
+		}
+		if viper.GetString("resume") != "" {
+			logger.Fatal(cmd.Context(), "distributed runs cannot be resumed")
+		}
+		runDistributed(cmd, logger)
+		logger.DeferClose()
+		return nil
+	}
+
+	clients, err := ocm.BuildConnections(cmd.Context(), logger)
 	if err != nil {
-		logger.Fatal(cmd.Context(), "Configuring ES: %s", err)
+		return err
 	}
 
+	testID := viper.GetString("test-id")
+	resume := viper.GetString("resume")
+	if resume != "" {
+		if viper.IsSet("test-id") && testID != resume {
+			logger.Warn(cmd.Context(), "Resuming run %s, test-id %s is ignored", resume, testID)
+		}
+		testID = resume
+	}
 	runner := tests.NewRunner(
-		viper.GetString("test-id"),
+		testID,
 		viper.GetString("output-path"),
 		logger,
-		connections,
+		clients,
 	)
+	if resume != "" {
+		runner.Resume()
+	}
 
-	if err := runner.Run(cmd.Context()); err != nil {
+	if soak := viper.GetString("soak"); soak != "" {
+		deadline, err := tests.ParseSoakDeadline(soak, time.Now())
+		if err != nil {
+			logger.Fatal(cmd.Context(), "%v", err)
+		}
+		conf := tests.SoakConfig{
+			Deadline:        deadline,
+			SummaryInterval: viper.GetDuration("soak-summary-interval"),
+		}
+		if err := runner.RunSoak(cmd.Context(), conf); err != nil {
+			logger.Fatal(cmd.Context(), "running soak test: %v", err)
+		}
+	} else if err := runner.Run(cmd.Context()); err != nil {
 		logger.Fatal(cmd.Context(), "running load test: %v", err)
 	}
 
@@ -218,6 +284,35 @@ func run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// runDistributed splits the run across the agents and reports on the results
+// collected from them, like a local run.
+func runDistributed(cmd *cobra.Command, logger logging.Logger) {
+	testID := viper.GetString("test-id")
+	outputPath := viper.GetString("output-path")
+	controller := distributed.NewController(
+		viper.GetStringSlice("agents"),
+		viper.GetString("agent-token"),
+		viper.GetDuration("agent-start-delay"),
+		logger,
+	)
+	settings := viper.AllSettings()
+	// AllSettings leaves out the tests without options
+	settings["tests"] = viper.Get("tests")
+	profiles, version, runErr := controller.Run(cmd.Context(), testID, outputPath, settings)
+	if runErr != nil {
+		logger.Error(cmd.Context(), "running distributed load test: %v", runErr)
+	}
+
+	runner := tests.NewRunner(testID, outputPath, logger, nil)
+	runner.Collected(profiles, version)
+	if err := runner.Report(cmd.Context()); err != nil {
+		logger.Fatal(cmd.Context(), "running load test: %v", err)
+	}
+	if runErr != nil {
+		logger.Fatal(cmd.Context(), "running distributed load test: %v", runErr)
+	}
+}
+
 func main() {
 	ctx := context.Background()
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
@@ -11,6 +11,7 @@ require (
 	github.com/spf13/viper v1.12.0
 	github.com/tsenart/vegeta/v12 v12.8.4
 	github.com/zgalor/weberr v0.7.0
+	golang.org/x/net v0.0.0-20220520000938-2e3eb7b945c2
 )
 
 require github.com/pelletier/go-toml/v2 v2.0.1 // indirect
@@ -48,12 +49,11 @@ require (
 	github.com/prometheus/procfs v0.7.3 // indirect
 	github.com/sirupsen/logrus v1.6.0 // indirect
 	github.com/spf13/afero v1.8.2 // indirect
-	github.com/spf13/cast v1.5.0 // indirect
+	github.com/spf13/cast v1.5.0
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/subosito/gotenv v1.3.0 // indirect
 	golang.org/x/exp v0.0.0-20220722155223-a9213eeb770e // indirect
-	golang.org/x/net v0.0.0-20220520000938-2e3eb7b945c2 // indirect
 	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
 	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/protobuf v1.28.0 // indirect
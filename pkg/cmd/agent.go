@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/distributed"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+const agentLongHelp = `
+	Runs an agent of a distributed run, which executes the share of the load
+	assigned by the controller, started with the --agents of the run.
+
+	ocm-load-test agent --listen :8090 --token $AGENT_TOKEN --output-path agent-results
+
+	The agent applies the config sent by the controller, credentials included,
+	over cleartext HTTP. It listens on localhost unless --listen is set, which
+	requires a --token, the --agent-token of the controller, and a trusted
+	network.
+`
+
+var agentCmd = &cobra.Command{
+	Use:          "agent",
+	Short:        "Executes the load assigned by a controller",
+	Long:         agentLongHelp,
+	SilenceUsage: true,
+	RunE:         runAgent,
+}
+
+func init() {
+	agentCmd.Flags().String("listen", "localhost:8090", "Address the agent listens on for the controller. Addresses other than loopback ones require a --token")
+	agentCmd.Flags().String("output-path", "agent-results", "Directory where the result files of each run are written, under the test ID")
+	agentCmd.Flags().String("token", "", "Token the controller must send, its --agent-token")
+	agentCmd.Flags().BoolP("verbose", "v", false, "set this flag to activate verbose logging.")
+}
+
+func NewAgentCommand() *cobra.Command {
+	return agentCmd
+}
+
+func runAgent(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	logger, err := logging.NewGoLoggerBuilder().Debug(verbose).Build()
+	if err != nil {
+		return fmt.Errorf("can't build logger: %v", err)
+	}
+	listen, _ := cmd.Flags().GetString("listen")
+	outputPath, _ := cmd.Flags().GetString("output-path")
+	token, _ := cmd.Flags().GetString("token")
+	if token == "" && !isLoopback(listen) {
+		return fmt.Errorf("--token is required to listen on %s, outside of loopback", listen)
+	}
+
+	err = helpers.CreateFolder(ctx, outputPath, logger)
+	if err != nil {
+		return err
+	}
+	agent := distributed.NewAgent(ctx, outputPath, token, distributed.PrepareRunner, logger)
+	server := &http.Server{Addr: listen, Handler: agent}
+	go func(ctx context.Context) {
+		<-ctx.Done()
+		server.Close()
+	}(ctx)
+	logger.Info(ctx, "Agent listening on %s", listen)
+	err = server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// isLoopback reports whether the listen address only accepts connections from
+// the host itself.
+func isLoopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
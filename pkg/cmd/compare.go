@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/elastic"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const compareLongHelp = `
+	Compares the results of two runs and flags regressions.
+
+	Each run can be given as a directory holding its result files, as a test ID
+	whose result files are in --output-path, or as a test ID indexed in the
+	configured Elasticsearch index.
+
+	ocm-load-test compare --baseline <test-id|dir> --candidate <test-id|dir>
+
+	The command exits with a non-zero code when a regression is found.
+`
+
+var compareCmd = &cobra.Command{
+	Use:          "compare",
+	Short:        "Compares two runs and detects regressions",
+	Long:         compareLongHelp,
+	SilenceUsage: true,
+	RunE:         runCompare,
+}
+
+func init() {
+	compareCmd.Flags().String("baseline", "", "Test ID or directory of the baseline run")
+	compareCmd.Flags().String("candidate", "", "Test ID or directory of the candidate run")
+	compareCmd.Flags().String("output-path", "results", "Directory where the result files of the test IDs are looked up")
+	compareCmd.Flags().String("report-file", "", "File to write the comparison report to, besides the standard output")
+	compareCmd.Flags().Float64("latency-tolerance", 10, "Allowed increase of latency percentiles, in percent")
+	compareCmd.Flags().Float64("throughput-tolerance", 10, "Allowed decrease of throughput, in percent")
+	compareCmd.Flags().Float64("error-ratio-tolerance", 1, "Allowed increase of the error ratio, in percentage points")
+	compareCmd.MarkFlagRequired("baseline")
+	compareCmd.MarkFlagRequired("candidate")
+	viper.BindPFlag("compare.latency-tolerance", compareCmd.Flags().Lookup("latency-tolerance"))
+	viper.BindPFlag("compare.throughput-tolerance", compareCmd.Flags().Lookup("throughput-tolerance"))
+	viper.BindPFlag("compare.error-ratio-tolerance", compareCmd.Flags().Lookup("error-ratio-tolerance"))
+}
+
+func NewCompareCommand() *cobra.Command {
+	return compareCmd
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	logger, err := logging.NewGoLoggerBuilder().Build()
+	if err != nil {
+		return fmt.Errorf("can't build logger: %v", err)
+	}
+	outputPath, _ := cmd.Flags().GetString("output-path")
+	baselineSource, _ := cmd.Flags().GetString("baseline")
+	candidateSource, _ := cmd.Flags().GetString("candidate")
+
+	baseline, err := LoadSummaries(ctx, baselineSource, outputPath, logger)
+	if err != nil {
+		return fmt.Errorf("loading baseline %s: %v", baselineSource, err)
+	}
+	candidate, err := LoadSummaries(ctx, candidateSource, outputPath, logger)
+	if err != nil {
+		return fmt.Errorf("loading candidate %s: %v", candidateSource, err)
+	}
+
+	comparison := results.Compare(baseline, candidate, results.ConfiguredTolerances())
+	err = comparison.Write(os.Stdout)
+	if err != nil {
+		return err
+	}
+	reportFile, _ := cmd.Flags().GetString("report-file")
+	if reportFile != "" {
+		f, err := os.Create(reportFile)
+		if err != nil {
+			return fmt.Errorf("writing report: %v", err)
+		}
+		defer f.Close()
+		err = comparison.Write(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	if comparison.Regressed() {
+		return fmt.Errorf("regressions detected between %s and %s", baselineSource, candidateSource)
+	}
+	return nil
+}
+
+// LoadSummaries summarizes a run from a directory, from the result files of a
+// test ID in outputPath or from the documents of a test ID in Elasticsearch.
+func LoadSummaries(ctx context.Context, source, outputPath string, logger logging.Logger) (map[string]*results.Summary, error) {
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		logger.Info(ctx, "Loading results from directory %s", source)
+		return results.LoadDir(source, "")
+	}
+	files, err := results.ResultFiles(outputPath, source)
+	if err == nil && len(files) > 0 {
+		logger.Info(ctx, "Loading results for test ID %s from %s", source, outputPath)
+		return results.LoadDir(outputPath, source)
+	}
+	if viper.GetString("elastic.server") == "" {
+		return nil, fmt.Errorf("no result files found in %s and no elastic server configured", outputPath)
+	}
+	collector := results.NewCollector()
+	err = elastic.SearchResults(ctx, source, logger, func(res *vegeta.Result) {
+		collector.Add(res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return collector.Summaries(), nil
+}
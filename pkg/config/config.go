@@ -37,6 +37,20 @@ func (c *ConfigHelper) ResolveIntConfig(ctx context.Context, def int, key string
 	return i
 }
 
+func (c *ConfigHelper) ResolveStringSliceConfig(ctx context.Context, def []string, key string) []string {
+	s := c.conf.GetStringSlice(key)
+	if len(s) == 0 {
+		c.logger.Info(ctx, "no value for %s. Using default value.", key)
+		return def
+	}
+	return s
+}
+
+// IsSet tells if the key has a value in the config.
+func (c *ConfigHelper) IsSet(key string) bool {
+	return c.conf.IsSet(key)
+}
+
 func (c *ConfigHelper) ValidateRampConfig(ctx context.Context, min, max, steps int) bool {
 	if steps < 2 {
 		c.logger.Warn(ctx,
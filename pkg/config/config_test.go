@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
@@ -62,6 +63,34 @@ func TestConfigHelper_ResolveIntConfig(t *testing.T) {
 	}
 }
 
+func TestConfigHelper_ResolveStringSliceConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		def  []string
+		key  string
+		want []string
+	}{
+		{"no_key_use_def", []string{"Date"}, "testHeaders", []string{"Date"}},
+		{"no_key_nil_def", nil, "testHeaders", nil},
+		{"key_exists", nil, "test_headers", []string{"Content-Type"}},
+		{"nested_key_exists", []string{"Date"}, "MyTest.headers", []string{"X-Operation-Id", "Date"}},
+	}
+	conf := viper.New()
+	conf.Set("test_headers", []string{"Content-Type"})
+	conf.Set("MyTest", map[string]interface{}{})
+	conf.Set("MyTest.headers", []interface{}{"X-Operation-Id", "Date"})
+	logBuilder := logging.NewGoLoggerBuilder()
+	log, _ := logBuilder.Build()
+	c := NewConfigHelper(log, conf)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.ResolveStringSliceConfig(context.TODO(), tt.def, tt.key); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConfigHelper.ResolveStringSliceConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestConfigHelper_ValidateRampConfig(t *testing.T) {
 	tests := []struct {
 		name  string
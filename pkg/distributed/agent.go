@@ -0,0 +1,246 @@
+package distributed
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/ocm"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/tests"
+	"github.com/spf13/viper"
+)
+
+// Agent executes the share of the runs assigned by a controller. It holds a
+// single run at a time: a new assignment replaces a run not started yet, and
+// is refused while a run is being prepared or executed.
+type Agent struct {
+	ctx             context.Context
+	outputDirectory string
+	token           string
+	prepare         Prepare
+	logger          logging.Logger
+
+	mu     sync.Mutex
+	status Status
+	job    Job
+}
+
+// NewAgent returns an agent writing the results of each run to its own
+// directory of outputDirectory, `<output-directory>/<test-id>`. Runs are
+// bound to ctx. When token is set, the requests of the controller must carry
+// it as a bearer token.
+func NewAgent(ctx context.Context, outputDirectory, token string, prepare Prepare, logger logging.Logger) *Agent {
+	return &Agent{
+		ctx:             ctx,
+		outputDirectory: outputDirectory,
+		token:           token,
+		prepare:         prepare,
+		logger:          logger,
+	}
+}
+
+// PrepareRunner applies the settings of the assignment to the config and
+// builds a tests.Runner with the connections of the auths in the share of the
+// agent.
+func PrepareRunner(ctx context.Context, assignment Assignment, outputDirectory string, logger logging.Logger) (Job, error) {
+	for key, value := range assignment.Settings {
+		viper.Set(key, value)
+	}
+	share := ocm.Share{Agent: assignment.Agent, Agents: assignment.Agents}
+	clients, err := ocm.BuildAgentConnections(ctx, logger, share)
+	if err != nil {
+		return nil, err
+	}
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("no auths configured")
+	}
+	runner := tests.NewRunner(assignment.TestID, outputDirectory, logger, clients)
+	runner.Share(assignment.Agent, assignment.Agents)
+	return runner, nil
+}
+
+func (a *Agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.token != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(a.token)) != 1 {
+			http.Error(w, "invalid agent token", http.StatusUnauthorized)
+			return
+		}
+	}
+	if !strings.HasPrefix(r.URL.Path, runsPath) {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, runsPath), "/"), "/")
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 1 && parts[0] == "":
+		a.handlePrepare(w, r)
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "start":
+		a.handleStart(w, r, parts[0])
+	case r.Method == http.MethodGet && len(parts) == 1:
+		a.handleStatus(w, parts[0])
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[1] == "files":
+		a.handleFile(w, r, parts[0], parts[2])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *Agent) handlePrepare(w http.ResponseWriter, r *http.Request) {
+	assignment := Assignment{}
+	err := json.NewDecoder(r.Body).Decode(&assignment)
+	if err == nil && !validTestID(assignment.TestID) {
+		err = fmt.Errorf("test ID %q is not a valid directory name", assignment.TestID)
+	}
+	if err != nil || assignment.Agents < 1 {
+		http.Error(w, fmt.Sprintf("invalid assignment: %v", err), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	if a.status.State == StatePreparing || a.status.State == StateRunning {
+		a.mu.Unlock()
+		http.Error(w, fmt.Sprintf("run %s is %s", a.status.TestID, a.status.State), http.StatusConflict)
+		return
+	}
+	a.status = Status{TestID: assignment.TestID, State: StatePreparing}
+	a.job = nil
+	a.mu.Unlock()
+
+	a.logger.Info(a.ctx, "Preparing run %s as agent %d of %d", assignment.TestID, assignment.Agent+1, assignment.Agents)
+	dir := filepath.Join(a.outputDirectory, assignment.TestID)
+	err = helpers.CreateFolder(a.ctx, dir, a.logger)
+	if err == nil {
+		err = writeFiles(dir, assignment)
+	}
+	var job Job
+	if err == nil {
+		job, err = a.prepare(a.ctx, assignment, dir, a.logger)
+	}
+
+	a.mu.Lock()
+	if err != nil {
+		a.status.State = StateFailed
+		a.status.Error = err.Error()
+	} else {
+		a.status.State = StatePrepared
+		a.job = job
+	}
+	status := a.status
+	a.mu.Unlock()
+	if err != nil {
+		a.logger.Error(a.ctx, "preparing run %s: %v", assignment.TestID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, status)
+}
+
+// validTestID tells if the test ID of an assignment can name the directory of
+// the run, which has to stay inside the output directory.
+func validTestID(testID string) bool {
+	return testID != "" && testID != "." && !strings.Contains(testID, "..") && !strings.ContainsAny(testID, `/\`)
+}
+
+func (a *Agent) handleStart(w http.ResponseWriter, r *http.Request, testID string) {
+	request := startRequest{}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid start request: %v", err), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status.TestID != testID || a.status.State != StatePrepared {
+		http.Error(w, fmt.Sprintf("run %s is not prepared", testID), http.StatusConflict)
+		return
+	}
+	a.status.State = StateRunning
+	go a.execute(a.job, testID, request.StartAt)
+	writeJSON(w, a.status)
+}
+
+// execute waits for the start time shared by every agent and executes the
+// job.
+func (a *Agent) execute(job Job, testID string, startAt time.Time) {
+	wait := time.Until(startAt)
+	if wait < 0 {
+		a.logger.Warn(a.ctx, "Run %s started %s late, check the clocks are in sync", testID, -wait)
+	}
+	timer := time.NewTimer(wait)
+	var err error
+	select {
+	case <-timer.C:
+		a.logger.Info(a.ctx, "Starting run %s", testID)
+		err = job.Execute(a.ctx)
+	case <-a.ctx.Done():
+		timer.Stop()
+		err = a.ctx.Err()
+	}
+
+	dir := filepath.Join(a.outputDirectory, testID)
+	files, listErr := results.ResultFiles(dir, testID)
+	if err == nil {
+		err = listErr
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	profiles := job.Profiles()
+	version := job.ServerVersion(a.ctx)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status.State = StateDone
+	if err != nil {
+		a.status.State = StateFailed
+		a.status.Error = err.Error()
+		a.logger.Error(a.ctx, "running %s: %v", testID, err)
+	}
+	a.status.Files = names
+	a.status.Profiles = profiles
+	a.status.Version = version
+	a.logger.Info(a.ctx, "Run %s %s with %d result files", testID, a.status.State, len(names))
+}
+
+func (a *Agent) handleStatus(w http.ResponseWriter, testID string) {
+	a.mu.Lock()
+	status := a.status
+	a.mu.Unlock()
+	if status.TestID != testID {
+		http.Error(w, fmt.Sprintf("unknown run %s", testID), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, status)
+}
+
+func (a *Agent) handleFile(w http.ResponseWriter, r *http.Request, testID, name string) {
+	a.mu.Lock()
+	status := a.status
+	a.mu.Unlock()
+	if status.TestID != testID || !status.finished() {
+		http.Error(w, fmt.Sprintf("run %s is not finished", testID), http.StatusConflict)
+		return
+	}
+	for _, f := range status.Files {
+		if f == name {
+			http.ServeFile(w, r, filepath.Join(a.outputDirectory, testID, name))
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
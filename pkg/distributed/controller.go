@@ -0,0 +1,282 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+)
+
+const (
+	// DefaultStartDelay is the time given to the agents to receive the
+	// start request before starting, when `agent-start-delay` is not set.
+	DefaultStartDelay = 10 * time.Second
+
+	// DefaultPollInterval is the time between two polls of the status of the
+	// agents.
+	DefaultPollInterval = 5 * time.Second
+
+	// maxPollFailures is the number of polls in a row an agent can fail to
+	// answer before its run is considered failed.
+	maxPollFailures = 12
+)
+
+// Controller splits runs across agents, starts them in sync and collects
+// their result files.
+type Controller struct {
+	agents       []string
+	token        string
+	startDelay   time.Duration
+	pollInterval time.Duration
+	client       *http.Client
+	logger       logging.Logger
+}
+
+// NewController returns a controller of the agents, given as `host:port` or
+// as URLs. token is sent to them as a bearer token when set.
+func NewController(agents []string, token string, startDelay time.Duration, logger logging.Logger) *Controller {
+	urls := make([]string, 0, len(agents))
+	for _, agent := range agents {
+		if !strings.Contains(agent, "://") {
+			agent = "http://" + agent
+		}
+		urls = append(urls, strings.TrimSuffix(agent, "/"))
+	}
+	if startDelay <= 0 {
+		startDelay = DefaultStartDelay
+	}
+	return &Controller{
+		agents:       urls,
+		token:        token,
+		startDelay:   startDelay,
+		pollInterval: DefaultPollInterval,
+		client:       &http.Client{},
+		logger:       logger,
+	}
+}
+
+// Run prepares the run on every agent with the settings, starts them all at
+// the same time and, once they are over, downloads their result files to
+// outputDirectory. It returns the rate profiles of the tests executed and the
+// server version, to look up the baseline of the run. The files of the agents
+// that failed are downloaded too, before returning the error. The files named
+// by the settings, such as `tls.ca-file`, are sent to the agents along with
+// them.
+func (c *Controller) Run(ctx context.Context, testID, outputDirectory string, settings map[string]interface{}) (map[string]string, string, error) {
+	settings, _ = normalize(settings).(map[string]interface{})
+	files, err := readFiles(settings)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c.logger.Info(ctx, "Preparing run %s on %d agents", testID, len(c.agents))
+	err = c.each(func(i int, agent string) error {
+		assignment := Assignment{TestID: testID, Agent: i, Agents: len(c.agents), Settings: settings, Files: files}
+		return c.send(ctx, http.MethodPost, agent+runsPath, assignment, nil)
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("preparing agents: %v", err)
+	}
+
+	startAt := time.Now().Add(c.startDelay)
+	c.logger.Info(ctx, "Starting run %s on every agent at %s", testID, startAt.Format(time.RFC3339))
+	err = c.each(func(i int, agent string) error {
+		return c.send(ctx, http.MethodPost, fmt.Sprintf("%s%s/%s/start", agent, runsPath, url.PathEscape(testID)), startRequest{StartAt: startAt}, nil)
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("starting agents: %v", err)
+	}
+
+	statuses, err := c.wait(ctx, testID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	profiles := map[string]string{}
+	version := ""
+	failed := []string{}
+	for i, status := range statuses {
+		agent := c.agents[i]
+		if status.State == StateFailed {
+			failed = append(failed, fmt.Sprintf("%s: %s", agent, status.Error))
+		}
+		for testName, profile := range status.Profiles {
+			profiles[testName] = profile
+		}
+		if version == "" {
+			version = status.Version
+		}
+		for _, name := range status.Files {
+			err := c.download(ctx, fmt.Sprintf("%s%s/%s/files/%s", agent, runsPath, url.PathEscape(testID), url.PathEscape(name)), name, outputDirectory)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s: downloading %s: %v", agent, name, err))
+			}
+		}
+		c.logger.Info(ctx, "Collected %d result files from agent %s", len(status.Files), agent)
+	}
+	if len(failed) > 0 {
+		return profiles, version, fmt.Errorf("agents failed: %s", strings.Join(failed, "; "))
+	}
+	return profiles, version, nil
+}
+
+// wait polls the status of the agents until every one of them is over, or
+// failed to answer maxPollFailures polls in a row.
+func (c *Controller) wait(ctx context.Context, testID string) ([]Status, error) {
+	statuses := make([]Status, len(c.agents))
+	failures := make([]int, len(c.agents))
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		running := 0
+		for i, agent := range c.agents {
+			if statuses[i].finished() {
+				continue
+			}
+			err := c.send(ctx, http.MethodGet, fmt.Sprintf("%s%s/%s", agent, runsPath, url.PathEscape(testID)), nil, &statuses[i])
+			if err != nil {
+				c.logger.Warn(ctx, "polling agent %s: %v", agent, err)
+				failures[i]++
+				if failures[i] >= maxPollFailures {
+					statuses[i] = Status{TestID: testID, State: StateFailed, Error: fmt.Sprintf("not answering: %v", err)}
+				}
+			} else {
+				failures[i] = 0
+			}
+			if !statuses[i].finished() {
+				running++
+			}
+		}
+		if running == 0 {
+			return statuses, nil
+		}
+		c.logger.Debug(ctx, "%d agents still running %s", running, testID)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// each calls fn for every agent concurrently and returns their errors.
+func (c *Controller) each(fn func(i int, agent string) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(c.agents))
+	for i, agent := range c.agents {
+		wg.Add(1)
+		go func(i int, agent string) {
+			defer wg.Done()
+			errs[i] = fn(i, agent)
+		}(i, agent)
+	}
+	wg.Wait()
+	messages := []string{}
+	for i, err := range errs {
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("%s: %v", c.agents[i], err))
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("%s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// send sends body as JSON and decodes the JSON response into out, when set.
+func (c *Controller) send(ctx context.Context, method, target string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	response, err := c.do(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+func (c *Controller) download(ctx context.Context, target, name, outputDirectory string) error {
+	response, err := c.do(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	file, err := helpers.CreateFile(name, outputDirectory)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, response.Body)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// do sends a request to an agent, failing on error status codes.
+func (c *Controller) do(ctx context.Context, method, target string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		message, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		return nil, fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+	return response, nil
+}
+
+// normalize converts the maps read from YAML, which can have interface keys,
+// to maps that can be encoded as JSON.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = normalize(value)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalize(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = normalize(value)
+		}
+		return s
+	}
+	return v
+}
@@ -0,0 +1,158 @@
+package distributed
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// fakeJob writes one result per agent, sent when the job is executed.
+type fakeJob struct {
+	assignment      Assignment
+	outputDirectory string
+	started         chan time.Time
+}
+
+func (j *fakeJob) Execute(ctx context.Context) error {
+	j.started <- time.Now()
+	file, err := os.Create(filepath.Join(j.outputDirectory, fmt.Sprintf("%s_list-clusters_%d.json", j.assignment.TestID, j.assignment.Agent)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	identity := results.Identity{Connection: fmt.Sprintf("auth-%d", j.assignment.Agent), ConnectionIndex: j.assignment.Agent}
+	res := vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: time.Now(), Latency: time.Millisecond}
+	return results.NewLabeledEncoder(file, identity).Encode(&res)
+}
+
+func (j *fakeJob) Profiles() map[string]string {
+	return map[string]string{"list-clusters": "10/s"}
+}
+
+func (j *fakeJob) ServerVersion(ctx context.Context) string {
+	return "v1"
+}
+
+func TestController_Run(t *testing.T) {
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	started := make(chan time.Time, 2)
+	var mu sync.Mutex
+	assignments := []Assignment{}
+	prepare := func(ctx context.Context, assignment Assignment, outputDirectory string, logger logging.Logger) (Job, error) {
+		mu.Lock()
+		assignments = append(assignments, assignment)
+		mu.Unlock()
+		return &fakeJob{assignment: assignment, outputDirectory: outputDirectory, started: started}, nil
+	}
+
+	agents := []string{}
+	for i := 0; i < 2; i++ {
+		server := httptest.NewServer(NewAgent(ctx, t.TempDir(), "secret", prepare, logger))
+		defer server.Close()
+		agents = append(agents, server.Listener.Addr().String())
+	}
+	controller := NewController(agents, "secret", 200*time.Millisecond, logger)
+	controller.pollInterval = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("controller CA"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	settings := map[string]interface{}{
+		"aws": []interface{}{map[interface{}]interface{}{"region": "us-west-1"}},
+		"tls": map[string]interface{}{"ca-file": caFile},
+	}
+	profiles, version, err := controller.Run(ctx, "run", dir, settings)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if profiles["list-clusters"] != "10/s" || version != "v1" {
+		t.Errorf("Run() = %v, %q, want the profiles and version of the agents", profiles, version)
+	}
+
+	if len(assignments) != 2 || assignments[0].Agents != 2 || assignments[0].Agent == assignments[1].Agent {
+		t.Fatalf("assignments = %+v, want one per agent", assignments)
+	}
+	if _, ok := assignments[0].Settings["aws"].([]interface{})[0].(map[string]interface{}); !ok {
+		t.Errorf("settings = %v, want maps with string keys", assignments[0].Settings)
+	}
+	// The files of the settings are sent, not their controller paths.
+	for _, assignment := range assignments {
+		path, _ := lookup(assignment.Settings, "tls.ca-file").(string)
+		content, err := os.ReadFile(path)
+		if path == caFile || err != nil || string(content) != "controller CA" {
+			t.Errorf("tls.ca-file = %q (%q, %v), want a copy of the controller file on the agent", path, content, err)
+		}
+	}
+	first, second := <-started, <-started
+	if d := first.Sub(second); d > 50*time.Millisecond || d < -50*time.Millisecond {
+		t.Errorf("agents started %s apart", d)
+	}
+
+	summaries, err := results.LoadDirByIdentity(dir, "run")
+	if err != nil {
+		t.Fatalf("LoadDirByIdentity() error = %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Errorf("summaries = %v, want the results of both agents", summaries)
+	}
+}
+
+func TestAgent_ServeHTTP(t *testing.T) {
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	block := make(chan time.Time)
+	prepare := func(ctx context.Context, assignment Assignment, outputDirectory string, logger logging.Logger) (Job, error) {
+		return &fakeJob{assignment: assignment, outputDirectory: outputDirectory, started: block}, nil
+	}
+	server := httptest.NewServer(NewAgent(ctx, t.TempDir(), "secret", prepare, logger))
+	defer server.Close()
+
+	intruder := NewController([]string{server.URL}, "guess", time.Millisecond, logger)
+	err := intruder.send(ctx, http.MethodPost, server.URL+runsPath, Assignment{TestID: "run", Agents: 1}, nil)
+	if err == nil {
+		t.Errorf("an assignment with the wrong token should be refused")
+	}
+
+	controller := NewController([]string{server.URL}, "secret", time.Millisecond, logger)
+	for _, testID := range []string{"../escape", "nested/run", `nested\run`, ".."} {
+		err = controller.send(ctx, http.MethodPost, server.URL+runsPath, Assignment{TestID: testID, Agents: 1}, nil)
+		if err == nil {
+			t.Errorf("an assignment with test ID %q should be refused", testID)
+		}
+	}
+	err = controller.send(ctx, http.MethodPost, server.URL+runsPath, Assignment{TestID: "run", Agents: 1}, nil)
+	if err != nil {
+		t.Fatalf("preparing run: %v", err)
+	}
+	err = controller.send(ctx, http.MethodPost, server.URL+runsPath+"/run/start", startRequest{StartAt: time.Now()}, nil)
+	if err != nil {
+		t.Fatalf("starting run: %v", err)
+	}
+	err = controller.send(ctx, http.MethodPost, server.URL+runsPath, Assignment{TestID: "other", Agents: 1}, nil)
+	if err == nil {
+		t.Errorf("an assignment should be refused while a run is executed")
+	}
+	err = controller.download(ctx, server.URL+runsPath+"/run/files/run_list-clusters_0.json", "out.json", t.TempDir())
+	if err == nil {
+		t.Errorf("files should not be served before the run is over")
+	}
+	<-block
+	controller.pollInterval = 10 * time.Millisecond
+	statuses, err := controller.wait(ctx, "run")
+	if err != nil || statuses[0].State != StateDone || len(statuses[0].Files) != 1 {
+		t.Errorf("status = %+v, %v, want the run done with its result file", statuses, err)
+	}
+}
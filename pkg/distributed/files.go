@@ -0,0 +1,90 @@
+package distributed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// filesDirectory is the directory of the run where the agents write the files
+// of the settings.
+const filesDirectory = "files"
+
+// readFiles reads the files named by the fileSettings of settings, keyed by
+// setting.
+func readFiles(settings map[string]interface{}) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, key := range fileSettings {
+		path, _ := lookup(settings, key).(string)
+		if path == "" {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", key, err)
+		}
+		files[key] = content
+	}
+	return files, nil
+}
+
+// writeFiles writes the files of the assignment to the filesDirectory of dir,
+// readable by the agent only, and points their settings to them.
+func writeFiles(dir string, assignment Assignment) error {
+	if len(assignment.Files) == 0 {
+		return nil
+	}
+	if assignment.Settings == nil {
+		return fmt.Errorf("files sent without settings")
+	}
+	dir = filepath.Join(dir, filesDirectory)
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return err
+	}
+	for key, content := range assignment.Files {
+		if !isFileSetting(key) {
+			return fmt.Errorf("unexpected file for setting %s", key)
+		}
+		path := filepath.Join(dir, key)
+		err := os.WriteFile(path, content, 0o600)
+		if err != nil {
+			return err
+		}
+		set(assignment.Settings, key, path)
+	}
+	return nil
+}
+
+func isFileSetting(key string) bool {
+	for _, k := range fileSettings {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the value of a dotted key of settings, nil when not set.
+func lookup(settings map[string]interface{}, key string) interface{} {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		settings, _ = settings[part].(map[string]interface{})
+	}
+	return settings[parts[len(parts)-1]]
+}
+
+// set sets the value of a dotted key of settings, adding the missing maps.
+func set(settings map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := settings[part].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			settings[part] = next
+		}
+		settings = next
+	}
+	settings[parts[len(parts)-1]] = value
+}
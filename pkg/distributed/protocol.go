@@ -0,0 +1,76 @@
+package distributed
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+)
+
+// The agents serve their API under runsPath:
+//
+//	POST /runs                     prepares the Assignment of a run
+//	POST /runs/<test-id>/start     starts the prepared run at the time given
+//	GET  /runs/<test-id>           returns the Status of the run
+//	GET  /runs/<test-id>/files/<f> returns a result file of the finished run
+const runsPath = "/runs"
+
+// Assignment is the share of a run the controller sends to an agent.
+type Assignment struct {
+	TestID string `json:"test_id"`
+	// Agent is the index of the agent among the Agents of the run.
+	Agent  int `json:"agent"`
+	Agents int `json:"agents"`
+	// Settings is the whole config of the controller, applied by the agent.
+	Settings map[string]interface{} `json:"settings"`
+	// Files holds the contents of the files of the settings in fileSettings,
+	// which are paths on the controller. The agent writes them to its run
+	// directory and points the settings to them.
+	Files map[string][]byte `json:"files,omitempty"`
+}
+
+// fileSettings are the settings naming a file read by the agents.
+var fileSettings = []string{"ocm.auths-file", "tls.ca-file", "tls.cert-file", "tls.key-file"}
+
+type startRequest struct {
+	StartAt time.Time `json:"start_at"`
+}
+
+// State is the state of the run of an agent.
+type State string
+
+const (
+	StatePreparing State = "preparing"
+	StatePrepared  State = "prepared"
+	StateRunning   State = "running"
+	StateDone      State = "done"
+	StateFailed    State = "failed"
+)
+
+// Status is the state of the run of an agent. Files, Profiles and Version are
+// set once the run is over.
+type Status struct {
+	TestID string `json:"test_id"`
+	State  State  `json:"state"`
+	Error  string `json:"error,omitempty"`
+	// Files are the names of the result files written by the run.
+	Files []string `json:"files,omitempty"`
+	// Profiles holds the rate profile of each executed test.
+	Profiles map[string]string `json:"profiles,omitempty"`
+	Version  string            `json:"version,omitempty"`
+}
+
+func (s Status) finished() bool {
+	return s.State == StateDone || s.State == StateFailed
+}
+
+// Job is the share of a run executed by an agent, a tests.Runner.
+type Job interface {
+	Execute(ctx context.Context) error
+	Profiles() map[string]string
+	ServerVersion(ctx context.Context) string
+}
+
+// Prepare builds the job of an assignment, which writes its result files to
+// outputDirectory.
+type Prepare func(ctx context.Context, assignment Assignment, outputDirectory string, logger logging.Logger) (Job, error)
@@ -6,19 +6,36 @@ import (
 )
 
 type doc struct {
-	Attack    string      `json:"attack"`
-	Uuid      string      `json:"uuid"`
-	Code      int         `json:"code"`
-	Timestamp time.Time   `json:"timestamp"`
-	Latency   int         `json:"latency"`
-	BytesOut  int         `json:"bytes_out"`
-	BytesIn   int         `json:"bytes_in"`
-	Error     string      `json:"error"`
-	Body      string      `json:"body"`
-	Method    string      `json:"method"`
-	URL       string      `json:"url"`
-	HasError  bool        `json:"has_error"`
-	HasBody   bool        `json:"has_body"`
-	Version   string      `json:"version"`
-	Headers   http.Header `json:"headers"`
+	Attack     string      `json:"attack"`
+	Uuid       string      `json:"uuid"`
+	Code       int         `json:"code"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Latency    int         `json:"latency"`
+	BytesOut   int         `json:"bytes_out"`
+	BytesIn    int         `json:"bytes_in"`
+	Error      string      `json:"error"`
+	Body       string      `json:"body"`
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	QueryShape string      `json:"query_shape"`
+	HasError   bool        `json:"has_error"`
+	HasBody    bool        `json:"has_body"`
+	Version    string      `json:"version"`
+	Headers    http.Header `json:"headers"`
+
+	// Identity that sent the request, written with each result by the runner.
+	Connection      string `json:"connection"`
+	ConnectionIndex int    `json:"connection_index"`
+	OrganizationID  string `json:"organization_id"`
+	Organization    string `json:"organization"`
+
+	// IDs to look the request up in tracing backends.
+	TraceID     string `json:"trace_id"`
+	OperationID string `json:"operation_id"`
+
+	// Throttling of the request by the gateway, which is not an error, and
+	// the pause of the attack by the backoff policy before the request.
+	Throttled  bool    `json:"throttled"`
+	RetryAfter float64 `json:"retry_after,omitempty"`
+	Backoff    int     `json:"backoff,omitempty"`
 }
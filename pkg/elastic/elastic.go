@@ -11,7 +11,9 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
 	opensearch "github.com/opensearch-project/opensearch-go"
 	"github.com/opensearch-project/opensearch-go/opensearchutil"
 	"github.com/spf13/viper"
@@ -35,8 +37,16 @@ func NewESIndexer(ctx context.Context, logger logging.Logger) (*ESIndexer, error
 func newClient(ctx context.Context, logger logging.Logger) (*opensearch.Client, error) {
 	logger.Info(ctx, "Building ES configuration")
 	logger.Debug(ctx, "Using server: %s", viper.GetString("elastic.server"))
+	proxy, err := helpers.ProxyConfig{
+		URL:     viper.GetString("http-proxy"),
+		NoProxy: viper.GetStringSlice("no-proxy"),
+	}.Func()
+	if err != nil {
+		return nil, err
+	}
 	cfg := opensearch.Config{
 		Transport: &http.Transport{
+			Proxy:           proxy,
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: viper.GetBool("elastic.insecure-skip-verify")},
 		},
 		Addresses: []string{
@@ -111,12 +121,13 @@ func (in *ESIndexer) IndexFile(ctx context.Context, testID string, version strin
 			errors = fmt.Sprintf("%s\n%s", errors, err)
 			continue
 		}
-		if _doc.Error != "" {
+		if _doc.Error != "" && !_doc.Throttled {
 			_doc.HasError = true
 		}
 		if _doc.Body != "" {
 			_doc.HasBody = true
 		}
+		_doc.QueryShape = results.QueryShape(_doc.URL)
 		_doc.Uuid = testID
 		_doc.Version = version
 		m, err := json.Marshal(_doc)
@@ -0,0 +1,232 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/spf13/viper"
+)
+
+const (
+	VerdictPass       = "pass"
+	VerdictFail       = "fail"
+	VerdictNoBaseline = "no-baseline"
+)
+
+// baselineCandidates is the number of most recent runs of the index looked
+// at to find a baseline.
+const baselineCandidates = 100
+
+// RunDocument describes a whole run. It is stored in the index along with the
+// result documents of the run, so following runs can find a baseline to
+// compare with.
+type RunDocument struct {
+	Uuid        string    `json:"uuid"`
+	Version     string    `json:"version"`
+	Timestamp   time.Time `json:"timestamp"`
+	Tests       []string  `json:"tests"`
+	RateProfile string    `json:"rate_profile"`
+	ProfileID   string    `json:"profile_id"`
+	Verdict     string    `json:"verdict"`
+	Baseline    string    `json:"baseline"`
+}
+
+// NewRunDocument builds the document of a run from the load profile of each
+// test, e.g. {"list-clusters": "10/s", "cluster-authorizations": "linear:1-50/6"}.
+func NewRunDocument(testID, version string, profiles map[string]string) RunDocument {
+	tests := make([]string, 0, len(profiles))
+	for name := range profiles {
+		tests = append(tests, name)
+	}
+	sort.Strings(tests)
+	parts := make([]string, len(tests))
+	for i, name := range tests {
+		parts[i] = fmt.Sprintf("%s=%s", name, profiles[name])
+	}
+	profile := strings.Join(parts, ";")
+	sum := sha256.Sum256([]byte(profile))
+	return RunDocument{
+		Uuid:        testID,
+		Version:     version,
+		Timestamp:   time.Now(),
+		Tests:       tests,
+		RateProfile: profile,
+		ProfileID:   hex.EncodeToString(sum[:]),
+	}
+}
+
+// IndexRun stores the run document in the index of the results.
+func IndexRun(ctx context.Context, run RunDocument, logger logging.Logger) error {
+	cli, err := newClient(ctx, logger)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	index := viper.GetString("elastic.index")
+	res, err := cli.Index(index, bytes.NewReader(body),
+		cli.Index.WithContext(ctx),
+		cli.Index.WithDocumentID("run-"+run.Uuid),
+		cli.Index.WithRefresh("true"),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("indexing run %s: %s", run.Uuid, res.Status())
+	}
+	logger.Info(ctx, "Run %s indexed in %s with verdict %s", run.Uuid, index, run.Verdict)
+	return nil
+}
+
+// runBucket is a run of the index, aggregated from its documents by uuid.
+type runBucket struct {
+	Key    string `json:"key"`
+	Latest struct {
+		Value float64 `json:"value"`
+	} `json:"latest"`
+	Version  termsAggregation `json:"version"`
+	Attacks  termsAggregation `json:"attacks"`
+	Profile  termsAggregation `json:"profile"`
+	Verdicts termsAggregation `json:"verdicts"`
+}
+
+type termsAggregation struct {
+	Buckets []struct {
+		Key string `json:"key"`
+	} `json:"buckets"`
+}
+
+func (a termsAggregation) first() string {
+	if len(a.Buckets) == 0 {
+		return ""
+	}
+	return a.Buckets[0].Key
+}
+
+// FindBaselineRun returns the most recent run, other than the given one, with
+// the same tests and rate profile that did not fail its own comparison.
+// The runs are aggregated by uuid from the documents of the index, so runs
+// indexed without a run document are found too, by their tests only, as their
+// rates are unknown. Returns nil when there is no such run.
+func FindBaselineRun(ctx context.Context, run RunDocument, logger logging.Logger) (*RunDocument, error) {
+	cli, err := newClient(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+	terms := func(field string, size int) map[string]interface{} {
+		return map[string]interface{}{"terms": map[string]interface{}{"field": field, "size": size}}
+	}
+	query := map[string]interface{}{
+		"size": 0,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must_not": []interface{}{
+					map[string]interface{}{"match_phrase": map[string]interface{}{"uuid": run.Uuid}},
+				},
+			},
+		},
+		"aggs": map[string]interface{}{
+			"runs": map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": "uuid.keyword",
+					"size":  baselineCandidates,
+					"order": map[string]interface{}{"latest": "desc"},
+				},
+				"aggs": map[string]interface{}{
+					"latest":   map[string]interface{}{"max": map[string]interface{}{"field": "timestamp"}},
+					"version":  terms("version.keyword", 1),
+					"attacks":  terms("attack.keyword", searchPageSize),
+					"profile":  terms("profile_id.keyword", 1),
+					"verdicts": terms("verdict.keyword", 1),
+				},
+			},
+		},
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	res, err := cli.Search(
+		cli.Search.WithContext(ctx),
+		cli.Search.WithIndex(viper.GetString("elastic.index")),
+		cli.Search.WithBody(bytes.NewReader(body)),
+		cli.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("searching baseline run: %s", res.Status())
+	}
+	var page struct {
+		Aggregations struct {
+			Runs struct {
+				Buckets []runBucket `json:"buckets"`
+			} `json:"runs"`
+		} `json:"aggregations"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&page)
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range page.Aggregations.Runs.Buckets {
+		if baseline := bucket.baselineOf(run); baseline != nil {
+			return baseline, nil
+		}
+	}
+	return nil, nil
+}
+
+// baselineOf returns the run of the bucket when it can be the baseline of the
+// given run, nil otherwise. The rate profile is only checked for runs with a
+// run document.
+func (b runBucket) baselineOf(run RunDocument) *RunDocument {
+	if b.Verdicts.first() == VerdictFail {
+		return nil
+	}
+	profileID := b.Profile.first()
+	if profileID != "" && profileID != run.ProfileID {
+		return nil
+	}
+	tests := runTests(b.Attacks)
+	if strings.Join(tests, ",") != strings.Join(run.Tests, ",") {
+		return nil
+	}
+	return &RunDocument{
+		Uuid:      b.Key,
+		Version:   b.Version.first(),
+		Timestamp: time.Unix(0, int64(b.Latest.Value)*int64(time.Millisecond)),
+		Tests:     tests,
+		ProfileID: profileID,
+		Verdict:   b.Verdicts.first(),
+	}
+}
+
+// runTests returns the sorted tests of the attacks of a run. The steps of the
+// journey tests are attacks named `<test-name>/<step>`.
+func runTests(attacks termsAggregation) []string {
+	seen := map[string]bool{}
+	tests := []string{}
+	for _, attack := range attacks.Buckets {
+		test := strings.SplitN(attack.Key, "/", 2)[0]
+		if !seen[test] {
+			seen[test] = true
+			tests = append(tests, test)
+		}
+	}
+	sort.Strings(tests)
+	return tests
+}
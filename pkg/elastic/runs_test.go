@@ -0,0 +1,161 @@
+package elastic
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestNewRunDocument(t *testing.T) {
+	a := NewRunDocument("id-1", "v1", map[string]string{"list-clusters": "10/s", "cluster-authorizations": "linear:1-50/6"})
+	b := NewRunDocument("id-2", "v2", map[string]string{"cluster-authorizations": "linear:1-50/6", "list-clusters": "10/s"})
+	c := NewRunDocument("id-3", "v2", map[string]string{"cluster-authorizations": "linear:1-50/6", "list-clusters": "20/s"})
+	if a.RateProfile != "cluster-authorizations=linear:1-50/6;list-clusters=10/s" {
+		t.Errorf("RateProfile = %s", a.RateProfile)
+	}
+	if a.ProfileID != b.ProfileID {
+		t.Errorf("runs with the same profile must have the same profile ID")
+	}
+	if a.ProfileID == c.ProfileID {
+		t.Errorf("runs with different rates must have different profile IDs")
+	}
+}
+
+// fakeOpenSearch answers the search and scroll requests with the given pages.
+func fakeOpenSearch(pages ...string) *httptest.Server {
+	call := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version":{"number":"1.3.0","distribution":"opensearch"}}`))
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.Write([]byte(`{"succeeded":true}`))
+			return
+		}
+		if call >= len(pages) {
+			w.Write([]byte(`{"_scroll_id":"s","hits":{"hits":[]}}`))
+			return
+		}
+		w.Write([]byte(pages[call]))
+		call++
+	}))
+}
+
+// runsAggregation answers a baseline search with the given run buckets.
+func runsAggregation(buckets ...string) string {
+	return `{"hits":{"hits":[]},"aggregations":{"runs":{"buckets":[` + strings.Join(buckets, ",") + `]}}}`
+}
+
+func runBucketJSON(uuid, profileID, verdict string, attacks ...string) string {
+	terms := func(keys ...string) string {
+		buckets := []string{}
+		for _, key := range keys {
+			if key != "" {
+				buckets = append(buckets, `{"key":"`+key+`"}`)
+			}
+		}
+		return `{"buckets":[` + strings.Join(buckets, ",") + `]}`
+	}
+	return `{"key":"` + uuid + `","latest":{"value":1600000000000},"version":` + terms("v1") +
+		`,"attacks":` + terms(attacks...) + `,"profile":` + terms(profileID) + `,"verdicts":` + terms(verdict) + `}`
+}
+
+func TestFindBaselineRun(t *testing.T) {
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	ctx := context.TODO()
+	run := NewRunDocument("candidate", "v2", map[string]string{"list-clusters": "10/s", "cluster-lifecycle": "1/s"})
+	other := NewRunDocument("other", "v2", map[string]string{"list-clusters": "20/s", "cluster-lifecycle": "1/s"})
+
+	tests := []struct {
+		name    string
+		buckets []string
+		want    string
+	}{
+		{
+			name: "same profile",
+			buckets: []string{
+				runBucketJSON("baseline", run.ProfileID, VerdictPass, "list-clusters", "cluster-lifecycle/create", "cluster-lifecycle/poll"),
+			},
+			want: "baseline",
+		},
+		{
+			name: "skips failed and other profiles",
+			buckets: []string{
+				runBucketJSON("failed", run.ProfileID, VerdictFail, "list-clusters", "cluster-lifecycle/create"),
+				runBucketJSON("other", other.ProfileID, VerdictPass, "list-clusters", "cluster-lifecycle/create"),
+				runBucketJSON("baseline", run.ProfileID, VerdictNoBaseline, "list-clusters", "cluster-lifecycle/create"),
+			},
+			want: "baseline",
+		},
+		{
+			name: "run without run document",
+			buckets: []string{
+				runBucketJSON("fewer-tests", "", "", "list-clusters"),
+				runBucketJSON("old", "", "", "list-clusters", "cluster-lifecycle/create"),
+			},
+			want: "old",
+		},
+		{
+			name:    "not found",
+			buckets: []string{runBucketJSON("fewer-tests", "", "", "list-clusters")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeOpenSearch(runsAggregation(tt.buckets...))
+			defer server.Close()
+			initConfig()
+			viper.Set("elastic", map[string]interface{}{"server": server.URL, "index": "ocm"})
+
+			got, err := FindBaselineRun(ctx, run, logger)
+			if err != nil {
+				t.Fatalf("FindBaselineRun() error = %v", err)
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("FindBaselineRun() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.Uuid != tt.want || got.Version != "v1" {
+				t.Errorf("FindBaselineRun() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchResults(t *testing.T) {
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	ctx := context.TODO()
+	hit := `{"_source":{"attack":"list-clusters","uuid":"baseline","code":200,"latency":1000000}}`
+	server := fakeOpenSearch(
+		`{"_scroll_id":"s","hits":{"hits":[`+strings.Join([]string{hit, hit}, ",")+`]}}`,
+		`{"_scroll_id":"s","hits":{"hits":[`+hit+`]}}`)
+	defer server.Close()
+	initConfig()
+	viper.Set("elastic", map[string]interface{}{"server": server.URL, "index": "ocm"})
+
+	got := []*vegeta.Result{}
+	err := SearchResults(ctx, "baseline", logger, func(res *vegeta.Result) {
+		got = append(got, res)
+	})
+	if err != nil {
+		t.Fatalf("SearchResults() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("SearchResults() returned %d results, want 3", len(got))
+	}
+	if got[0].Attack != "list-clusters" || got[0].Code != 200 || got[0].Latency.Milliseconds() != 1 {
+		t.Errorf("SearchResults() = %+v", got[0])
+	}
+}
@@ -0,0 +1,139 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	opensearch "github.com/opensearch-project/opensearch-go"
+	"github.com/opensearch-project/opensearch-go/opensearchapi"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	searchPageSize = 1000
+	scrollTimeout  = time.Minute
+)
+
+type searchResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []struct {
+			Source doc `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// SearchResults fetches every document indexed for the given test ID and
+// feeds them, as vegeta results, to the given function.
+func SearchResults(ctx context.Context, testID string, logger logging.Logger, fn func(*vegeta.Result)) error {
+	cli, err := newClient(ctx, logger)
+	if err != nil {
+		return err
+	}
+	// The run document of the test, see IndexRun, is not a result
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"match_phrase": map[string]interface{}{"uuid": testID}},
+				},
+				"must_not": []interface{}{
+					map[string]interface{}{"exists": map[string]interface{}{"field": "verdict"}},
+				},
+			},
+		},
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	logger.Info(ctx, "Searching results for test ID %s in index %s", testID, viper.GetString("elastic.index"))
+	res, err := cli.Search(
+		cli.Search.WithContext(ctx),
+		cli.Search.WithIndex(viper.GetString("elastic.index")),
+		cli.Search.WithBody(bytes.NewReader(body)),
+		cli.Search.WithSize(searchPageSize),
+		cli.Search.WithScroll(scrollTimeout),
+	)
+	if err != nil {
+		return err
+	}
+	page, err := decodeSearchResponse(res)
+	if err != nil {
+		return err
+	}
+	defer clearScroll(ctx, cli, page.ScrollID, logger)
+
+	total := 0
+	for len(page.Hits.Hits) > 0 {
+		for _, hit := range page.Hits.Hits {
+			fn(hit.Source.toResult())
+		}
+		total += len(page.Hits.Hits)
+		res, err := cli.Scroll(
+			cli.Scroll.WithContext(ctx),
+			cli.Scroll.WithScrollID(page.ScrollID),
+			cli.Scroll.WithScroll(scrollTimeout),
+		)
+		if err != nil {
+			return err
+		}
+		page, err = decodeSearchResponse(res)
+		if err != nil {
+			return err
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("no documents found for test ID %s", testID)
+	}
+	logger.Info(ctx, "Loaded %d documents for test ID %s", total, testID)
+	return nil
+}
+
+func decodeSearchResponse(res *opensearchapi.Response) (*searchResponse, error) {
+	defer res.Body.Close()
+	if res.IsError() {
+		b, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("search failed: %s: %s", res.Status(), b)
+	}
+	page := &searchResponse{}
+	err := json.NewDecoder(res.Body).Decode(page)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func clearScroll(ctx context.Context, cli *opensearch.Client, scrollID string, logger logging.Logger) {
+	if scrollID == "" {
+		return
+	}
+	res, err := cli.ClearScroll(cli.ClearScroll.WithContext(ctx), cli.ClearScroll.WithScrollID(scrollID))
+	if err != nil {
+		logger.Warn(ctx, "clearing scroll: %s", err)
+		return
+	}
+	res.Body.Close()
+}
+
+// toResult converts an indexed document back to a vegeta result.
+func (d doc) toResult() *vegeta.Result {
+	return &vegeta.Result{
+		Attack:    d.Attack,
+		Code:      uint16(d.Code),
+		Timestamp: d.Timestamp,
+		Latency:   time.Duration(d.Latency),
+		BytesOut:  uint64(d.BytesOut),
+		BytesIn:   uint64(d.BytesIn),
+		Error:     d.Error,
+		Method:    d.Method,
+		URL:       d.URL,
+		Headers:   d.Headers,
+	}
+}
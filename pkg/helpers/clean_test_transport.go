@@ -43,9 +43,28 @@ func (t *CleanTestTransport) RoundTrip(request *http.Request) (*http.Response, e
 	if t.isClusterAuthorization(request) && response.StatusCode == 200 {
 		response = t.addToArchive(request, response, false)
 	}
+	if t.isQuotaAuthorization(request) && response.StatusCode == 200 {
+		response = t.addToArchive(request, response, false)
+	}
 	if t.isServicesCreate(request) && response.StatusCode == 201 {
 		response = t.addToServiceCleanup(request, response)
 	}
+	for _, collection := range trackedCollections {
+		if t.isResourceCreate(request, collection) && response.StatusCode == 201 {
+			response = t.addToResourceCleanup(request, response, collection)
+		}
+		if t.isResourceDelete(request, collection) && response.StatusCode == 204 {
+			t.removeResourceFromCleanup(request, collection)
+		}
+	}
+	for _, collection := range []string{MachinePoolsResource, AddOnsResource} {
+		if t.isClusterResourceCreate(request, collection) && response.StatusCode == 201 {
+			response = t.addToClusterResourceCleanup(request, response, collection)
+		}
+		if t.isClusterResourceDelete(request, collection) && response.StatusCode == 204 {
+			t.removeClusterResourceFromCleanup(request, collection)
+		}
+	}
 	return response, err
 }
 
@@ -121,6 +140,8 @@ func (t *CleanTestTransport) removeFromCleanup(request *http.Request) {
 	parts := strings.Split(url, "/")
 	clusterID := parts[len(parts)-1]
 	t.Logger.Info(ctx, "Removing cluster '%s' from cleanup", clusterID)
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
 	delete(createdClusterIDs, clusterID)
 }
 
@@ -159,6 +180,11 @@ func (t *CleanTestTransport) isClusterAuthorization(request *http.Request) bool
 	return request.Method == "POST" && strings.HasSuffix(url, "/cluster_authorizations") && request.Body != nil
 }
 
+func (t *CleanTestTransport) isQuotaAuthorization(request *http.Request) bool {
+	url := strings.TrimSuffix(request.URL.String(), "/")
+	return request.Method == "POST" && strings.HasSuffix(url, "/quota_authorizations") && request.Body != nil
+}
+
 func (t *CleanTestTransport) isDeleteCluster(request *http.Request) bool {
 	parts := strings.Split(request.URL.String(), "/")
 	return parts[len(parts)-2] == "clusters" && request.Method == "DELETE"
@@ -166,15 +192,27 @@ func (t *CleanTestTransport) isDeleteCluster(request *http.Request) bool {
 
 func markClusterForCleanup(ctx context.Context, clusterID string, deprovision bool, logger logging.Logger) {
 	logger.Info(ctx, "Marking cluster '%s' for cleanup with 'deprovision'=%v", clusterID, deprovision)
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
 	createdClusterIDs[clusterID] = deprovision
 }
 
 func markSubscriptionForArchiving(ctx context.Context, subscriptionID string, logger logging.Logger) {
 	logger.Info(ctx, "Marking subscription '%s' for archiving", subscriptionID)
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
 	createdSubcriptionIDs = append(createdSubcriptionIDs, subscriptionID)
 }
 
+func markFailedSubscriptionCleanup(subscriptionID string) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	failedDeletedSubcriptionIDs = append(failedDeletedSubcriptionIDs, subscriptionID)
+}
+
 func markFailedCleanup(clusterID string) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
 	failedCleanupClusterIDs = append(failedCleanupClusterIDs, clusterID)
 	delete(createdClusterIDs, clusterID)
 }
@@ -251,9 +289,174 @@ func (t *CleanTestTransport) addToServiceCleanup(request *http.Request, response
 
 func markServiceForCleanup(ctx context.Context, serviceID string, logger logging.Logger) {
 	logger.Info(ctx, "Marking service '%s' for deleting", serviceID)
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
 	createdServiceIDs = append(createdServiceIDs, serviceID)
 }
 
 func markFailedServiceCleanup(serviceID string) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
 	failedDeletedServicesIDs = append(failedDeletedServicesIDs, serviceID)
 }
+
+func (t *CleanTestTransport) isResourceCreate(request *http.Request, collection string) bool {
+	url := strings.TrimSuffix(request.URL.Path, "/")
+	return request.Method == "POST" &&
+		strings.HasSuffix(url, strings.TrimSuffix(collection, "/")) && request.Body != nil
+}
+
+func (t *CleanTestTransport) isResourceDelete(request *http.Request, collection string) bool {
+	return request.Method == "DELETE" && resourceID(request, collection) != ""
+}
+
+// resourceID returns the ID of the resource of the collection addressed by
+// the request, if any.
+func resourceID(request *http.Request, collection string) string {
+	i := strings.Index(request.URL.Path, collection)
+	if i < 0 {
+		return ""
+	}
+	id := strings.TrimSuffix(request.URL.Path[i+len(collection):], "/")
+	if strings.Contains(id, "/") {
+		return ""
+	}
+	return id
+}
+
+func (t *CleanTestTransport) addToResourceCleanup(request *http.Request, response *http.Response, collection string) *http.Response {
+	ctx := request.Context()
+
+	var resource map[string]interface{}
+	err := json.NewDecoder(response.Body).Decode(&resource)
+	if err != nil {
+		t.Logger.Error(ctx, "Failed to unmarshal body of response for request %s %s: %v", request.Method,
+			request.URL.String(), err)
+		return response
+	}
+	id, ok := resource["id"].(string)
+	if !ok {
+		t.Logger.Error(ctx, "Failed to get ID from body of response for request %s %s", request.Method,
+			request.URL.String())
+		return response
+	}
+	markResourceForCleanup(ctx, collection, id, t.Logger)
+
+	body, err := json.Marshal(resource)
+	if err != nil {
+		t.Logger.Error(ctx, "Failed to marshall body of response for request %s %s: %v", request.Method,
+			request.URL.String(), err)
+		return response
+	}
+	response.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+	return response
+}
+
+func (t *CleanTestTransport) removeResourceFromCleanup(request *http.Request, collection string) {
+	id := resourceID(request, collection)
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	ids := createdResourceIDs[collection]
+	for i, createdID := range ids {
+		if createdID == id {
+			createdResourceIDs[collection] = append(ids[:i], ids[i+1:]...)
+			return
+		}
+	}
+}
+
+func markResourceForCleanup(ctx context.Context, collection, id string, logger logging.Logger) {
+	logger.Debug(ctx, "Marking '%s%s' for deleting", collection, id)
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	createdResourceIDs[collection] = append(createdResourceIDs[collection], id)
+}
+
+func markFailedResourceCleanup(path string) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	failedDeletedResources = append(failedDeletedResources, path)
+}
+
+// clusterResourceURLParts splits `.../clusters/{id}/{collection}[/{resourceId}]`
+// returning the cluster ID and the resource ID, if any.
+func clusterResourceURLParts(request *http.Request, collection string) (clusterID, id string, ok bool) {
+	url := strings.TrimSuffix(strings.Split(request.URL.String(), "?")[0], "/")
+	parts := strings.Split(url, "/")
+	for i := len(parts) - 1; i >= 2; i-- {
+		if parts[i] == collection && parts[i-2] == "clusters" {
+			if i+1 < len(parts) {
+				id = parts[i+1]
+			}
+			return parts[i-1], id, i+2 >= len(parts)
+		}
+	}
+	return "", "", false
+}
+
+func (t *CleanTestTransport) isClusterResourceCreate(request *http.Request, collection string) bool {
+	_, id, ok := clusterResourceURLParts(request, collection)
+	return request.Method == "POST" && ok && id == "" && request.Body != nil
+}
+
+func (t *CleanTestTransport) isClusterResourceDelete(request *http.Request, collection string) bool {
+	_, id, ok := clusterResourceURLParts(request, collection)
+	return request.Method == "DELETE" && ok && id != ""
+}
+
+func (t *CleanTestTransport) addToClusterResourceCleanup(request *http.Request, response *http.Response, collection string) *http.Response {
+	ctx := request.Context()
+
+	var resource map[string]interface{}
+	err := json.NewDecoder(response.Body).Decode(&resource)
+	if err != nil {
+		t.Logger.Error(ctx, "Failed to unmarshal body of response for request %s %s: %v", request.Method,
+			request.URL.String(), err)
+		return response
+	}
+	id, ok := resource["id"].(string)
+	if !ok {
+		t.Logger.Error(ctx, "Failed to get ID from body of response for request %s %s", request.Method,
+			request.URL.String())
+		return response
+	}
+	clusterID, _, _ := clusterResourceURLParts(request, collection)
+	markClusterResourceForCleanup(ctx, collection, clusterID, id, t.Logger)
+
+	body, err := json.Marshal(resource)
+	if err != nil {
+		t.Logger.Error(ctx, "Failed to marshall body of response for request %s %s: %v", request.Method,
+			request.URL.String(), err)
+		return response
+	}
+	response.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+	return response
+}
+
+func (t *CleanTestTransport) removeClusterResourceFromCleanup(request *http.Request, collection string) {
+	ctx := request.Context()
+	clusterID, id, _ := clusterResourceURLParts(request, collection)
+	t.Logger.Info(ctx, "Removing '%s' from cleanup", ClusterResourcePath(collection, clusterID, id))
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	resources := createdClusterResources[collection]
+	for i, r := range resources {
+		if r.clusterID == clusterID && r.id == id {
+			createdClusterResources[collection] = append(resources[:i], resources[i+1:]...)
+			return
+		}
+	}
+}
+
+func markClusterResourceForCleanup(ctx context.Context, collection, clusterID, id string, logger logging.Logger) {
+	logger.Info(ctx, "Marking '%s' for deleting", ClusterResourcePath(collection, clusterID, id))
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	createdClusterResources[collection] = append(createdClusterResources[collection], clusterResource{clusterID: clusterID, id: id})
+}
+
+func markFailedClusterResourceCleanup(path string) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	failedDeletedClusterResources = append(failedDeletedClusterResources, path)
+}
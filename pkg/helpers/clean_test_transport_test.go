@@ -0,0 +1,153 @@
+package helpers
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestCleanTestTransport_NoCredentialsInLogs(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	logFile := filepath.Join(t.TempDir(), "transport.log")
+	logger, err := logging.NewGoLoggerBuilder().Debug(true).LogFile(logFile).Build()
+	if err != nil {
+		t.Fatalf("building logger: %v", err)
+	}
+
+	body := `{"name":"pocm-abcd-0","aws":{"access_key_id":"ASD7ASFET65FFGHDFFS","secret_access_key":"fjhgsadf6dfghdfgdsdf","account_id":"123434565665"}}`
+	transport := &CleanTestTransport{
+		Logger: logger,
+		Wrapped: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusBadRequest, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+		}),
+	}
+	request, _ := http.NewRequest(http.MethodPost, "http://localhost"+strings.TrimSuffix(ClustersEndpoint, "/"), strings.NewReader(body))
+	if _, err := transport.RoundTrip(request); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	logger.DeferClose()
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("reading log file: %v", err)
+	}
+	if !strings.Contains(string(content), "pocm-abcd-0") {
+		t.Fatalf("expected the request body to be logged, got:\n%s", content)
+	}
+	for _, secret := range []string{"ASD7ASFET65FFGHDFFS", "fjhgsadf6dfghdfgdsdf"} {
+		if strings.Contains(string(content), secret) {
+			t.Errorf("log file contains credential %q:\n%s", secret, content)
+		}
+	}
+}
+
+func Test_clusterResourceURLParts(t *testing.T) {
+	tests := []struct {
+		name          string
+		url           string
+		wantClusterID string
+		wantID        string
+		wantOk        bool
+	}{
+		{"collection", "http://localhost/api/clusters_mgmt/v1/clusters/abc/machine_pools", "abc", "", true},
+		{"collection with query", "http://localhost/api/clusters_mgmt/v1/clusters/abc/machine_pools?page=2", "abc", "", true},
+		{"resource", "http://localhost/api/clusters_mgmt/v1/clusters/abc/machine_pools/mp-1", "abc", "mp-1", true},
+		{"sub resource", "http://localhost/api/clusters_mgmt/v1/clusters/abc/machine_pools/mp-1/status", "abc", "mp-1", false},
+		{"other collection", "http://localhost/api/clusters_mgmt/v1/clusters/abc/addons", "", "", false},
+		{"cluster", "http://localhost/api/clusters_mgmt/v1/clusters/abc", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			clusterID, id, ok := clusterResourceURLParts(request, MachinePoolsResource)
+			if clusterID != tt.wantClusterID || id != tt.wantID || ok != tt.wantOk {
+				t.Errorf("clusterResourceURLParts() = (%q, %q, %v), want (%q, %q, %v)",
+					clusterID, id, ok, tt.wantClusterID, tt.wantID, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCleanTestTransport_TracksClusterResources(t *testing.T) {
+	logger, err := logging.NewGoLoggerBuilder().Build()
+	if err != nil {
+		t.Fatalf("building logger: %v", err)
+	}
+	defer func() { createdClusterResources = map[string][]clusterResource{} }()
+
+	transport := &CleanTestTransport{
+		Logger: logger,
+		Wrapped: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Method == http.MethodDelete {
+				return &http.Response{StatusCode: http.StatusNoContent, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			}
+			return &http.Response{StatusCode: http.StatusCreated, Body: ioutil.NopCloser(strings.NewReader(`{"id":"mp-1"}`))}, nil
+		}),
+	}
+	collection := "http://localhost" + ClustersEndpoint + "abc/" + MachinePoolsResource
+
+	request, _ := http.NewRequest(http.MethodPost, collection, strings.NewReader(`{"id":"mp-1"}`))
+	if _, err := transport.RoundTrip(request); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	want := []clusterResource{{clusterID: "abc", id: "mp-1"}}
+	if got := createdClusterResources[MachinePoolsResource]; len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("tracked resources = %v, want %v", got, want)
+	}
+
+	request, _ = http.NewRequest(http.MethodDelete, collection+"/mp-1", nil)
+	if _, err := transport.RoundTrip(request); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	if got := createdClusterResources[MachinePoolsResource]; len(got) != 0 {
+		t.Errorf("tracked resources after delete = %v, want none", got)
+	}
+}
+
+func TestCleanTestTransport_TracksResources(t *testing.T) {
+	logger, err := logging.NewGoLoggerBuilder().Build()
+	if err != nil {
+		t.Fatalf("building logger: %v", err)
+	}
+	defer func() { createdResourceIDs = map[string][]string{} }()
+
+	transport := &CleanTestTransport{
+		Logger: logger,
+		Wrapped: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Method == http.MethodDelete {
+				return &http.Response{StatusCode: http.StatusNoContent, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			}
+			return &http.Response{StatusCode: http.StatusCreated, Body: ioutil.NopCloser(strings.NewReader(`{"id":"res-1"}`))}, nil
+		}),
+	}
+
+	for _, collection := range trackedCollections {
+		t.Run(collection, func(t *testing.T) {
+			request, _ := http.NewRequest(http.MethodPost, "http://localhost"+strings.TrimSuffix(collection, "/"), strings.NewReader(`{}`))
+			if _, err := transport.RoundTrip(request); err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if got := createdResourceIDs[collection]; len(got) != 1 || got[0] != "res-1" {
+				t.Fatalf("tracked resources = %v, want [res-1]", got)
+			}
+
+			request, _ = http.NewRequest(http.MethodDelete, "http://localhost"+collection+"res-1", nil)
+			if _, err := transport.RoundTrip(request); err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if got := createdResourceIDs[collection]; len(got) != 0 {
+				t.Errorf("tracked resources after delete = %v, want none", got)
+			}
+		})
+	}
+}
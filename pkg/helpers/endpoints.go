@@ -4,4 +4,10 @@ const (
 	ClustersEndpoint     = "/api/clusters_mgmt/v1/clusters/"
 	SubscriptionEndpoint = "/api/accounts_mgmt/v1/subscriptions/"
 	ServiceEndpoint      = "/api/service_mgmt/v1/services/"
+	ServiceLogsEndpoint  = "/api/service_logs/v1/cluster_logs/"
+	RoleBindingsEndpoint = "/api/accounts_mgmt/v1/role_bindings/"
+
+	// Collections of resources that belong to a cluster
+	MachinePoolsResource = "machine_pools"
+	AddOnsResource       = "addons"
 )
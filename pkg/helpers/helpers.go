@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/Rican7/retry"
@@ -14,6 +15,10 @@ import (
 	errors "github.com/zgalor/weberr"
 )
 
+// cleanupMu guards the cleanup tracking below, which is updated from every
+// concurrent request going through the CleanTestTransport.
+var cleanupMu sync.Mutex
+
 // createdClusterIDs maps the IDs of the cluster created by testing to a bool value for `deprovision`.
 var createdClusterIDs = map[string]bool{}
 var validateDeletedClusterIDs = make([]string, 0)
@@ -22,72 +27,306 @@ var createdSubcriptionIDs = make([]string, 0)
 var validateDeletedSubcriptionIDs = make([]string, 0)
 var failedDeletedSubcriptionIDs = make([]string, 0)
 var createdServiceIDs = make([]string, 0)
+
+// clusterResource identifies a resource that belongs to a cluster, like a machine pool.
+type clusterResource struct {
+	clusterID string
+	id        string
+}
+
+// createdClusterResources maps the collection of the resources (e.g. `machine_pools`)
+// to the resources created by testing. They are deleted before the clusters.
+var createdClusterResources = map[string][]clusterResource{}
+var failedDeletedClusterResources = make([]string, 0)
 var validateDeletedServicesIDs = make([]string, 0)
 var failedDeletedServicesIDs = make([]string, 0)
 
+// trackedCollections are the collections whose resources created by testing
+// are tracked by ID and deleted by the cleanup, in this order.
+var trackedCollections = []string{RoleBindingsEndpoint, ServiceLogsEndpoint}
+
+// createdResourceIDs maps each of the trackedCollections to the IDs of the
+// resources created by testing.
+var createdResourceIDs = map[string][]string{}
+var failedDeletedResources = make([]string, 0)
+
+// Cleanup deletes the resources created by testing. The tracking is updated
+// concurrently by the CleanTestTransport, including for the deletions sent
+// here, so it is only read and updated under cleanupMu, on copies.
 func Cleanup(ctx context.Context, connection *sdk.Connection) {
-	if len(createdClusterIDs) == 0 && len(createdSubcriptionIDs) == 0 && len(createdServiceIDs) == 0 {
+	if Ledger().Empty() {
 		return
 	}
-	if len(createdClusterIDs) > 0 {
+	for _, collection := range []string{MachinePoolsResource, AddOnsResource} {
+		// Successful deletions are removed from the tracking by the transport,
+		// so iterate over a copy.
+		cleanupMu.Lock()
+		resources := append([]clusterResource{}, createdClusterResources[collection]...)
+		cleanupMu.Unlock()
+		if len(resources) == 0 {
+			continue
+		}
+		connection.Logger().Info(ctx, "About to delete the following %s:", collection)
+		for _, resource := range resources {
+			connection.Logger().Info(ctx, "Cluster ID: %s, ID: %s", resource.clusterID, resource.id)
+			DeleteClusterResource(ctx, collection, resource.clusterID, resource.id, connection)
+		}
+		cleanupMu.Lock()
+		remaining := make([]clusterResource, 0)
+		for _, r := range createdClusterResources[collection] {
+			if !containsClusterResource(resources, r) {
+				remaining = append(remaining, r)
+			}
+		}
+		createdClusterResources[collection] = remaining
+		if len(remaining) == 0 {
+			delete(createdClusterResources, collection)
+		}
+		cleanupMu.Unlock()
+	}
+	if failed := takeIDs(&failedDeletedClusterResources); len(failed) > 0 {
+		connection.Logger().Warn(ctx, "The following cluster resources failed to be deleted: %v", failed)
+	}
+
+	cleanupMu.Lock()
+	clusters := make(map[string]bool, len(createdClusterIDs))
+	for clusterID, deprovision := range createdClusterIDs {
+		clusters[clusterID] = deprovision
+	}
+	cleanupMu.Unlock()
+	if len(clusters) > 0 {
 		connection.Logger().Info(ctx, "About to clean up the following clusters:")
-		for clusterID, deprovision := range createdClusterIDs {
+		for clusterID, deprovision := range clusters {
 			connection.Logger().Info(ctx, "Cluster ID: %s, deprovision: %v", clusterID, deprovision)
 			DeleteCluster(ctx, clusterID, deprovision, connection)
 		}
-		for _, clusterID := range validateDeletedClusterIDs {
+		for _, clusterID := range takeIDs(&validateDeletedClusterIDs) {
 			err := verifyClusterDeleted(ctx, clusterID, connection)
 			if err != nil {
 				markFailedCleanup(clusterID)
-			} else {
-				delete(createdClusterIDs, clusterID)
 			}
 		}
-		if len(failedCleanupClusterIDs) > 0 {
-			connection.Logger().Warn(ctx, "The following clusters failed deletion: %v", failedCleanupClusterIDs)
+		cleanupMu.Lock()
+		for clusterID := range clusters {
+			delete(createdClusterIDs, clusterID)
+		}
+		cleanupMu.Unlock()
+		if failed := takeIDs(&failedCleanupClusterIDs); len(failed) > 0 {
+			connection.Logger().Warn(ctx, "The following clusters failed deletion: %v", failed)
 		}
-		createdClusterIDs = make(map[string]bool)
-		failedCleanupClusterIDs = make([]string, 0)
 	}
-	if len(createdSubcriptionIDs) > 0 {
+
+	if subscriptions := takeIDs(&createdSubcriptionIDs); len(subscriptions) > 0 {
 		connection.Logger().Info(ctx, "About to delete the following subscriptions:")
-		for _, subscription := range createdSubcriptionIDs {
+		for _, subscription := range subscriptions {
 			connection.Logger().Info(ctx, "Subscription ID: %s", subscription)
 			DeleteSubscription(ctx, subscription, connection)
 		}
-		for _, subscriptionID := range validateDeletedSubcriptionIDs {
+		for _, subscriptionID := range takeIDs(&validateDeletedSubcriptionIDs) {
 			err := verifySubscriptionDeleted(ctx, subscriptionID, connection)
 			if err != nil {
-				failedDeletedSubcriptionIDs = append(failedDeletedSubcriptionIDs, subscriptionID)
+				markFailedSubscriptionCleanup(subscriptionID)
 			}
 		}
-		if len(failedDeletedSubcriptionIDs) > 0 {
-			connection.Logger().Warn(ctx, "The following subscriptions failed archiving: %v", failedDeletedSubcriptionIDs)
+		if failed := takeIDs(&failedDeletedSubcriptionIDs); len(failed) > 0 {
+			connection.Logger().Warn(ctx, "The following subscriptions failed archiving: %v", failed)
 		}
-		createdSubcriptionIDs = make([]string, 0)
-		failedDeletedSubcriptionIDs = make([]string, 0)
 	}
-	if len(createdServiceIDs) > 0 {
+
+	if services := takeIDs(&createdServiceIDs); len(services) > 0 {
 		connection.Logger().Info(ctx, "About to delete the following services:")
-		for _, service := range createdServiceIDs {
+		for _, service := range services {
 			connection.Logger().Info(ctx, "Service ID: %s", service)
 			DeleteService(ctx, service, connection)
 		}
-		for _, serviceID := range validateDeletedServicesIDs {
+		for _, serviceID := range takeIDs(&validateDeletedServicesIDs) {
 			err := verifyServiceDeleted(ctx, serviceID, connection)
 			if err != nil {
-				failedDeletedServicesIDs = append(failedDeletedServicesIDs, serviceID)
+				markFailedServiceCleanup(serviceID)
+			}
+		}
+		if failed := takeIDs(&failedDeletedServicesIDs); len(failed) > 0 {
+			connection.Logger().Warn(ctx, "The following services failed to be deleted: %v", failed)
+		}
+	}
+
+	for _, collection := range trackedCollections {
+		// Successful deletions are removed from the tracking by the transport,
+		// so iterate over a copy.
+		cleanupMu.Lock()
+		ids := append([]string{}, createdResourceIDs[collection]...)
+		cleanupMu.Unlock()
+		if len(ids) == 0 {
+			continue
+		}
+		connection.Logger().Info(ctx, "About to delete %d resources of %s", len(ids), collection)
+		for _, id := range ids {
+			DeleteResource(ctx, collection, id, connection)
+		}
+		cleanupMu.Lock()
+		remaining := make([]string, 0)
+		for _, id := range createdResourceIDs[collection] {
+			if !containsID(ids, id) {
+				remaining = append(remaining, id)
 			}
 		}
-		if len(failedDeletedServicesIDs) > 0 {
-			connection.Logger().Warn(ctx, "The following services failed to be deleted: %v", failedDeletedServicesIDs)
+		createdResourceIDs[collection] = remaining
+		if len(remaining) == 0 {
+			delete(createdResourceIDs, collection)
 		}
-		createdServiceIDs = make([]string, 0)
-		failedDeletedServicesIDs = make([]string, 0)
+		cleanupMu.Unlock()
+	}
+	if failed := takeIDs(&failedDeletedResources); len(failed) > 0 {
+		connection.Logger().Warn(ctx, "The following resources failed to be deleted: %v", failed)
 	}
 }
 
-func DeleteCluster(ctx context.Context, id string, deprovision bool, connection *sdk.Connection) {
+// CleanupClusters deletes the clusters of the list created by testing, along
+// with the machine pools and add-ons created on them. Unlike Cleanup, the
+// resources created by other connections are left alone, so it can be called
+// while they are still running.
+func CleanupClusters(ctx context.Context, connection *sdk.Connection, clusterIDs []string) {
+	if len(clusterIDs) == 0 {
+		return
+	}
+	for _, collection := range []string{MachinePoolsResource, AddOnsResource} {
+		cleanupMu.Lock()
+		resources := make([]clusterResource, 0)
+		for _, r := range createdClusterResources[collection] {
+			if containsID(clusterIDs, r.clusterID) {
+				resources = append(resources, r)
+			}
+		}
+		cleanupMu.Unlock()
+		if len(resources) == 0 {
+			continue
+		}
+		connection.Logger().Info(ctx, "About to delete %d %s of %d clusters", len(resources), collection, len(clusterIDs))
+		for _, resource := range resources {
+			DeleteClusterResource(ctx, collection, resource.clusterID, resource.id, connection)
+		}
+		cleanupMu.Lock()
+		remaining := make([]clusterResource, 0)
+		for _, r := range createdClusterResources[collection] {
+			if !containsClusterResource(resources, r) {
+				remaining = append(remaining, r)
+			}
+		}
+		createdClusterResources[collection] = remaining
+		if len(remaining) == 0 {
+			delete(createdClusterResources, collection)
+		}
+		cleanupMu.Unlock()
+	}
+
+	cleanupMu.Lock()
+	clusters := make(map[string]bool, len(clusterIDs))
+	for _, clusterID := range clusterIDs {
+		if deprovision, ok := createdClusterIDs[clusterID]; ok {
+			clusters[clusterID] = deprovision
+		}
+	}
+	cleanupMu.Unlock()
+	if len(clusters) == 0 {
+		return
+	}
+	connection.Logger().Info(ctx, "About to clean up %d clusters", len(clusters))
+	for clusterID, deprovision := range clusters {
+		DeleteCluster(ctx, clusterID, deprovision, connection)
+	}
+	for _, clusterID := range takeIDsOf(&validateDeletedClusterIDs, clusterIDs) {
+		err := verifyClusterDeleted(ctx, clusterID, connection)
+		if err != nil {
+			markFailedCleanup(clusterID)
+		}
+	}
+	cleanupMu.Lock()
+	for clusterID := range clusters {
+		delete(createdClusterIDs, clusterID)
+	}
+	cleanupMu.Unlock()
+	if failed := takeIDsOf(&failedCleanupClusterIDs, clusterIDs); len(failed) > 0 {
+		connection.Logger().Warn(ctx, "The following clusters failed deletion: %v", failed)
+	}
+}
+
+// CleanupResources deletes the resources of the list created by testing in
+// one of the trackedCollections. Like CleanupClusters, the resources created
+// by other connections are left alone.
+func CleanupResources(ctx context.Context, connection *sdk.Connection, collection string, ids []string) {
+	cleanupMu.Lock()
+	tracked := make([]string, 0, len(ids))
+	for _, id := range createdResourceIDs[collection] {
+		if containsID(ids, id) {
+			tracked = append(tracked, id)
+		}
+	}
+	cleanupMu.Unlock()
+	if len(tracked) == 0 {
+		return
+	}
+	connection.Logger().Info(ctx, "About to delete %d resources of %s", len(tracked), collection)
+	paths := make([]string, len(tracked))
+	for i, id := range tracked {
+		DeleteResource(ctx, collection, id, connection)
+		paths[i] = collection + id
+	}
+	cleanupMu.Lock()
+	remaining := make([]string, 0)
+	for _, id := range createdResourceIDs[collection] {
+		if !containsID(tracked, id) {
+			remaining = append(remaining, id)
+		}
+	}
+	createdResourceIDs[collection] = remaining
+	if len(remaining) == 0 {
+		delete(createdResourceIDs, collection)
+	}
+	cleanupMu.Unlock()
+	if failed := takeIDsOf(&failedDeletedResources, paths); len(failed) > 0 {
+		connection.Logger().Warn(ctx, "The following resources failed to be deleted: %v", failed)
+	}
+}
+
+// takeIDs empties a list of the cleanup tracking, returning its IDs.
+func takeIDs(ids *[]string) []string {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	taken := *ids
+	*ids = make([]string, 0)
+	return taken
+}
+
+// takeIDsOf removes the IDs of the given list from a list of the cleanup
+// tracking, returning them.
+func takeIDsOf(ids *[]string, of []string) []string {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	taken := make([]string, 0)
+	kept := make([]string, 0, len(*ids))
+	for _, id := range *ids {
+		if containsID(of, id) {
+			taken = append(taken, id)
+		} else {
+			kept = append(kept, id)
+		}
+	}
+	*ids = kept
+	return taken
+}
+
+func containsID(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteCluster deletes the cluster and marks it to be verified by the cleanup,
+// returning an error when the deletion is not accepted.
+func DeleteCluster(ctx context.Context, id string, deprovision bool, connection *sdk.Connection) error {
 	connection.Logger().Info(ctx, "Deleting cluster '%s'", id)
 	// Send the request to delete the cluster
 	response, err := connection.Delete().
@@ -97,13 +336,63 @@ func DeleteCluster(ctx context.Context, id string, deprovision bool, connection
 	if err != nil {
 		connection.Logger().Error(ctx, "Failed to delete cluster '%s', got error: %v", id, err)
 		markFailedCleanup(id)
+		return err
 	} else if response.Status() != 204 {
 		connection.Logger().Error(ctx, "Failed to delete cluster '%s', got http status %d", id, response.Status())
 		markFailedCleanup(id)
-	} else {
-		validateDeletedClusterIDs = append(validateDeletedClusterIDs, id)
-		connection.Logger().Info(ctx, "Cluster '%s' deleted", id)
+		return errors.Errorf("Failed to delete cluster '%s': got http status %d", id, response.Status())
+	}
+	cleanupMu.Lock()
+	validateDeletedClusterIDs = append(validateDeletedClusterIDs, id)
+	cleanupMu.Unlock()
+	connection.Logger().Info(ctx, "Cluster '%s' deleted", id)
+	return nil
+}
+
+// DeleteClusterResource deletes a resource that belongs to a cluster, e.g. a
+// machine pool, given the name of its collection.
+func DeleteClusterResource(ctx context.Context, collection, clusterID, id string, connection *sdk.Connection) error {
+	path := ClusterResourcePath(collection, clusterID, id)
+	connection.Logger().Info(ctx, "Deleting '%s'", path)
+	response, err := connection.Delete().
+		Path(path).
+		Send()
+	if err != nil {
+		connection.Logger().Error(ctx, "Failed to delete '%s', got error: %v", path, err)
+		markFailedClusterResourceCleanup(path)
+		return err
+	} else if response.Status() != http.StatusNoContent && response.Status() != http.StatusNotFound {
+		connection.Logger().Error(ctx, "Failed to delete '%s', got http status %d", path, response.Status())
+		markFailedClusterResourceCleanup(path)
+		return errors.Errorf("Failed to delete '%s': got http status %d", path, response.Status())
 	}
+	connection.Logger().Info(ctx, "'%s' deleted", path)
+	return nil
+}
+
+// DeleteResource deletes a resource of one of the tracked collections.
+// A resource that no longer exists is considered deleted.
+func DeleteResource(ctx context.Context, collection, id string, connection *sdk.Connection) error {
+	path := collection + id
+	connection.Logger().Debug(ctx, "Deleting '%s'", path)
+	response, err := connection.Delete().
+		Path(path).
+		Send()
+	if err != nil {
+		connection.Logger().Error(ctx, "Failed to delete '%s', got error: %v", path, err)
+		markFailedResourceCleanup(path)
+		return err
+	} else if response.Status() != http.StatusNoContent && response.Status() != http.StatusNotFound {
+		connection.Logger().Error(ctx, "Failed to delete '%s', got http status %d", path, response.Status())
+		markFailedResourceCleanup(path)
+		return errors.Errorf("Failed to delete '%s': got http status %d", path, response.Status())
+	}
+	return nil
+}
+
+// ClusterResourcePath returns the path of a resource that belongs to a cluster.
+func ClusterResourcePath(collection, clusterID, id string) string {
+	return fmt.Sprintf("%s%s/%s/%s", ClustersEndpoint, clusterID, collection, id)
 }
 
 func DeleteSubscription(ctx context.Context, id string, connection *sdk.Connection) {
@@ -115,15 +404,17 @@ func DeleteSubscription(ctx context.Context, id string, connection *sdk.Connecti
 	if err != nil {
 		connection.Logger().Error(ctx, "Got error trying to delete subscription '%s', "+
 			"adding to failed delete subscriptions", id)
-		failedDeletedSubcriptionIDs = append(failedDeletedSubcriptionIDs, id)
+		markFailedSubscriptionCleanup(id)
 	} else if (response.Status() != http.StatusOK) && (response.Status() != http.StatusNoContent) {
 		connection.Logger().Error(ctx, "Failed to delete subscription '%s', "+
 			"got http %d, marking it as failed delete subscription",
 			id, response.Status())
-		failedDeletedSubcriptionIDs = append(failedDeletedSubcriptionIDs, id)
+		markFailedSubscriptionCleanup(id)
 
 	} else {
+		cleanupMu.Lock()
 		validateDeletedSubcriptionIDs = append(validateDeletedSubcriptionIDs, id)
+		cleanupMu.Unlock()
 		connection.Logger().Info(ctx, "Subscription '%s' deleted", id)
 	}
 }
@@ -260,7 +551,9 @@ func DeleteService(ctx context.Context, id string, connection *sdk.Connection) {
 		connection.Logger().Error(ctx, "Failed to delete service '%s', got http status %d", id, response.Status())
 		markFailedServiceCleanup(id)
 	} else {
+		cleanupMu.Lock()
 		validateDeletedServicesIDs = append(validateDeletedServicesIDs, id)
+		cleanupMu.Unlock()
 		connection.Logger().Info(ctx, "Service '%s' deleted", id)
 	}
 }
@@ -0,0 +1,134 @@
+package helpers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	sdk "github.com/openshift-online/ocm-sdk-go"
+)
+
+func TestCleanup_ConcurrentTracking(t *testing.T) {
+	defer func() {
+		createdClusterIDs = map[string]bool{}
+		validateDeletedClusterIDs = make([]string, 0)
+		failedCleanupClusterIDs = make([]string, 0)
+	}()
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	connection := newCleanupTestConnection(t, logger)
+	defer connection.Close()
+
+	for i := 0; i < 20; i++ {
+		markClusterForCleanup(ctx, fmt.Sprintf("before-%d", i), false, logger)
+	}
+	// Other connections keep creating clusters while one of them cleans up.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			markClusterForCleanup(ctx, fmt.Sprintf("during-%d", i), false, logger)
+		}
+	}()
+	Cleanup(ctx, connection)
+	wg.Wait()
+	Cleanup(ctx, connection)
+
+	if len(createdClusterIDs) != 0 {
+		t.Errorf("tracked clusters after cleanup = %d, want none", len(createdClusterIDs))
+	}
+	if len(validateDeletedClusterIDs) != 0 {
+		t.Errorf("clusters left to verify = %v, want none", validateDeletedClusterIDs)
+	}
+}
+
+func TestCleanupClusters(t *testing.T) {
+	defer func() {
+		createdClusterIDs = map[string]bool{}
+		createdClusterResources = map[string][]clusterResource{}
+		validateDeletedClusterIDs = make([]string, 0)
+		failedCleanupClusterIDs = make([]string, 0)
+	}()
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	connection := newCleanupTestConnection(t, logger)
+	defer connection.Close()
+
+	for _, clusterID := range []string{"mine-1", "mine-2", "other-1"} {
+		markClusterForCleanup(ctx, clusterID, true, logger)
+		markClusterResourceForCleanup(ctx, MachinePoolsResource, clusterID, "mp-1", logger)
+	}
+	// The other connection is deleting its cluster.
+	validateDeletedClusterIDs = append(validateDeletedClusterIDs, "other-2")
+
+	CleanupClusters(ctx, connection, []string{"mine-1", "mine-2"})
+
+	if len(createdClusterIDs) != 1 || !createdClusterIDs["other-1"] {
+		t.Errorf("tracked clusters = %v, want only other-1", createdClusterIDs)
+	}
+	resources := createdClusterResources[MachinePoolsResource]
+	if len(resources) != 1 || resources[0].clusterID != "other-1" {
+		t.Errorf("tracked machine pools = %v, want only the one of other-1", resources)
+	}
+	if len(validateDeletedClusterIDs) != 1 || validateDeletedClusterIDs[0] != "other-2" {
+		t.Errorf("clusters left to verify = %v, want other-2", validateDeletedClusterIDs)
+	}
+}
+
+func TestCleanupResources(t *testing.T) {
+	defer func() {
+		createdResourceIDs = map[string][]string{}
+		failedDeletedResources = make([]string, 0)
+	}()
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	connection := newCleanupTestConnection(t, logger)
+	defer connection.Close()
+
+	for _, id := range []string{"mine-1", "mine-2", "other-1"} {
+		markResourceForCleanup(ctx, ServiceLogsEndpoint, id, logger)
+	}
+
+	CleanupResources(ctx, connection, ServiceLogsEndpoint, []string{"mine-1", "mine-2", "untracked"})
+
+	ids := createdResourceIDs[ServiceLogsEndpoint]
+	if len(ids) != 1 || ids[0] != "other-1" {
+		t.Errorf("tracked service logs = %v, want only other-1", ids)
+	}
+}
+
+// newCleanupTestConnection returns a connection to a server accepting every
+// deletion, whose requests go through the CleanTestTransport.
+func newCleanupTestConnection(t *testing.T, logger logging.Logger) *sdk.Connection {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	encode := base64.RawURLEncoding.EncodeToString
+	token := encode([]byte(`{"alg":"none"}`)) + "." +
+		encode([]byte(fmt.Sprintf(`{"typ":"Bearer","exp":%d}`, time.Now().Add(time.Hour).Unix()))) + "."
+	connection, err := sdk.NewConnectionBuilder().
+		URL(server.URL).
+		Tokens(token).
+		Logger(logger).
+		TransportWrapper(func(wrapped http.RoundTripper) http.RoundTripper {
+			return &CleanTestTransport{Wrapped: wrapped, Logger: logger}
+		}).
+		BuildContext(context.TODO())
+	if err != nil {
+		t.Fatalf("building connection: %v", err)
+	}
+	return connection
+}
@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"strings"
+)
+
+// CleanupLedger is a copy of the resources created by testing and not cleaned
+// up yet. Soak runs save it in their checkpoint, so a restarted run still
+// cleans up the resources created before the restart.
+type CleanupLedger struct {
+	// Clusters maps the IDs of the clusters to the value of `deprovision`.
+	Clusters      map[string]bool `json:"clusters,omitempty"`
+	Subscriptions []string        `json:"subscriptions,omitempty"`
+	Services      []string        `json:"services,omitempty"`
+	// ClusterResources maps the collections of the cluster resources to
+	// their `<cluster-id>/<id>` paths.
+	ClusterResources map[string][]string `json:"cluster_resources,omitempty"`
+	// Resources maps the tracked collections to the IDs of their resources.
+	Resources map[string][]string `json:"resources,omitempty"`
+}
+
+// Empty reports whether the ledger has no resource to clean up.
+func (l CleanupLedger) Empty() bool {
+	return len(l.Clusters) == 0 && len(l.Subscriptions) == 0 && len(l.Services) == 0 &&
+		len(l.ClusterResources) == 0 && len(l.Resources) == 0
+}
+
+// Ledger returns the resources created by testing and not cleaned up yet.
+func Ledger() CleanupLedger {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	ledger := CleanupLedger{
+		Subscriptions: append([]string{}, createdSubcriptionIDs...),
+		Services:      append([]string{}, createdServiceIDs...),
+	}
+	if len(createdClusterIDs) > 0 {
+		ledger.Clusters = make(map[string]bool, len(createdClusterIDs))
+		for id, deprovision := range createdClusterIDs {
+			ledger.Clusters[id] = deprovision
+		}
+	}
+	for collection, resources := range createdClusterResources {
+		if len(resources) == 0 {
+			continue
+		}
+		if ledger.ClusterResources == nil {
+			ledger.ClusterResources = map[string][]string{}
+		}
+		for _, resource := range resources {
+			ledger.ClusterResources[collection] = append(ledger.ClusterResources[collection], resource.clusterID+"/"+resource.id)
+		}
+	}
+	for collection, ids := range createdResourceIDs {
+		if len(ids) == 0 {
+			continue
+		}
+		if ledger.Resources == nil {
+			ledger.Resources = map[string][]string{}
+		}
+		ledger.Resources[collection] = append([]string{}, ids...)
+	}
+	return ledger
+}
+
+// RestoreLedger adds the resources of the ledger to the ones cleaned up by
+// Cleanup.
+func RestoreLedger(ledger CleanupLedger) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	for id, deprovision := range ledger.Clusters {
+		createdClusterIDs[id] = deprovision
+	}
+	createdSubcriptionIDs = appendMissing(createdSubcriptionIDs, ledger.Subscriptions...)
+	createdServiceIDs = appendMissing(createdServiceIDs, ledger.Services...)
+	for collection, paths := range ledger.ClusterResources {
+		for _, path := range paths {
+			i := strings.Index(path, "/")
+			if i < 0 {
+				continue
+			}
+			resource := clusterResource{clusterID: path[:i], id: path[i+1:]}
+			if !containsClusterResource(createdClusterResources[collection], resource) {
+				createdClusterResources[collection] = append(createdClusterResources[collection], resource)
+			}
+		}
+	}
+	for collection, ids := range ledger.Resources {
+		createdResourceIDs[collection] = appendMissing(createdResourceIDs[collection], ids...)
+	}
+}
+
+func appendMissing(s []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, v := range s {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s = append(s, value)
+		}
+	}
+	return s
+}
+
+func containsClusterResource(resources []clusterResource, resource clusterResource) bool {
+	for _, r := range resources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
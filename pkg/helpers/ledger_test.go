@@ -0,0 +1,54 @@
+package helpers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLedger_RoundTrip(t *testing.T) {
+	defer func() {
+		createdClusterIDs = map[string]bool{}
+		createdSubcriptionIDs = make([]string, 0)
+		createdServiceIDs = make([]string, 0)
+		createdClusterResources = map[string][]clusterResource{}
+		createdResourceIDs = map[string][]string{}
+	}()
+
+	createdClusterIDs = map[string]bool{"c1": true}
+	createdSubcriptionIDs = []string{"s1"}
+	createdServiceIDs = []string{}
+	createdClusterResources = map[string][]clusterResource{MachinePoolsResource: {{clusterID: "c1", id: "mp-1"}}}
+	createdResourceIDs = map[string][]string{RoleBindingsEndpoint: {"rb-1"}}
+
+	b, err := json.Marshal(Ledger())
+	if err != nil {
+		t.Fatalf("encoding ledger: %v", err)
+	}
+	ledger := CleanupLedger{}
+	if err := json.Unmarshal(b, &ledger); err != nil {
+		t.Fatalf("decoding ledger: %v", err)
+	}
+	if ledger.Empty() {
+		t.Fatalf("Ledger() is empty, encoded as %s", b)
+	}
+
+	createdClusterIDs = map[string]bool{}
+	createdSubcriptionIDs = []string{"s1"}
+	createdClusterResources = map[string][]clusterResource{}
+	createdResourceIDs = map[string][]string{}
+	RestoreLedger(ledger)
+
+	if !reflect.DeepEqual(createdClusterIDs, map[string]bool{"c1": true}) {
+		t.Errorf("clusters = %v, want c1", createdClusterIDs)
+	}
+	if !reflect.DeepEqual(createdSubcriptionIDs, []string{"s1"}) {
+		t.Errorf("subscriptions = %v, want [s1] once", createdSubcriptionIDs)
+	}
+	if got := createdClusterResources[MachinePoolsResource]; len(got) != 1 || got[0] != (clusterResource{clusterID: "c1", id: "mp-1"}) {
+		t.Errorf("cluster resources = %v, want c1/mp-1", got)
+	}
+	if got := createdResourceIDs[RoleBindingsEndpoint]; !reflect.DeepEqual(got, []string{"rb-1"}) {
+		t.Errorf("resources = %v, want [rb-1]", got)
+	}
+}
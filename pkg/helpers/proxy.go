@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyConfig holds the HTTP proxy the requests are sent through.
+type ProxyConfig struct {
+	// URL of the proxy, used for both http and https requests. When empty
+	// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
+	URL string
+	// NoProxy lists the hosts, domains, IP addresses and CIDR ranges reached
+	// without the proxy, with the syntax of NO_PROXY.
+	NoProxy []string
+}
+
+// Func returns the function selecting the proxy of each request, to be used
+// as the Proxy of an http.Transport. As with the environment variables,
+// requests to localhost and loopback addresses never use the proxy.
+func (c ProxyConfig) Func() (func(*http.Request) (*url.URL, error), error) {
+	if c.URL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyURL, err := url.Parse(c.URL)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", c.URL)
+	}
+	proxy := (&httpproxy.Config{
+		HTTPProxy:  c.URL,
+		HTTPSProxy: c.URL,
+		NoProxy:    strings.Join(c.NoProxy, ","),
+	}).ProxyFunc()
+	return func(request *http.Request) (*url.URL, error) {
+		return proxy(request.URL)
+	}, nil
+}
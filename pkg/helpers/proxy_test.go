@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProxyConfig_Func(t *testing.T) {
+	proxy, err := ProxyConfig{
+		URL:     "http://proxy.example.com:3128",
+		NoProxy: []string{".internal.example.com", "10.0.0.0/8"},
+	}.Func()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url     string
+		proxied bool
+	}{
+		{"https://api.openshift.com/api", true},
+		{"http://sso.redhat.com/token", true},
+		{"https://es.internal.example.com:9200", false},
+		{"http://10.1.2.3/api", false},
+		{"http://localhost:9200", false},
+	}
+	for _, tt := range tests {
+		request, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		got, err := proxy(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got != nil) != tt.proxied {
+			t.Errorf("proxy of %s = %v, want proxied %v", tt.url, got, tt.proxied)
+		}
+	}
+
+	if _, err := (ProxyConfig{URL: "proxy.example.com:3128"}).Func(); err == nil {
+		t.Errorf("a proxy URL without scheme should fail")
+	}
+}
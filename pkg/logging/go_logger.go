@@ -20,7 +20,6 @@ package logging
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"io"
@@ -148,8 +147,7 @@ func (l *GoLogger) LogFileEnabled() bool {
 // format and arguments.
 func (l *GoLogger) Debug(ctx context.Context, format string, args ...interface{}) {
 	if l.debugEnabled {
-		format = appendHeader(Debug, format)
-		msg := fmt.Sprintf(format, args...)
+		msg := formatMessage(Debug, format, args...)
 		// #nosec G104
 		log.Output(1, msg)
 	}
@@ -159,8 +157,7 @@ func (l *GoLogger) Debug(ctx context.Context, format string, args ...interface{}
 // given format and arguments.
 func (l *GoLogger) Info(ctx context.Context, format string, args ...interface{}) {
 	if l.infoEnabled {
-		format = appendHeader(Info, format)
-		msg := fmt.Sprintf(format, args...)
+		msg := formatMessage(Info, format, args...)
 		// #nosec G104
 		log.Output(1, msg)
 	}
@@ -170,8 +167,7 @@ func (l *GoLogger) Info(ctx context.Context, format string, args ...interface{})
 // format and arguments.
 func (l *GoLogger) Warn(ctx context.Context, format string, args ...interface{}) {
 	if l.warnEnabled {
-		format = appendHeader(Warning, format)
-		msg := fmt.Sprintf(format, args...)
+		msg := formatMessage(Warning, format, args...)
 		// #nosec G104
 		log.Output(1, msg)
 	}
@@ -181,8 +177,7 @@ func (l *GoLogger) Warn(ctx context.Context, format string, args ...interface{})
 // format and arguments.
 func (l *GoLogger) Error(ctx context.Context, format string, args ...interface{}) {
 	if l.errorEnabled {
-		format = appendHeader(Error, format)
-		msg := fmt.Sprintf(format, args...)
+		msg := formatMessage(Error, format, args...)
 		// #nosec G104
 		if l.LogFileEnabled() {
                         mw := io.MultiWriter(log.Writer(), os.Stderr)
@@ -199,8 +194,7 @@ func (l *GoLogger) Error(ctx context.Context, format string, args ...interface{}
 // format and arguments. After that it will os.Exit(1)
 // This level is always enabled
 func (l *GoLogger) Fatal(ctx context.Context, format string, args ...interface{}) {
-	format = appendHeader(Fatal, format)
-	msg := fmt.Sprintf(format, args...)
+	msg := formatMessage(Fatal, format, args...)
 	// #nosec G104
 	if l.LogFileEnabled() {
                 mw := io.MultiWriter(log.Writer(), os.Stderr)
@@ -1,6 +1,10 @@
 package logging
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/redact"
+)
 
 type Level int
 
@@ -30,3 +34,10 @@ func appendHeader(l Level, msg string) string {
 	return fmt.Sprintf("%s - %s", prepend, msg)
 
 }
+
+// formatMessage builds the final log line for the given level, masking any
+// secret (tokens, client secrets, AWS keys...) found in the formatted message.
+// Every logger backend must use it so that no credential reaches the logs.
+func formatMessage(l Level, format string, args ...interface{}) string {
+	return redact.String(fmt.Sprintf(appendHeader(l, format), args...))
+}
@@ -27,3 +27,25 @@ func Test_appendHeader(t *testing.T) {
 		})
 	}
 }
+
+func Test_formatMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"plain", "Executing Test: %s", []interface{}{"create-cluster"}, "Info - Executing Test: create-cluster"},
+		{"aws_keys", "POST /clusters: %s", []interface{}{`{"access_key_id":"ASD7ASFET65FFGHDFFS","secret_access_key":"fjhgsadf6"}`},
+			`Info - POST /clusters: {"access_key_id":"***REDACTED***","secret_access_key":"***REDACTED***"}`},
+		{"client_secret", "token request: %s", []interface{}{"client_id=cloud-services&client_secret=secure-secret"},
+			"Info - token request: client_id=cloud-services&client_secret=***REDACTED***"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatMessage(Info, tt.format, tt.args...); got != tt.want {
+				t.Errorf("formatMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,12 @@ package ocm
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
 	sdk "github.com/openshift-online/ocm-sdk-go"
 	"github.com/spf13/viper"
 )
@@ -27,17 +29,33 @@ func (c Connection) GetConnection() *sdk.Connection {
 }
 
 // BuildConnection build the vegeta connection
-// that is going to be used for testing
-func BuildConnection(gateway, clientID, clientSecret, token string, logger logging.Logger, ctx context.Context) (*sdk.Connection, error) {
-	conn, err := sdk.NewConnectionBuilder().
-		Insecure(true).
+// that is going to be used for testing. Its token requests are recorded by
+// the given recorder and its transports are set up according to transport.
+func BuildConnection(gateway, clientID, clientSecret, token string, transport TransportConfig, recorder *TokenRecorder, logger logging.Logger, ctx context.Context) (*sdk.Connection, error) {
+	transportWrapper, err := transport.Wrapper(gateway)
+	if err != nil {
+		return nil, err
+	}
+	builder := sdk.NewConnectionBuilder()
+	if transport.Headers != nil {
+		headersWrapper, err := transport.Headers.Wrapper(gateway)
+		if err != nil {
+			return nil, err
+		}
+		builder = builder.TransportWrapper(headersWrapper)
+	}
+	conn, err := builder.
+		Insecure(transport.TLS.InsecureSkipVerify).
 		URL(gateway).
+		TokenURL(recorder.tokenURL).
 		Client(clientID, clientSecret).
 		Tokens(token).
 		Logger(logger).
 		TransportWrapper(func(wrapped http.RoundTripper) http.RoundTripper {
 			return &helpers.CleanTestTransport{Wrapped: wrapped, Logger: logger}
 		}).
+		TransportWrapper(recorder.Wrap).
+		TransportWrapper(transportWrapper).
 		BuildContext(ctx)
 	if err != nil {
 		return nil, err
@@ -45,12 +63,59 @@ func BuildConnection(gateway, clientID, clientSecret, token string, logger loggi
 	return conn, nil
 }
 
-func BuildConnections(ctx context.Context, logger logging.Logger) ([]*sdk.Connection, error) {
-	connections := make([]*sdk.Connection, 0)
+// Client is the connection built for an auth entry, along with the identity
+// its results are labelled with and the recorder of its token requests.
+type Client struct {
+	Connection *sdk.Connection
+	Identity   results.Identity
+	Tokens     *TokenRecorder
+	// Pool, when set, sends the requests of the tests across the identities
+	// of the pool. Connection is then the one of the first identity, used by
+	// the handlers for setup and cleanup.
+	Pool *Pool
+	// Headers are the headers added to every request of the client.
+	Headers *RunHeaders
+}
+
+// Transport returns the transport the requests of the tests are sent through.
+func (c *Client) Transport() http.RoundTripper {
+	if c.Pool != nil {
+		return c.Pool
+	}
+	return c.Connection
+}
+
+// BuildConnections builds a client for each configured auth. Auths without a
+// `name` are named after their index, `auth-<idx>`. When `ocm.auths-file` is
+// set one more client sends the requests across its identities, see BuildPool.
+func BuildConnections(ctx context.Context, logger logging.Logger) ([]*Client, error) {
+	return BuildAgentConnections(ctx, logger, WholeShare)
+}
+
+// Share is the part of the identities an agent of a distributed run sends
+// its load with: every one whose index modulo Agents is Agent. When there are
+// fewer identities than agents, every agent uses them all.
+type Share struct {
+	Agent  int
+	Agents int
+}
+
+// WholeShare is the share of a run that is not distributed.
+var WholeShare = Share{Agent: 0, Agents: 1}
+
+func (s Share) includes(index, count int) bool {
+	return s.Agents <= 1 || count < s.Agents || index%s.Agents == s.Agent
+}
+
+// BuildAgentConnections is like BuildConnections, for the auths, and the
+// identities of the pool, in the share of an agent. The clients keep the
+// name and index of their auth in the whole config.
+func BuildAgentConnections(ctx context.Context, logger logging.Logger, share Share) ([]*Client, error) {
+	clients := make([]*Client, 0)
 
 	var auths []interface{}
 	if viper.Sub("ocm") != nil {
-		auths = viper.GetStringMap("ocm")["auths"].([]interface{})
+		auths, _ = viper.GetStringMap("ocm")["auths"].([]interface{})
 	} else if viper.GetString("ocm-token") != "" {
 		auth := map[string]interface{}{
 				"token": viper.GetString("ocm-token"),
@@ -59,7 +124,10 @@ func BuildConnections(ctx context.Context, logger logging.Logger) ([]*sdk.Connec
 			}
 		auths = append(auths, auth)
 	}
-	for _, a := range auths {
+	for i, a := range auths {
+		if !share.includes(i, len(auths)) {
+			continue
+		}
 		m := a.(map[string]interface{})
 		token, ok := m["token"]
 		if !ok {
@@ -73,18 +141,119 @@ func BuildConnections(ctx context.Context, logger logging.Logger) ([]*sdk.Connec
 		if !ok {
 			clientSecret = ""
 		}
+		name, ok := m["name"]
+		if !ok || name == "" {
+			name = fmt.Sprintf("auth-%d", i)
+		}
+		recorder := NewTokenRecorder(viper.GetString("ocm-token-url"))
+		transport := ConfiguredTransport()
 		connection, err := BuildConnection(viper.GetString("gateway-url"),
 			clientID.(string),
 			clientSecret.(string),
 			token.(string),
+			transport,
+			recorder,
 			logger,
 			ctx)
 		if err != nil {
-			logger.Fatal(ctx, "creating api connection: %v - %v", token, err)
+			logger.Fatal(ctx, "creating api connection for auth %d: %v", i, err)
 		}
 		defer helpers.Cleanup(ctx, connection)
 
-		connections = append(connections, connection)
+		identity, err := ResolveIdentity(ctx, connection, fmt.Sprint(name), i)
+		if err != nil {
+			logger.Warn(ctx, "resolving organization of auth %s: %v", identity.Connection, err)
+		} else {
+			logger.Info(ctx, "Auth %s belongs to organization %s (%s)", identity.Connection, identity.Organization, identity.OrganizationID)
+		}
+
+		clients = append(clients, &Client{
+			Connection: connection,
+			Identity:   identity,
+			Tokens:     recorder,
+			Headers:    transport.Headers,
+		})
+	}
+
+	if viper.GetString("ocm.auths-file") != "" {
+		client, err := BuildPool(ctx, viper.GetString("ocm.auths-file"), len(auths), share, logger)
+		if err != nil {
+			return nil, err
+		}
+		defer helpers.Cleanup(ctx, client.Connection)
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// BuildPool builds a client that sends each request through the identities
+// of the credentials file or directory. Up to `ocm.pool.max-connections` are
+// open at the same time, and they are created, then rotated, at no more than
+// `ocm.pool.token-rate`. Its results are labelled as the `pool` identity.
+// Only the identities in the share are used.
+func BuildPool(ctx context.Context, path string, index int, share Share, logger logging.Logger) (*Client, error) {
+	loaded, err := LoadCredentials(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading credentials: %v", err)
+	}
+	credentials := make([]Credential, 0, len(loaded))
+	for i, c := range loaded {
+		if share.includes(i, len(loaded)) {
+			credentials = append(credentials, c)
+		}
+	}
+	maxConnections := viper.GetInt("ocm.pool.max-connections")
+	if maxConnections == 0 {
+		maxConnections = DefaultPoolMaxConnections
+	}
+	rate := viper.GetString("ocm.pool.token-rate")
+	if rate == "" {
+		rate = DefaultPoolTokenRate
+	}
+	tokenRate, err := helpers.ParseRate(rate, 1)
+	if err != nil {
+		return nil, fmt.Errorf("parsing pool token rate: %v", err)
+	}
+
+	recorder := NewTokenRecorder(viper.GetString("ocm-token-url"))
+	transport := ConfiguredTransport()
+	build := func(c Credential) (*sdk.Connection, error) {
+		clientID, clientSecret := c.ClientID, c.ClientSecret
+		if c.Token != "" {
+			clientID, clientSecret = viper.GetString("client.id"), viper.GetString("client.secret")
+		}
+		return BuildConnection(viper.GetString("gateway-url"), clientID, clientSecret, c.Token, transport, recorder, logger, ctx)
+	}
+	connection, err := build(credentials[0])
+	if err != nil {
+		return nil, fmt.Errorf("creating api connection for the pool: %v", err)
+	}
+	pool := NewPool(ctx, credentials, maxConnections, tokenRate, logger, build)
+	logger.Info(ctx, "Pool of %d identities, up to %d connections open, created at %s", pool.Size(), maxConnections, tokenRate.String())
+
+	return &Client{
+		Connection: connection,
+		Identity:   results.Identity{Connection: "pool", ConnectionIndex: index},
+		Tokens:     recorder,
+		Pool:       pool,
+		Headers:    transport.Headers,
+	}, nil
+}
+
+// ResolveIdentity looks up the organization of the account behind the
+// connection, once, to label its results. The returned identity always holds
+// the connection name and index, even on error.
+func ResolveIdentity(ctx context.Context, conn *sdk.Connection, name string, index int) (results.Identity, error) {
+	identity := results.Identity{
+		Connection:      name,
+		ConnectionIndex: index,
+	}
+	resp, err := conn.AccountsMgmt().V1().CurrentAccount().Get().SendContext(ctx)
+	if err != nil {
+		return identity, err
 	}
-	return connections, nil
+	organization := resp.Body().Organization()
+	identity.OrganizationID = organization.ID()
+	identity.Organization = organization.Name()
+	return identity, nil
 }
@@ -0,0 +1,77 @@
+package ocm
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+)
+
+// unsignedToken returns an access token of the subject the SDK accepts without
+// refreshing it.
+func unsignedToken(subject string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	claims := fmt.Sprintf(`{"typ":"Bearer","sub":%q,"exp":%d}`, subject, time.Now().Add(time.Hour).Unix())
+	return encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(claims)) + "."
+}
+
+func TestResolveIdentity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/accounts_mgmt/v1/current_account" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind":"Account","id":"acc","organization":{"kind":"Organization","id":"1a2b","name":"Load Org"}}`))
+	}))
+	defer server.Close()
+
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	conn, err := BuildConnection(server.URL, "", "", unsignedToken("org-admin"), TransportConfig{}, NewTokenRecorder(server.URL+"/token"), logger, ctx)
+	if err != nil {
+		t.Fatalf("BuildConnection() error = %v", err)
+	}
+	defer conn.Close()
+
+	identity, err := ResolveIdentity(ctx, conn, "org-admin", 2)
+	if err != nil {
+		t.Fatalf("ResolveIdentity() error = %v", err)
+	}
+	if identity.Connection != "org-admin" || identity.ConnectionIndex != 2 {
+		t.Errorf("ResolveIdentity() = %+v, want connection org-admin with index 2", identity)
+	}
+	if identity.OrganizationID != "1a2b" || identity.Organization != "Load Org" {
+		t.Errorf("ResolveIdentity() = %+v, want organization 1a2b (Load Org)", identity)
+	}
+
+	server.Close()
+	identity, err = ResolveIdentity(ctx, conn, "org-admin", 2)
+	if err == nil {
+		t.Errorf("ResolveIdentity() should fail when the gateway is down")
+	}
+	if identity.Connection != "org-admin" || identity.OrganizationID != "" {
+		t.Errorf("ResolveIdentity() = %+v, want only the connection name", identity)
+	}
+}
+
+func TestShare_includes(t *testing.T) {
+	share := Share{Agent: 1, Agents: 3}
+	got := []int{}
+	for i := 0; i < 7; i++ {
+		if share.includes(i, 7) {
+			got = append(got, i)
+		}
+	}
+	if fmt.Sprint(got) != "[1 4]" {
+		t.Errorf("share of 7 identities = %v, want [1 4]", got)
+	}
+	if !share.includes(0, 2) || !WholeShare.includes(5, 7) {
+		t.Errorf("every agent should use all the identities when there are fewer of them than agents")
+	}
+}
@@ -0,0 +1,149 @@
+package ocm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// LoadTestIDHeader carries the test ID on every request, so the
+	// synthetic traffic of a run can be filtered in gateway and service logs.
+	LoadTestIDHeader = "X-Load-Test-Id"
+	// RequestIDHeader carries a unique ID per request, prefixed by the test ID.
+	RequestIDHeader = "X-Request-Id"
+	// TraceparentHeader is the W3C trace context header sent with every
+	// request.
+	TraceparentHeader = "Traceparent"
+)
+
+// DefaultOperationIDHeaders are the response headers looked up for the
+// operation ID of a request when `operation-id-headers` is not set.
+var DefaultOperationIDHeaders = []string{"X-Operation-Id"}
+
+// RunHeaders are the headers sent with every request of a connection to the
+// gateway, whether it is sent by an attack, a journey or the setup and
+// cleanup of a test. They are set by the runner once the test ID is known;
+// until then no header is added.
+type RunHeaders struct {
+	mu                 sync.RWMutex
+	testID             string
+	headers            http.Header
+	operationIDHeaders []string
+}
+
+// Set sets the test ID of the run, the configured headers and the response
+// headers looked up for the operation ID.
+func (h *RunHeaders) Set(testID string, headers http.Header, operationIDHeaders []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.testID = testID
+	h.headers = headers
+	h.operationIDHeaders = operationIDHeaders
+}
+
+func (h *RunHeaders) get() (string, http.Header, []string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.testID, h.headers, h.operationIDHeaders
+}
+
+type testHeadersKey struct{}
+
+// WithTestHeaders returns a context whose requests are sent with the headers
+// of a test, which take precedence over the ones of the run.
+func WithTestHeaders(ctx context.Context, headers http.Header) context.Context {
+	return context.WithValue(ctx, testHeadersKey{}, headers)
+}
+
+// Wrapper returns a transport wrapper adding the headers of the run, and of
+// the test of the request context, to the requests sent to the gateway, along
+// with the correlation headers. Headers already set on a request are kept.
+// The trace ID of the traceparent sent, and the first of the operation ID
+// headers returned by the gateway, are added to the response headers so they
+// are stored in the results, see results.TraceIDHeader.
+func (h *RunHeaders) Wrapper(gatewayURL string) (func(http.RoundTripper) http.RoundTripper, error) {
+	gateway, err := url.Parse(gatewayURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing gateway URL: %v", err)
+	}
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &headerTransport{wrapped: wrapped, gatewayHost: gateway.Host, run: h}
+	}, nil
+}
+
+type headerTransport struct {
+	wrapped     http.RoundTripper
+	gatewayHost string
+	run         *RunHeaders
+}
+
+func (t *headerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	testID, headers, operationIDHeaders := t.run.get()
+	if testID == "" || request.URL.Host != t.gatewayHost {
+		return t.wrapped.RoundTrip(request)
+	}
+
+	request = request.Clone(request.Context())
+	if testHeaders, ok := request.Context().Value(testHeadersKey{}).(http.Header); ok {
+		setMissing(request.Header, testHeaders)
+	}
+	setMissing(request.Header, headers)
+	request.Header.Set(LoadTestIDHeader, testID)
+	if request.Header.Get(RequestIDHeader) == "" {
+		request.Header.Set(RequestIDHeader, fmt.Sprintf("%s-%s", testID, uuid.NewV4().String()))
+	}
+	if request.Header.Get(TraceparentHeader) == "" {
+		_, traceparent := NewTraceparent()
+		request.Header.Set(TraceparentHeader, traceparent)
+	}
+
+	response, err := t.wrapped.RoundTrip(request)
+	if err != nil {
+		return response, err
+	}
+	response.Header.Set(results.TraceIDHeader, TraceID(request.Header.Get(TraceparentHeader)))
+	for _, name := range operationIDHeaders {
+		if id := response.Header.Get(name); id != "" {
+			response.Header.Set(results.OperationIDHeader, id)
+			break
+		}
+	}
+	return response, nil
+}
+
+// setMissing adds the headers that are not set yet.
+func setMissing(header, headers http.Header) {
+	for name, values := range headers {
+		if _, ok := header[name]; !ok {
+			header[name] = values
+		}
+	}
+}
+
+// NewTraceparent returns a new random trace ID and the W3C traceparent
+// header of a sampled request of that trace.
+func NewTraceparent() (string, string) {
+	ids := make([]byte, 24)
+	rand.Read(ids)
+	traceID := hex.EncodeToString(ids[:16])
+	spanID := hex.EncodeToString(ids[16:])
+	return traceID, fmt.Sprintf("00-%s-%s-01", traceID, spanID)
+}
+
+// TraceID returns the trace ID of a W3C traceparent header.
+func TraceID(traceparent string) string {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,123 @@
+package ocm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+)
+
+func TestRunHeaders_Wrapper(t *testing.T) {
+	received := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+		w.Header().Set("X-Operation-Id", "op-"+r.Header.Get(RequestIDHeader))
+	}))
+	defer server.Close()
+
+	run := &RunHeaders{}
+	wrapper, err := run.Wrapper(server.URL)
+	if err != nil {
+		t.Fatalf("Wrapper() error = %v", err)
+	}
+	transport := wrapper(http.DefaultTransport)
+	send := func(ctx context.Context, url string) (*http.Request, *http.Response, http.Header) {
+		request, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		request.Header.Set("X-Env", "from-request")
+		response, err := transport.RoundTrip(request)
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		response.Body.Close()
+		return request, response, <-received
+	}
+
+	// No header is added until the run sets its test ID.
+	_, _, got := send(context.TODO(), server.URL)
+	if got.Get(LoadTestIDHeader) != "" || got.Get(TraceparentHeader) != "" {
+		t.Errorf("headers = %v, want none added before Set()", got)
+	}
+
+	runHeaders := http.Header{}
+	runHeaders.Set("X-Team", "perfscale")
+	runHeaders.Set("X-Env", "stage")
+	runHeaders.Set("X-Owner", "run")
+	run.Set("run-1", runHeaders, []string{"X-Trace-Id", "X-Operation-Id"})
+	testHeaders := http.Header{}
+	testHeaders.Set("X-Owner", "test")
+	ctx := WithTestHeaders(context.TODO(), testHeaders)
+
+	requestIDs := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		request, response, got := send(ctx, server.URL)
+		if request.Header.Get(LoadTestIDHeader) != "" {
+			t.Errorf("RoundTrip() modified the request")
+		}
+		if got.Get("X-Team") != "perfscale" || got.Get("X-Env") != "from-request" || got.Get("X-Owner") != "test" {
+			t.Errorf("headers = %v, want the run and test headers without overriding the request ones", got)
+		}
+		if got.Get(LoadTestIDHeader) != "run-1" {
+			t.Errorf("%s = %q, want run-1", LoadTestIDHeader, got.Get(LoadTestIDHeader))
+		}
+		requestID := got.Get(RequestIDHeader)
+		if !strings.HasPrefix(requestID, "run-1-") || requestIDs[requestID] {
+			t.Errorf("%s = %q, want a new ID prefixed by the test ID", RequestIDHeader, requestID)
+		}
+		requestIDs[requestID] = true
+
+		parts := strings.Split(got.Get(TraceparentHeader), "-")
+		if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || parts[3] != "01" {
+			t.Errorf("%s = %q, want a sampled W3C traceparent", TraceparentHeader, got.Get(TraceparentHeader))
+		}
+		if response.Header.Get(results.TraceIDHeader) != parts[1] {
+			t.Errorf("response trace ID = %q, want %q", response.Header.Get(results.TraceIDHeader), parts[1])
+		}
+		if response.Header.Get(results.OperationIDHeader) != "op-"+requestID {
+			t.Errorf("response operation ID = %q, want op-%s", response.Header.Get(results.OperationIDHeader), requestID)
+		}
+	}
+
+	// Requests to other hosts, such as the token requests, are left alone.
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+	}))
+	defer other.Close()
+	_, _, got = send(ctx, other.URL)
+	if got.Get(LoadTestIDHeader) != "" || got.Get("X-Team") != "" {
+		t.Errorf("headers = %v, want none added to requests outside the gateway", got)
+	}
+}
+
+func TestBuildConnection_headers(t *testing.T) {
+	received := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	transport := TransportConfig{Headers: &RunHeaders{}}
+	transport.Headers.Set("run-1", http.Header{}, DefaultOperationIDHeaders)
+	conn, err := BuildConnection(server.URL, "", "", unsignedToken("org-admin"), transport, NewTokenRecorder(server.URL+"/token"), logger, ctx)
+	if err != nil {
+		t.Fatalf("BuildConnection() error = %v", err)
+	}
+	defer conn.Close()
+
+	// The requests sent with the SDK, outside of any attack, get the headers.
+	_, err = conn.Get().Path("/api/clusters_mgmt/v1").SendContext(ctx)
+	if err != nil {
+		t.Fatalf("SendContext() error = %v", err)
+	}
+	got := <-received
+	if got.Get(LoadTestIDHeader) != "run-1" || !strings.HasPrefix(got.Get(RequestIDHeader), "run-1-") || got.Get(TraceparentHeader) == "" {
+		t.Errorf("headers = %v, want the correlation headers of the run", got)
+	}
+}
@@ -0,0 +1,338 @@
+package ocm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// DefaultPoolMaxConnections is the number of connections a pool keeps
+	// open when `ocm.pool.max-connections` is not set.
+	DefaultPoolMaxConnections = 100
+	// DefaultPoolTokenRate is the rate new connections of a pool are created
+	// at when `ocm.pool.token-rate` is not set.
+	DefaultPoolTokenRate = "10/s"
+)
+
+// Credential is one identity of a pool, authenticated either with an offline
+// token or with a client ID and secret.
+type Credential struct {
+	Token        string
+	ClientID     string
+	ClientSecret string
+}
+
+// LoadCredentials reads the credentials of a file, or of every file of a
+// directory, one per line: either an offline token or `<client-id>:<client-secret>`.
+// Empty lines and lines starting with `#` are skipped.
+func LoadCredentials(path string) ([]Credential, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = files[:0]
+		for _, e := range entries {
+			if !e.IsDir() {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+
+	credentials := make([]Credential, 0)
+	for _, f := range files {
+		fileCredentials, err := readCredentials(f)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, fileCredentials...)
+	}
+	if len(credentials) == 0 {
+		return nil, fmt.Errorf("no credentials found in %s", path)
+	}
+	return credentials, nil
+}
+
+func readCredentials(fileName string) ([]Credential, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	credentials := make([]Credential, 0)
+	scanner := bufio.NewScanner(file)
+	// Offline tokens are larger than the default line limit
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if parts := strings.SplitN(line, ":", 2); len(parts) == 2 {
+			credentials = append(credentials, Credential{ClientID: parts[0], ClientSecret: parts[1]})
+		} else {
+			credentials = append(credentials, Credential{Token: line})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", fileName, err)
+	}
+	return credentials, nil
+}
+
+// Pool sends each request through one of its open connections, round robin.
+// Up to maxConnections connections are opened in the background, one per
+// identity, at no more than tokenRate, and each of them gets its token right
+// away. When the pool has more identities than that, the open connections are
+// rotated: at tokenRate, the oldest one is replaced by the connection of the
+// next identity. Requests never wait for a connection to be opened, except
+// for the very first one, so the attack is not slowed down and the load on
+// SSO stays controlled however many identities the pool has.
+type Pool struct {
+	ctx            context.Context
+	logger         logging.Logger
+	credentials    []Credential
+	build          func(Credential) (*sdk.Connection, error)
+	maxConnections int
+	tokenRate      vegeta.Rate
+
+	start     sync.Once
+	ready     chan struct{} // closed once a connection is open, or none can be
+	readyOnce sync.Once
+	stop      chan struct{}
+
+	mu     sync.Mutex
+	open   []*poolEntry
+	next   uint64
+	closed bool
+	err    error
+
+	created uint64
+}
+
+type poolEntry struct {
+	index int
+	conn  *sdk.Connection
+	// inFlight tracks the requests using the connection, so a replaced
+	// connection is closed only once they are done.
+	inFlight sync.WaitGroup
+}
+
+// NewPool returns a pool of the credentials whose connections are built with
+// build. A maxConnections lower than 1 keeps a single connection open and a
+// zero tokenRate does not limit the creation of connections, but then the
+// open connections are not rotated.
+func NewPool(ctx context.Context, credentials []Credential, maxConnections int, tokenRate vegeta.Rate, logger logging.Logger, build func(Credential) (*sdk.Connection, error)) *Pool {
+	if maxConnections < 1 {
+		maxConnections = 1
+	}
+	return &Pool{
+		ctx:            ctx,
+		logger:         logger,
+		credentials:    credentials,
+		build:          build,
+		maxConnections: maxConnections,
+		tokenRate:      tokenRate,
+		ready:          make(chan struct{}),
+		stop:           make(chan struct{}),
+	}
+}
+
+// Size returns the number of identities of the pool.
+func (p *Pool) Size() int {
+	return len(p.credentials)
+}
+
+// Created returns the number of connections created so far.
+func (p *Pool) Created() int {
+	return int(atomic.LoadUint64(&p.created))
+}
+
+// RoundTrip sends the request through the next open connection. The
+// connections start to be opened on the first request.
+func (p *Pool) RoundTrip(request *http.Request) (*http.Response, error) {
+	p.start.Do(func() {
+		go p.rotate()
+	})
+	select {
+	case <-p.ready:
+	case <-request.Context().Done():
+		return nil, request.Context().Err()
+	}
+	entry, err := p.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer entry.inFlight.Done()
+	return entry.conn.RoundTrip(request)
+}
+
+// Close stops the rotation and closes every open connection of the pool.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	for _, entry := range p.open {
+		go p.closeWhenIdle(entry)
+	}
+	p.open = nil
+	p.fail(fmt.Errorf("pool closed"))
+}
+
+// acquire returns the next open connection, marked as in use.
+func (p *Pool) acquire() (*poolEntry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.open) == 0 {
+		return nil, p.err
+	}
+	entry := p.open[p.next%uint64(len(p.open))]
+	p.next++
+	entry.inFlight.Add(1)
+	return entry, nil
+}
+
+// rotate opens the connections of the identities in turn, at no more than
+// tokenRate. Once maxConnections are open, each new connection replaces the
+// oldest one, until the pool is closed. Without more identities than
+// maxConnections, or without tokenRate, every identity is opened once.
+func (p *Pool) rotate() {
+	size := p.maxConnections
+	if size > len(p.credentials) {
+		size = len(p.credentials)
+	}
+	rotating := len(p.credentials) > size && p.tokenRate.Freq > 0
+	var lastErr error
+	defer func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if len(p.open) == 0 {
+			p.fail(fmt.Errorf("no connection of the %d identities could be opened, last error: %v", len(p.credentials), lastErr))
+		}
+	}()
+	began := time.Now()
+	replace, failures := 0, 0
+	for i := 0; rotating || i < len(p.credentials); i++ {
+		if !rotating && p.openConnections() >= size {
+			return
+		}
+		if !p.pace(began, uint64(i)) {
+			if lastErr == nil {
+				lastErr = p.ctx.Err()
+			}
+			return
+		}
+		index := i % len(p.credentials)
+		conn, err := p.connect(index)
+		if err != nil {
+			p.logger.Warn(p.ctx, "Opening connection of identity %d: %v", index, err)
+			lastErr = err
+			failures++
+			if failures >= len(p.credentials) && p.openConnections() == 0 {
+				return
+			}
+			continue
+		}
+		failures = 0
+
+		entry := &poolEntry{index: index, conn: conn}
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			conn.Close()
+			return
+		}
+		if len(p.open) < size {
+			p.open = append(p.open, entry)
+		} else {
+			replaced := p.open[replace]
+			p.open[replace] = entry
+			replace = (replace + 1) % size
+			go p.closeWhenIdle(replaced)
+		}
+		p.mu.Unlock()
+		p.readyOnce.Do(func() {
+			close(p.ready)
+		})
+	}
+}
+
+// fail makes the requests waiting for a connection fail with err. It must be
+// called with mu held.
+func (p *Pool) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+	p.readyOnce.Do(func() {
+		close(p.ready)
+	})
+}
+
+func (p *Pool) openConnections() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.open)
+}
+
+// connect builds the connection of the identity and gets its token.
+func (p *Pool) connect(index int) (*sdk.Connection, error) {
+	atomic.AddUint64(&p.created, 1)
+	conn, err := p.build(p.credentials[index])
+	if err != nil {
+		return nil, err
+	}
+	_, _, err = conn.TokensContext(p.ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	p.logger.Debug(p.ctx, "Opened connection of identity %d", index)
+	return conn, nil
+}
+
+// pace waits for the slot of the n-th connection, returning false when the
+// pool is closed first.
+func (p *Pool) pace(began time.Time, n uint64) bool {
+	wait := time.Duration(0)
+	if p.tokenRate.Freq > 0 {
+		wait, _ = p.tokenRate.Pace(time.Since(began), n)
+	}
+	select {
+	case <-time.After(wait):
+		return true
+	case <-p.stop:
+		return false
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+func (p *Pool) closeWhenIdle(entry *poolEntry) {
+	entry.inFlight.Wait()
+	entry.conn.Close()
+	p.logger.Debug(p.ctx, "Closed connection of identity %d", entry.index)
+}
@@ -0,0 +1,180 @@
+package ocm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestLoadCredentials(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("# users\ntoken-a\n\n  token-b  \n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("client-c:secret:with:colons\n"), 0o644)
+	os.Mkdir(filepath.Join(dir, "nested"), 0o755)
+
+	credentials, err := LoadCredentials(dir)
+	if err != nil {
+		t.Fatalf("LoadCredentials() error = %v", err)
+	}
+	want := []Credential{
+		{Token: "token-a"},
+		{Token: "token-b"},
+		{ClientID: "client-c", ClientSecret: "secret:with:colons"},
+	}
+	if fmt.Sprint(credentials) != fmt.Sprint(want) {
+		t.Errorf("LoadCredentials() = %v, want %v", credentials, want)
+	}
+
+	credentials, err = LoadCredentials(filepath.Join(dir, "a.txt"))
+	if err != nil || len(credentials) != 2 {
+		t.Errorf("LoadCredentials() = %v, %v, want the 2 tokens of the file", credentials, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "empty.txt"), []byte("# nobody\n"), 0o644)
+	if _, err := LoadCredentials(filepath.Join(dir, "empty.txt")); err == nil {
+		t.Errorf("LoadCredentials() should fail without credentials")
+	}
+	if _, err := LoadCredentials(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("LoadCredentials() should fail when the path does not exist")
+	}
+}
+
+func TestPool(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = map[string]int{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Header.Get("Authorization")]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	credentials := []Credential{
+		{Token: "broken"},
+		{Token: unsignedToken("user-1")},
+		{Token: unsignedToken("user-2")},
+		{Token: unsignedToken("user-3")},
+	}
+	built := map[int]int{}
+	build := func(c Credential) (*sdk.Connection, error) {
+		for i := range credentials {
+			if credentials[i] == c {
+				mu.Lock()
+				built[i]++
+				mu.Unlock()
+			}
+		}
+		return BuildConnection(server.URL, "", "", c.Token, TransportConfig{}, NewTokenRecorder(server.URL+"/token"), logger, ctx)
+	}
+	pool := NewPool(ctx, credentials, 2, vegeta.Rate{}, logger, build)
+	defer pool.Close()
+
+	for i := 0; i < 8; i++ {
+		request, _ := http.NewRequest(http.MethodGet, "/api/clusters_mgmt/v1/clusters", nil)
+		response, err := pool.RoundTrip(request)
+		if err != nil {
+			t.Fatalf("request %d error = %v", i, err)
+		}
+		response.Body.Close()
+	}
+
+	// Without a token rate the connections are not rotated: the broken
+	// identity is skipped and the next 2 identities get every request.
+	for i, want := range []int{0, 4, 4, 0} {
+		if got := requests["Bearer "+credentials[i].Token]; got != want {
+			t.Errorf("requests of identity %d = %d, want %d", i, got, want)
+		}
+	}
+	for i, want := range []int{1, 1, 1, 0} {
+		if built[i] != want {
+			t.Errorf("connections built for identity %d = %d, want %d", i, built[i], want)
+		}
+	}
+	if pool.Created() != 3 {
+		t.Errorf("Created() = %d, want 3", pool.Created())
+	}
+}
+
+func TestPool_Rotation(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = map[string]int{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Header.Get("Authorization")]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	credentials := make([]Credential, 50)
+	for i := range credentials {
+		credentials[i] = Credential{Token: unsignedToken(fmt.Sprintf("user-%d", i))}
+	}
+	build := func(c Credential) (*sdk.Connection, error) {
+		return BuildConnection(server.URL, "", "", c.Token, TransportConfig{}, NewTokenRecorder(server.URL+"/token"), logger, ctx)
+	}
+	rate := vegeta.Rate{Freq: 100, Per: time.Second}
+	pool := NewPool(ctx, credentials, 5, rate, logger, build)
+
+	began := time.Now()
+	for i := 0; i < 200; i++ {
+		request, _ := http.NewRequest(http.MethodGet, "/api/clusters_mgmt/v1/clusters", nil)
+		response, err := pool.RoundTrip(request)
+		if err != nil {
+			t.Fatalf("request %d error = %v", i, err)
+		}
+		response.Body.Close()
+		if open := pool.openConnections(); open > 5 {
+			t.Fatalf("open connections = %d, want at most 5", open)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	elapsed := time.Since(began)
+	pool.Close()
+
+	// Connections are created at the token rate, not for every request of
+	// an identity missing from the open ones.
+	if max := int(elapsed.Seconds()*float64(rate.Freq)) + 2; pool.Created() > max {
+		t.Errorf("Created() = %d for 200 requests in %s, want at most %d", pool.Created(), elapsed, max)
+	}
+	if len(requests) <= 5 {
+		t.Errorf("identities used = %d, want the open connections rotated", len(requests))
+	}
+}
+
+func TestPool_NoConnection(t *testing.T) {
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	build := func(c Credential) (*sdk.Connection, error) {
+		return nil, fmt.Errorf("invalid credential")
+	}
+	pool := NewPool(ctx, []Credential{{Token: "a"}, {Token: "b"}}, 1, vegeta.Rate{Freq: 100, Per: time.Second}, logger, build)
+	defer pool.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, "/api/clusters_mgmt/v1/clusters", nil)
+	if _, err := pool.RoundTrip(request); err == nil || !strings.Contains(err.Error(), "invalid credential") {
+		t.Errorf("RoundTrip() error = %v, want the error opening the connections", err)
+	}
+}
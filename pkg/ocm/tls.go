@@ -0,0 +1,111 @@
+package ocm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/spf13/viper"
+)
+
+// TLSConfig holds the TLS settings of the connections to the gateway. The
+// SSO token URL keeps the system trusted CAs and is never sent the client
+// certificate.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of the CAs trusted for the gateway, instead of
+	// the system ones.
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key presented
+	// to gateways requiring mTLS.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name sent as SNI and verified in the gateway
+	// certificate, by default the host of the gateway URL.
+	ServerName string
+	// MinVersion is the minimum TLS version, 1.0 to 1.3. Defaults to 1.2.
+	MinVersion string
+	// InsecureSkipVerify disables the verification of certificates.
+	InsecureSkipVerify bool
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ConfiguredTLS returns the TLS settings of the `tls` section of the config.
+func ConfiguredTLS() TLSConfig {
+	return TLSConfig{
+		CAFile:             viper.GetString("tls.ca-file"),
+		CertFile:           viper.GetString("tls.cert-file"),
+		KeyFile:            viper.GetString("tls.key-file"),
+		ServerName:         viper.GetString("tls.server-name"),
+		MinVersion:         viper.GetString("tls.min-version"),
+		InsecureSkipVerify: viper.GetBool("tls.insecure-skip-verify"),
+	}
+}
+
+// Wrapper returns a transport wrapper that applies the settings to the TLS
+// configuration of the transports the SDK creates for the gateway. It must be
+// the last wrapper of the connection, so it gets the SDK transport itself.
+// The files are loaded once, here.
+func (c TLSConfig) Wrapper(gatewayURL string) (func(http.RoundTripper) http.RoundTripper, error) {
+	gateway, err := url.Parse(gatewayURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing gateway URL: %v", err)
+	}
+	minVersion := uint16(tls.VersionTLS12)
+	if c.MinVersion != "" {
+		v, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown minimum TLS version %q (1.0, 1.1, 1.2, 1.3)", c.MinVersion)
+		}
+		minVersion = v
+	}
+	var rootCAs *x509.CertPool
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %v", err)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", c.CAFile)
+		}
+	}
+	var certificates []tls.Certificate
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %v", err)
+		}
+		certificates = []tls.Certificate{cert}
+	}
+
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		transport, ok := wrapped.(*http.Transport)
+		if !ok || transport.TLSClientConfig == nil {
+			return wrapped
+		}
+		config := transport.TLSClientConfig.Clone()
+		config.MinVersion = minVersion
+		// The SDK sets the server name to the host of each URL, the token
+		// URL transport is left with the system CAs and no certificate.
+		if config.ServerName == gateway.Hostname() {
+			if rootCAs != nil {
+				config.RootCAs = rootCAs
+			}
+			config.Certificates = certificates
+			if c.ServerName != "" {
+				config.ServerName = c.ServerName
+			}
+		}
+		transport.TLSClientConfig = config
+		return transport
+	}, nil
+}
@@ -0,0 +1,156 @@
+package ocm
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+)
+
+// writeClientCertificate writes a self signed client certificate and its key
+// to dir and returns their paths and the certificate.
+func writeClientCertificate(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "load-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile, cert
+}
+
+func TestBuildConnection_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCertificate(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	serverNames := make(chan string, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case serverNames <- r.TLS.ServerName:
+		default:
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  clientCAs,
+		MaxVersion: tls.VersionTLS12,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600)
+
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	send := func(config TLSConfig) error {
+		conn, err := BuildConnection(server.URL, "", "", unsignedToken("org-admin"), TransportConfig{TLS: config}, NewTokenRecorder(server.URL+"/token"), logger, ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, err = conn.Get().Path("/api/clusters_mgmt/v1").SendContext(ctx)
+		return err
+	}
+
+	tests := []struct {
+		name       string
+		config     TLSConfig
+		wantErr    bool
+		serverName string
+	}{
+		{"UnknownCA", TLSConfig{}, true, ""},
+		{"CABundle", TLSConfig{CAFile: caFile}, false, ""},
+		{"InsecureSkipVerify", TLSConfig{InsecureSkipVerify: true}, false, ""},
+		{"ClientCertificate", TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, false, ""},
+		{"ServerName", TLSConfig{CAFile: caFile, ServerName: "example.com"}, false, "example.com"},
+		{"WrongServerName", TLSConfig{CAFile: caFile, ServerName: "gateway.local"}, true, ""},
+		{"MinVersionAboveServer", TLSConfig{CAFile: caFile, MinVersion: "1.3"}, true, ""},
+		{"UnknownMinVersion", TLSConfig{MinVersion: "2.0"}, true, ""},
+		{"MissingCABundle", TLSConfig{CAFile: filepath.Join(dir, "missing.crt")}, true, ""},
+		{"KeyWithoutCertificate", TLSConfig{KeyFile: keyFile}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := send(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("request error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.serverName != "" {
+				if got := <-serverNames; got != tt.serverName {
+					t.Errorf("SNI = %q, want %q", got, tt.serverName)
+				}
+			}
+			select {
+			case <-serverNames:
+			default:
+			}
+		})
+	}
+}
+
+func TestTLSConfig_WrapperRequiresClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCertificate(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	for _, config := range []TLSConfig{
+		{InsecureSkipVerify: true},
+		{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile},
+	} {
+		conn, err := BuildConnection(server.URL, "", "", unsignedToken("org-admin"), TransportConfig{TLS: config}, NewTokenRecorder(server.URL+"/token"), logger, ctx)
+		if err != nil {
+			t.Fatalf("BuildConnection() error = %v", err)
+		}
+		_, err = conn.Get().Path("/api/clusters_mgmt/v1").SendContext(ctx)
+		conn.Close()
+		if withCert := config.CertFile != ""; (err == nil) != withCert {
+			t.Errorf("request with client certificate %v error = %v", withCert, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package ocm
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// TokenAttack is the attack name of the token requests, used as the test name
+// of their result files and summary.
+const TokenAttack = "sso-token"
+
+// TokenRecorder records the requests a connection sends to the token URL to
+// get or refresh its access token, so a slow SSO can be told apart from a slow
+// gateway. Only timing, status and errors are recorded, never the bodies.
+type TokenRecorder struct {
+	tokenURL string
+
+	mu      sync.Mutex
+	results []*vegeta.Result
+}
+
+// NewTokenRecorder returns a recorder for the token URL, the SDK default one
+// when empty.
+func NewTokenRecorder(tokenURL string) *TokenRecorder {
+	if tokenURL == "" {
+		tokenURL = sdk.DefaultTokenURL
+	}
+	return &TokenRecorder{tokenURL: strings.TrimSuffix(tokenURL, "/")}
+}
+
+// Wrap wraps the transport of a connection. The SDK applies it to both the
+// token requests and the API requests, only the former are recorded.
+func (r *TokenRecorder) Wrap(wrapped http.RoundTripper) http.RoundTripper {
+	return &tokenTransport{wrapped: wrapped, recorder: r}
+}
+
+// Drain returns the token requests recorded since the last call and forgets
+// them, so they are not held in memory once written. Their sequence numbers
+// start at 0 at every call.
+func (r *TokenRecorder) Drain() []*vegeta.Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := r.results
+	r.results = nil
+	return results
+}
+
+func (r *TokenRecorder) isTokenRequest(request *http.Request) bool {
+	u := *request.URL
+	u.RawQuery = ""
+	return strings.TrimSuffix(u.String(), "/") == r.tokenURL
+}
+
+func (r *TokenRecorder) record(request *http.Request, began time.Time, response *http.Response, err error) {
+	res := &vegeta.Result{
+		Attack:    TokenAttack,
+		Timestamp: began,
+		Latency:   time.Since(began),
+		Method:    request.Method,
+		URL:       r.tokenURL,
+	}
+	if request.ContentLength > 0 {
+		res.BytesOut = uint64(request.ContentLength)
+	}
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Code = uint16(response.StatusCode)
+		if response.ContentLength > 0 {
+			res.BytesIn = uint64(response.ContentLength)
+		}
+		// Same as vegeta, non successful codes are errors
+		if res.Code < 200 || res.Code >= 400 {
+			res.Error = response.Status
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res.Seq = uint64(len(r.results))
+	r.results = append(r.results, res)
+}
+
+type tokenTransport struct {
+	wrapped  http.RoundTripper
+	recorder *TokenRecorder
+}
+
+func (t *tokenTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !t.recorder.isTokenRequest(request) {
+		return t.wrapped.RoundTrip(request)
+	}
+	began := time.Now()
+	response, err := t.wrapped.RoundTrip(request)
+	t.recorder.record(request, began, response, err)
+	return response, err
+}
@@ -0,0 +1,72 @@
+package ocm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTokenRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.URL.Query().Get("fail") != "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token":"secret"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	recorder := NewTokenRecorder(server.URL + "/token/")
+	client := &http.Client{Transport: recorder.Wrap(http.DefaultTransport)}
+
+	for _, url := range []string{server.URL + "/api/clusters_mgmt/v1/clusters", server.URL + "/token", server.URL + "/token?fail=1"} {
+		response, err := client.Post(url, "application/x-www-form-urlencoded", strings.NewReader("grant_type=refresh_token"))
+		if err != nil {
+			t.Fatalf("POST %s: %v", url, err)
+		}
+		response.Body.Close()
+	}
+
+	results := recorder.Drain()
+	if len(results) != 2 {
+		t.Fatalf("recorded %d requests, want only the 2 token requests", len(results))
+	}
+	for i, res := range results {
+		if res.Attack != TokenAttack || res.Method != http.MethodPost || res.URL != server.URL+"/token" || res.Seq != uint64(i) {
+			t.Errorf("result %d = %+v", i, res)
+		}
+		if len(res.Body) != 0 {
+			t.Errorf("result %d recorded the response body", i)
+		}
+	}
+	if results[0].Code != http.StatusOK || results[0].Error != "" {
+		t.Errorf("successful token request recorded as %d %q", results[0].Code, results[0].Error)
+	}
+	if results[1].Code != http.StatusUnauthorized || results[1].Error == "" {
+		t.Errorf("failed token request recorded as %d %q", results[1].Code, results[1].Error)
+	}
+
+	if drained := recorder.Drain(); len(drained) != 0 {
+		t.Errorf("Drain() = %d requests, want none after draining", len(drained))
+	}
+	response, err := client.Post(server.URL+"/token", "application/x-www-form-urlencoded", strings.NewReader("grant_type=refresh_token"))
+	if err != nil {
+		t.Fatalf("POST token: %v", err)
+	}
+	response.Body.Close()
+	if drained := recorder.Drain(); len(drained) != 1 || drained[0].Seq != 0 {
+		t.Errorf("Drain() = %+v, want the request recorded since the last call", drained)
+	}
+}
+
+func TestNewTokenRecorder_DefaultURL(t *testing.T) {
+	if recorder := NewTokenRecorder(""); recorder.tokenURL == "" {
+		t.Errorf("NewTokenRecorder(\"\") should use the default token URL")
+	}
+}
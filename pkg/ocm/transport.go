@@ -0,0 +1,89 @@
+package ocm
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/spf13/viper"
+)
+
+// TransportConfig holds the settings of the transports of a connection. They
+// are connection-wide: the connections are built once and shared by every
+// test, so these settings are only read from the global config and can't be
+// set per test.
+type TransportConfig struct {
+	TLS TLSConfig
+	// DisableKeepAlives opens a new TCP connection for every request.
+	DisableKeepAlives bool
+	// DisableHTTP2 sticks to HTTP/1.1 even when the server supports HTTP/2.
+	DisableHTTP2 bool
+	// LocalAddress is the local IP address the requests are sent from.
+	LocalAddress string
+	// Proxy is the HTTP proxy of both the API and the token requests.
+	Proxy helpers.ProxyConfig
+	// Headers, when set, are added to every request to the gateway.
+	Headers *RunHeaders
+}
+
+// ConfiguredTransport returns the transport settings of the global config.
+func ConfiguredTransport() TransportConfig {
+	return TransportConfig{
+		TLS:               ConfiguredTLS(),
+		DisableKeepAlives: viper.IsSet("keep-alive") && !viper.GetBool("keep-alive"),
+		DisableHTTP2:      viper.IsSet("http2") && !viper.GetBool("http2"),
+		LocalAddress:      viper.GetString("local-address"),
+		Proxy: helpers.ProxyConfig{
+			URL:     viper.GetString("http-proxy"),
+			NoProxy: viper.GetStringSlice("no-proxy"),
+		},
+		Headers: &RunHeaders{},
+	}
+}
+
+// Wrapper returns a transport wrapper that applies the settings to the
+// transports the SDK creates. Like TLSConfig.Wrapper, it must be the last
+// wrapper of the connection.
+func (c TransportConfig) Wrapper(gatewayURL string) (func(http.RoundTripper) http.RoundTripper, error) {
+	tlsWrapper, err := c.TLS.Wrapper(gatewayURL)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := c.Proxy.Func()
+	if err != nil {
+		return nil, err
+	}
+	var dialer *net.Dialer
+	if c.LocalAddress != "" {
+		ip := net.ParseIP(c.LocalAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid local address %q", c.LocalAddress)
+		}
+		// Same timeouts as the default transport of net/http
+		dialer = &net.Dialer{
+			LocalAddr: &net.TCPAddr{IP: ip},
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+	}
+
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		if transport, ok := wrapped.(*http.Transport); ok {
+			transport.Proxy = proxy
+			if c.DisableKeepAlives {
+				transport.DisableKeepAlives = true
+			}
+			if c.DisableHTTP2 {
+				transport.ForceAttemptHTTP2 = false
+				transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+			}
+			if dialer != nil {
+				transport.DialContext = dialer.DialContext
+			}
+		}
+		return tlsWrapper(wrapped)
+	}, nil
+}
@@ -0,0 +1,99 @@
+package ocm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+)
+
+func TestTransportConfig_Wrapper(t *testing.T) {
+	type request struct {
+		proto      int
+		close      bool
+		remoteAddr string
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- request{proto: r.ProtoMajor, close: r.Close, remoteAddr: r.RemoteAddr}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	send := func(transport TransportConfig) (request, error) {
+		transport.TLS.InsecureSkipVerify = true
+		conn, err := BuildConnection(server.URL, "", "", unsignedToken("org-admin"), transport, NewTokenRecorder(server.URL+"/token"), logger, ctx)
+		if err != nil {
+			return request{}, err
+		}
+		defer conn.Close()
+		_, err = conn.Get().Path("/api/clusters_mgmt/v1").SendContext(ctx)
+		if err != nil {
+			return request{}, err
+		}
+		return <-requests, nil
+	}
+
+	got, err := send(TransportConfig{})
+	if err != nil || got.proto != 2 || got.close {
+		t.Errorf("default request = %+v, %v, want HTTP/2 with keep-alive", got, err)
+	}
+	got, err = send(TransportConfig{DisableHTTP2: true, DisableKeepAlives: true})
+	if err != nil || got.proto != 1 || !got.close {
+		t.Errorf("tuned request = %+v, %v, want HTTP/1.1 without keep-alive", got, err)
+	}
+	got, err = send(TransportConfig{DisableHTTP2: true, LocalAddress: "127.0.0.1"})
+	if err != nil || !strings.HasPrefix(got.remoteAddr, "127.0.0.1:") {
+		t.Errorf("request from local address = %+v, %v", got, err)
+	}
+	if _, err := send(TransportConfig{LocalAddress: "localhost"}); err == nil {
+		t.Errorf("an invalid local address should fail")
+	}
+}
+
+func TestTransportConfig_WrapperProxy(t *testing.T) {
+	hosts := make(chan string, 2)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.URL.Host
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/token" {
+			fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":300}`, unsignedToken("org-admin"))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer proxy.Close()
+
+	ctx := context.TODO()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	transport := TransportConfig{Proxy: helpers.ProxyConfig{URL: proxy.URL}}
+	conn, err := BuildConnection("http://gateway.test", "client", "secret", "", transport, NewTokenRecorder("http://sso.test/token"), logger, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.Get().Path("/api/clusters_mgmt/v1").SendContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"sso.test", "gateway.test"} {
+		if got := <-hosts; got != want {
+			t.Errorf("proxied request to %q, want %q", got, want)
+		}
+	}
+
+	transport.Proxy.URL = "://proxy"
+	if _, err := BuildConnection("http://gateway.test", "client", "secret", "", transport, NewTokenRecorder("http://sso.test/token"), logger, ctx); err == nil {
+		t.Errorf("an invalid proxy URL should fail")
+	}
+}
@@ -1,6 +1,9 @@
 package ramping
 
-import "math"
+import (
+	"fmt"
+	"math"
+)
 
 type Exponential struct {
 	startRate   int
@@ -39,3 +42,7 @@ func (e *Exponential) GetSteps() int {
 func (e *Exponential) GetType() string {
 	return "Exponential ramp"
 }
+
+func (e *Exponential) GetProfile() string {
+	return fmt.Sprintf("exponential:%d-%d/%d", e.startRate, e.endRate, e.steps)
+}
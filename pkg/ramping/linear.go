@@ -1,6 +1,7 @@
 package ramping
 
 import (
+	"fmt"
 	"math"
 )
 
@@ -43,3 +44,7 @@ func (l *Linear) GetSteps() int {
 func (l *Linear) GetType() string {
 	return "Linear ramp"
 }
+
+func (l *Linear) GetProfile() string {
+	return fmt.Sprintf("linear:%d-%d/%d", l.startRate, l.endRate, l.steps)
+}
@@ -11,6 +11,8 @@ type Ramper interface {
 	NextRate() int
 	GetSteps() int
 	GetType() string
+	// GetProfile describes the whole ramp, e.g. to identify runs with the same load.
+	GetProfile() string
 }
 
 // NewRampingService when using None ramping
@@ -26,3 +26,21 @@ func TestNewRampingService(t *testing.T) {
 		})
 	}
 }
+
+func TestGetProfile(t *testing.T) {
+	tests := []struct {
+		name   string
+		ramper Ramper
+		want   string
+	}{
+		{"Linear", NewRampingService(LinearRamp, 1, 50, 6), "linear:1-50/6"},
+		{"Exponential", NewRampingService(ExponentialRamp, 2, 10, 4), "exponential:2-10/4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ramper.GetProfile(); got != tt.want {
+				t.Errorf("GetProfile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package redact
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Mask is the value used to replace any secret found by this package.
+const Mask = "***REDACTED***"
+
+// sensitiveHeaders are the (canonical) header names whose values are always masked.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":        true,
+	"Proxy-Authorization":  true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"X-Auth-Token":         true,
+	"X-Api-Key":            true,
+	"X-Amz-Security-Token": true,
+}
+
+// sensitiveFields are the names of JSON, YAML or form fields holding secrets.
+// This covers the OCM tokens, the SSO client credentials and the AWS keys sent
+// when creating CCS clusters.
+var sensitiveFields = []string{
+	"access_key_id",
+	"secret_access_key",
+	"access-key",
+	"secret-access-key",
+	"aws-access-key",
+	"aws-access-secret",
+	"ocm-token",
+	"access_token",
+	"refresh_token",
+	"id_token",
+	"offline_token",
+	"authorization_token",
+	"client_secret",
+	"client-secret",
+	"password",
+	"elastic-password",
+}
+
+// ambiguousFields are only treated as secrets when they are JSON keys, as
+// they are common words in free text such as error messages.
+var ambiguousFields = []string{
+	"token",
+	"auth",
+}
+
+var (
+	fields = strings.Join(sensitiveFields, "|")
+
+	// "secret_access_key": "value"
+	jsonFieldRegexp = regexp.MustCompile(`("(?:` + fields + "|" + strings.Join(ambiguousFields, "|") + `)"\s*:\s*")(?:[^"\\]|\\.)*(")`)
+	// secret_access_key=value or secret-access-key: value
+	plainFieldRegexp = regexp.MustCompile(`(?i)(\b(?:` + fields + `)\s*[=:]\s*)[^\s"',&;}\]]+`)
+	// Authorization: Bearer value
+	bearerRegexp = regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+	// JSON Web Tokens, used for access and offline tokens
+	jwtRegexp = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// AWS access key IDs
+	awsKeyRegexp = regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)
+)
+
+// String returns s with every known secret field and pattern masked.
+func String(s string) string {
+	if s == "" {
+		return s
+	}
+	s = jsonFieldRegexp.ReplaceAllString(s, "${1}"+Mask+"${2}")
+	s = plainFieldRegexp.ReplaceAllString(s, "${1}"+Mask)
+	s = bearerRegexp.ReplaceAllString(s, "${1}"+Mask)
+	s = jwtRegexp.ReplaceAllString(s, Mask)
+	s = awsKeyRegexp.ReplaceAllString(s, Mask)
+	return s
+}
+
+// Bytes is the same as String but for byte slices.
+func Bytes(b []byte) []byte {
+	if len(b) == 0 {
+		return b
+	}
+	return []byte(String(string(b)))
+}
+
+// Header returns a copy of h where the values of sensitive headers are masked
+// and the remaining values are passed through String.
+func Header(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	redacted := make(http.Header, len(h))
+	for key, values := range h {
+		masked := make([]string, len(values))
+		for i, v := range values {
+			if sensitiveHeaders[http.CanonicalHeaderKey(key)] {
+				masked[i] = Mask
+			} else {
+				masked[i] = String(v)
+			}
+		}
+		redacted[key] = masked
+	}
+	return redacted
+}
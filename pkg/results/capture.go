@@ -0,0 +1,112 @@
+package results
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/redact"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// CaptureMode defines which response bodies are kept in the results.
+type CaptureMode string
+
+const (
+	CaptureAll      CaptureMode = "all"      // keep every body
+	CaptureNone     CaptureMode = "none"     // drop every body
+	CaptureErrors   CaptureMode = "errors"   // keep only bodies of failed requests
+	CaptureTruncate CaptureMode = "truncate" // keep every body, truncated to MaxBytes
+	CaptureSample   CaptureMode = "sample"   // keep SamplePercent % of the bodies
+
+	// DefaultTruncateBytes is used when truncate mode has no size configured.
+	DefaultTruncateBytes = 1024
+)
+
+// ParseCaptureMode validates the mode coming from the configuration.
+// An empty string means CaptureAll. An unknown mode returns CaptureErrors with
+// the error, so a typo doesn't store every body.
+func ParseCaptureMode(mode string) (CaptureMode, error) {
+	switch CaptureMode(mode) {
+	case "":
+		return CaptureAll, nil
+	case CaptureAll, CaptureNone, CaptureErrors, CaptureTruncate, CaptureSample:
+		return CaptureMode(mode), nil
+	}
+	return CaptureErrors, fmt.Errorf("unknown body capture mode %q (all, none, errors, truncate, sample)", mode)
+}
+
+// CapturePolicy decides what part of each result is stored.
+// Secrets are always redacted from bodies, headers and errors regardless of
+// the policy.
+type CapturePolicy struct {
+	Mode CaptureMode
+	// MaxBytes caps the size of every kept body when greater than 0.
+	MaxBytes int
+	// SamplePercent is the percentage of bodies kept in CaptureSample mode.
+	SamplePercent int
+	// HeaderAllowList are the only response headers kept. Empty keeps all.
+	HeaderAllowList []string
+}
+
+// Apply modifies the result according to the policy.
+func (p CapturePolicy) Apply(res *vegeta.Result) {
+	res.Error = redact.String(res.Error)
+	res.Headers = redact.Header(p.filterHeaders(res.Headers))
+
+	if !p.keepBody(res) {
+		res.Body = nil
+		return
+	}
+	res.Body = redact.Bytes(res.Body)
+	maxBytes := p.MaxBytes
+	if p.Mode == CaptureTruncate && maxBytes <= 0 {
+		maxBytes = DefaultTruncateBytes
+	}
+	if maxBytes > 0 && len(res.Body) > maxBytes {
+		res.Body = res.Body[:maxBytes]
+	}
+}
+
+func (p CapturePolicy) keepBody(res *vegeta.Result) bool {
+	switch p.Mode {
+	case CaptureNone:
+		return false
+	case CaptureErrors:
+		return IsError(res)
+	case CaptureSample:
+		return rand.Intn(100) < p.SamplePercent
+	}
+	return true
+}
+
+// filterHeaders keeps the allowed headers, plus the trace and operation IDs,
+// the backoff and the rate limit headers which are always kept.
+func (p CapturePolicy) filterHeaders(h http.Header) http.Header {
+	if h == nil || len(p.HeaderAllowList) == 0 {
+		return h
+	}
+	filtered := http.Header{}
+	kept := append([]string{TraceIDHeader, OperationIDHeader, BackoffHeader}, RateLimitHeaders...)
+	for _, name := range append(kept, p.HeaderAllowList...) {
+		key := http.CanonicalHeaderKey(name)
+		if values, ok := h[key]; ok {
+			filtered[key] = values
+		}
+	}
+	return filtered
+}
+
+// IsError reports whether the result should be considered a failed request.
+func IsError(res *vegeta.Result) bool {
+	return res.Error != "" || res.Code < 200 || res.Code >= 400
+}
+
+// NewCaptureEncoder wraps enc so that every result goes through the policy
+// before being written.
+func NewCaptureEncoder(enc vegeta.Encoder, policy CapturePolicy) vegeta.Encoder {
+	return func(res *vegeta.Result) error {
+		policy.Apply(res)
+		return enc.Encode(res)
+	}
+}
@@ -0,0 +1,93 @@
+package results
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/redact"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestParseCaptureMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    CaptureMode
+		wantErr bool
+	}{
+		{"", CaptureAll, false},
+		{"all", CaptureAll, false},
+		{"none", CaptureNone, false},
+		{"errors", CaptureErrors, false},
+		{"truncate", CaptureTruncate, false},
+		{"sample", CaptureSample, false},
+		{"everything", CaptureErrors, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			got, err := ParseCaptureMode(tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCaptureMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCaptureMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapturePolicy_Apply(t *testing.T) {
+	body := []byte(strings.Repeat("a", 2048))
+	tests := []struct {
+		name     string
+		policy   CapturePolicy
+		code     uint16
+		wantBody int
+	}{
+		{"all", CapturePolicy{Mode: CaptureAll}, 200, 2048},
+		{"all_capped", CapturePolicy{Mode: CaptureAll, MaxBytes: 10}, 200, 10},
+		{"none", CapturePolicy{Mode: CaptureNone}, 500, 0},
+		{"errors_ok", CapturePolicy{Mode: CaptureErrors}, 200, 0},
+		{"errors_failed", CapturePolicy{Mode: CaptureErrors}, 500, 2048},
+		{"truncate_default", CapturePolicy{Mode: CaptureTruncate}, 200, DefaultTruncateBytes},
+		{"truncate", CapturePolicy{Mode: CaptureTruncate, MaxBytes: 100}, 200, 100},
+		{"sample_none", CapturePolicy{Mode: CaptureSample, SamplePercent: 0}, 200, 0},
+		{"sample_all", CapturePolicy{Mode: CaptureSample, SamplePercent: 100}, 200, 2048},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &vegeta.Result{Code: tt.code, Body: body}
+			tt.policy.Apply(res)
+			if len(res.Body) != tt.wantBody {
+				t.Errorf("Apply() body length = %d, want %d", len(res.Body), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCapturePolicy_ApplyRedacts(t *testing.T) {
+	res := &vegeta.Result{
+		Code:  201,
+		Body:  []byte(`{"aws":{"secret_access_key":"fjhgsadf6dfghdfgdsdf"}}`),
+		Error: "",
+		Headers: http.Header{
+			"Authorization": []string{"Bearer xyz"},
+			"Content-Type":  []string{"application/json"},
+			"Date":          []string{"today"},
+		},
+	}
+	CapturePolicy{Mode: CaptureAll, HeaderAllowList: []string{"authorization", "content-type"}}.Apply(res)
+	if bytes.Contains(res.Body, []byte("fjhgsadf6dfghdfgdsdf")) {
+		t.Errorf("Apply() body = %s, secret not redacted", res.Body)
+	}
+	if res.Headers.Get("Authorization") != redact.Mask {
+		t.Errorf("Apply() headers = %v, Authorization not redacted", res.Headers)
+	}
+	if _, ok := res.Headers["Date"]; ok {
+		t.Errorf("Apply() headers = %v, Date is not in the allow list", res.Headers)
+	}
+	if res.Headers.Get("Content-Type") != "application/json" {
+		t.Errorf("Apply() headers = %v, Content-Type is in the allow list", res.Headers)
+	}
+}
@@ -0,0 +1,204 @@
+package results
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Tolerances are the maximum changes accepted between a baseline and a
+// candidate before flagging a regression.
+type Tolerances struct {
+	// Latency is the allowed increase of each latency percentile, in percent.
+	Latency float64
+	// Throughput is the allowed decrease of the throughput, in percent.
+	Throughput float64
+	// ErrorRatio is the allowed absolute increase of the error ratio (0.01 = 1%).
+	ErrorRatio float64
+}
+
+// ConfiguredTolerances returns the tolerances set in the `compare` section
+// of the configuration (or its flags).
+func ConfiguredTolerances() Tolerances {
+	return Tolerances{
+		Latency:    viper.GetFloat64("compare.latency-tolerance"),
+		Throughput: viper.GetFloat64("compare.throughput-tolerance"),
+		ErrorRatio: viper.GetFloat64("compare.error-ratio-tolerance") / 100,
+	}
+}
+
+// Delta is the change of a single metric between two runs.
+type Delta struct {
+	Metric     string
+	Baseline   float64
+	Candidate  float64
+	Change     float64 // percent for latencies and throughput, absolute for the error ratio
+	Regression bool
+}
+
+// TestComparison holds the deltas of a single test. Tests only present in one
+// of the runs have no deltas. A test missing in the candidate is a regression,
+// one missing in the baseline is not.
+type TestComparison struct {
+	TestName         string
+	Deltas           []Delta
+	MissingBaseline  bool
+	MissingCandidate bool
+}
+
+// Regressed reports whether any metric of the test went beyond the tolerances,
+// or the test is missing in the candidate.
+func (t TestComparison) Regressed() bool {
+	if t.MissingCandidate {
+		return true
+	}
+	for _, d := range t.Deltas {
+		if d.Regression {
+			return true
+		}
+	}
+	return false
+}
+
+// Comparison is the result of comparing two runs.
+type Comparison struct {
+	Tolerances Tolerances
+	Tests      []TestComparison
+}
+
+// Regressed reports whether any test regressed.
+func (c *Comparison) Regressed() bool {
+	for _, t := range c.Tests {
+		if t.Regressed() {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare computes per test deltas between baseline and candidate summaries.
+func Compare(baseline, candidate map[string]*Summary, tol Tolerances) *Comparison {
+	names := map[string]bool{}
+	for n := range baseline {
+		names[n] = true
+	}
+	for n := range candidate {
+		names[n] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	comparison := &Comparison{Tolerances: tol}
+	for _, name := range sorted {
+		b, okB := baseline[name]
+		c, okC := candidate[name]
+		tc := TestComparison{
+			TestName:         name,
+			MissingBaseline:  !okB,
+			MissingCandidate: !okC,
+		}
+		if okB && okC {
+			tc.Deltas = []Delta{
+				latencyDelta("mean", b.Mean, c.Mean, tol),
+				latencyDelta("p50", b.P50, c.P50, tol),
+				latencyDelta("p90", b.P90, c.P90, tol),
+				latencyDelta("p95", b.P95, c.P95, tol),
+				latencyDelta("p99", b.P99, c.P99, tol),
+				latencyDelta("max", b.Max, c.Max, tol),
+				throughputDelta(b.Throughput, c.Throughput, tol),
+				errorRatioDelta(b.ErrorRatio, c.ErrorRatio, tol),
+			}
+		}
+		comparison.Tests = append(comparison.Tests, tc)
+	}
+	return comparison
+}
+
+func percentChange(baseline, candidate float64) float64 {
+	if baseline == 0 {
+		if candidate == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (candidate - baseline) / baseline * 100
+}
+
+func latencyDelta(metric string, baseline, candidate time.Duration, tol Tolerances) Delta {
+	b := float64(baseline) / float64(time.Millisecond)
+	c := float64(candidate) / float64(time.Millisecond)
+	change := percentChange(b, c)
+	return Delta{
+		Metric:     metric,
+		Baseline:   b,
+		Candidate:  c,
+		Change:     change,
+		Regression: change > tol.Latency,
+	}
+}
+
+func throughputDelta(baseline, candidate float64, tol Tolerances) Delta {
+	change := percentChange(baseline, candidate)
+	return Delta{
+		Metric:     "throughput",
+		Baseline:   baseline,
+		Candidate:  candidate,
+		Change:     change,
+		Regression: -change > tol.Throughput,
+	}
+}
+
+func errorRatioDelta(baseline, candidate float64, tol Tolerances) Delta {
+	change := candidate - baseline
+	return Delta{
+		Metric:     "error_ratio",
+		Baseline:   baseline,
+		Candidate:  candidate,
+		Change:     change,
+		Regression: change > tol.ErrorRatio,
+	}
+}
+
+// Write writes a human readable report of the comparison.
+func (c *Comparison) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "TEST\tMETRIC\tBASELINE\tCANDIDATE\tCHANGE\tVERDICT\n")
+	for _, t := range c.Tests {
+		switch {
+		case t.MissingBaseline:
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\tmissing in baseline\n", t.TestName)
+			continue
+		case t.MissingCandidate:
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\tREGRESSION: missing in candidate\n", t.TestName)
+			continue
+		}
+		for _, d := range t.Deltas {
+			verdict := "ok"
+			if d.Regression {
+				verdict = "REGRESSION"
+			}
+			switch d.Metric {
+			case "throughput":
+				fmt.Fprintf(tw, "%s\t%s\t%.2f/s\t%.2f/s\t%+.2f%%\t%s\n", t.TestName, d.Metric, d.Baseline, d.Candidate, d.Change, verdict)
+			case "error_ratio":
+				fmt.Fprintf(tw, "%s\t%s\t%.2f%%\t%.2f%%\t%+.2fpp\t%s\n", t.TestName, d.Metric, d.Baseline*100, d.Candidate*100, d.Change*100, verdict)
+			default:
+				fmt.Fprintf(tw, "%s\t%s\t%.2fms\t%.2fms\t%+.2f%%\t%s\n", t.TestName, d.Metric, d.Baseline, d.Candidate, d.Change, verdict)
+			}
+		}
+	}
+	verdict := "PASS"
+	if c.Regressed() {
+		verdict = "FAIL"
+	}
+	fmt.Fprintf(tw, "\nVerdict: %s (tolerances: latency +%.2f%%, throughput -%.2f%%, error ratio +%.2fpp)\n",
+		verdict, c.Tolerances.Latency, c.Tolerances.Throughput, c.Tolerances.ErrorRatio*100)
+	return tw.Flush()
+}
@@ -0,0 +1,85 @@
+package results
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	tol := Tolerances{Latency: 10, Throughput: 10, ErrorRatio: 0.01}
+	base := &Summary{TestName: "list-clusters", Throughput: 100, ErrorRatio: 0, Mean: 100 * time.Millisecond,
+		P50: 100 * time.Millisecond, P90: 100 * time.Millisecond, P95: 100 * time.Millisecond, P99: 100 * time.Millisecond, Max: 100 * time.Millisecond}
+	tests := []struct {
+		name      string
+		candidate Summary
+		regressed []string
+	}{
+		{"same", *base, nil},
+		{"within_tolerance", Summary{Throughput: 95, ErrorRatio: 0.005, Mean: 105 * time.Millisecond,
+			P50: 105 * time.Millisecond, P90: 105 * time.Millisecond, P95: 105 * time.Millisecond, P99: 105 * time.Millisecond, Max: 105 * time.Millisecond}, nil},
+		{"slower_p99", Summary{Throughput: 100, Mean: 100 * time.Millisecond,
+			P50: 100 * time.Millisecond, P90: 100 * time.Millisecond, P95: 100 * time.Millisecond, P99: 200 * time.Millisecond, Max: 200 * time.Millisecond}, []string{"p99", "max"}},
+		{"lower_throughput", Summary{Throughput: 80, Mean: 100 * time.Millisecond,
+			P50: 100 * time.Millisecond, P90: 100 * time.Millisecond, P95: 100 * time.Millisecond, P99: 100 * time.Millisecond, Max: 100 * time.Millisecond}, []string{"throughput"}},
+		{"more_errors", Summary{Throughput: 100, ErrorRatio: 0.05, Mean: 100 * time.Millisecond,
+			P50: 100 * time.Millisecond, P90: 100 * time.Millisecond, P95: 100 * time.Millisecond, P99: 100 * time.Millisecond, Max: 100 * time.Millisecond}, []string{"error_ratio"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidate := tt.candidate
+			c := Compare(map[string]*Summary{"list-clusters": base}, map[string]*Summary{"list-clusters": &candidate}, tol)
+			if len(c.Tests) != 1 {
+				t.Fatalf("Compare() returned %d tests, want 1", len(c.Tests))
+			}
+			got := []string{}
+			for _, d := range c.Tests[0].Deltas {
+				if d.Regression {
+					got = append(got, d.Metric)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.regressed, ",") {
+				t.Errorf("Compare() regressions = %v, want %v", got, tt.regressed)
+			}
+			if c.Regressed() != (len(tt.regressed) > 0) {
+				t.Errorf("Regressed() = %v", c.Regressed())
+			}
+		})
+	}
+}
+
+func TestCompareMissingTests(t *testing.T) {
+	c := Compare(
+		map[string]*Summary{"list-clusters": {}},
+		map[string]*Summary{"create-cluster": {}},
+		Tolerances{})
+	if len(c.Tests) != 2 {
+		t.Fatalf("Compare() returned %d tests, want 2", len(c.Tests))
+	}
+	if !c.Tests[0].MissingBaseline || !c.Tests[1].MissingCandidate {
+		t.Errorf("Compare() = %+v, expected missing tests to be flagged", c.Tests)
+	}
+	if c.Tests[0].Regressed() {
+		t.Errorf("a test missing in the baseline should not be reported as a regression")
+	}
+	if !c.Tests[1].Regressed() || !c.Regressed() {
+		t.Errorf("a test missing in the candidate should be reported as a regression")
+	}
+	var out bytes.Buffer
+	if err := c.Write(&out); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "missing in baseline") || !strings.Contains(out.String(), "REGRESSION: missing in candidate") ||
+		!strings.Contains(out.String(), "Verdict: FAIL") {
+		t.Errorf("Write() = %s", out.String())
+	}
+
+	c = Compare(
+		map[string]*Summary{"list-clusters": {}},
+		map[string]*Summary{"list-clusters": {}, "create-cluster": {}},
+		Tolerances{})
+	if c.Regressed() {
+		t.Errorf("a test missing in the baseline should not fail the comparison")
+	}
+}
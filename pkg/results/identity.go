@@ -0,0 +1,71 @@
+package results
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// Identity labels the results sent through a connection with the auth entry
+// and the organization behind it.
+type Identity struct {
+	Connection      string `json:"connection"`
+	ConnectionIndex int    `json:"connection_index"`
+	OrganizationID  string `json:"organization_id,omitempty"`
+	Organization    string `json:"organization,omitempty"`
+}
+
+// NewLabeledEncoder returns an encoder that writes JSON results to w, like
+// vegeta's JSON encoder, with the fields of the identity added to each of
+// them, along with its trace and operation IDs when known and its throttling,
+// see appendThrottleFields. vegeta decoders
+// ignore the extra fields. Like vegeta's, the encoder must not be used
+// concurrently.
+func NewLabeledEncoder(w io.Writer, identity Identity) vegeta.Encoder {
+	labels, _ := json.Marshal(identity)
+	// Keep only the fields, without the enclosing braces.
+	labels = labels[1 : len(labels)-1]
+
+	var buf bytes.Buffer
+	enc := vegeta.NewJSONEncoder(&buf)
+	return func(res *vegeta.Result) error {
+		buf.Reset()
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+		line := bytes.TrimRight(buf.Bytes(), "\n")
+		line = line[:len(line)-1]
+		line = append(line, ',')
+		line = append(line, labels...)
+		line = appendTraceFields(line, res)
+		line = appendThrottleFields(line, res)
+		line = append(line, '}', '\n')
+		_, err := w.Write(line)
+		return err
+	}
+}
+
+// fileIdentity returns the identity of the first result of a result file. All
+// the results of a file are sent through the same connection.
+func fileIdentity(fileName string) (Identity, error) {
+	identity := Identity{}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return identity, err
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return identity, err
+	}
+	if len(bytes.TrimSpace(line)) == 0 {
+		return identity, nil
+	}
+	err = json.Unmarshal(line, &identity)
+	return identity, err
+}
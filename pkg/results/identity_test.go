@@ -0,0 +1,109 @@
+package results
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func writeIdentityResults(t *testing.T, fileName string, identity Identity, results ...vegeta.Result) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		t.Fatalf("creating %s: %v", fileName, err)
+	}
+	defer f.Close()
+	enc := NewLabeledEncoder(f, identity)
+	for i := range results {
+		if err := enc.Encode(&results[i]); err != nil {
+			t.Fatalf("encoding result: %v", err)
+		}
+	}
+}
+
+func TestNewLabeledEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	identity := Identity{Connection: "org-admin", ConnectionIndex: 1, OrganizationID: "1a2b", Organization: "Load Org"}
+	enc := NewLabeledEncoder(&buf, identity)
+	for _, code := range []uint16{200, 404} {
+		err := enc.Encode(&vegeta.Result{Attack: "list-clusters", Code: code, Latency: time.Millisecond})
+		if err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Encode() wrote %d lines, want 2", len(lines))
+	}
+	for _, want := range []string{`"connection":"org-admin"`, `"connection_index":1`, `"organization_id":"1a2b"`, `"organization":"Load Org"`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("line %q does not contain %s", lines[0], want)
+		}
+	}
+
+	dec := vegeta.NewJSONDecoder(&buf)
+	for _, want := range []uint16{200, 404} {
+		var res vegeta.Result
+		if err := dec.Decode(&res); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		if res.Attack != "list-clusters" || res.Code != want {
+			t.Errorf("Decode() = %+v, want list-clusters with code %d", res, want)
+		}
+	}
+}
+
+func TestLoadDirByIdentity(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeIdentityResults(t, filepath.Join(dir, "run1_list-clusters_0.json"),
+		Identity{Connection: "auth-0", OrganizationID: "org-a"},
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now, Latency: 10 * time.Millisecond},
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now.Add(time.Second), Latency: 20 * time.Millisecond})
+	writeIdentityResults(t, filepath.Join(dir, "run1_list-clusters_1.json"),
+		Identity{Connection: "hot-org", ConnectionIndex: 1, OrganizationID: "org-b", Organization: "Hot Org"},
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now, Latency: time.Second})
+	writeResults(t, filepath.Join(dir, "run1_list-clusters_2.json"),
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now, Latency: time.Millisecond})
+
+	summaries, err := LoadDirByIdentity(dir, "run1")
+	if err != nil {
+		t.Fatalf("LoadDirByIdentity() error = %v", err)
+	}
+	if len(summaries) != 3 {
+		t.Fatalf("LoadDirByIdentity() = %v, want 3 summaries", summaries)
+	}
+	s := summaries["list-clusters@auth-0"]
+	if s == nil || s.Requests != 2 || s.TestName != "list-clusters" || s.Organization != "org-a" {
+		t.Errorf("auth-0 summary = %+v", s)
+	}
+	s = summaries["list-clusters@hot-org"]
+	if s == nil || s.Max != time.Second || s.Organization != "Hot Org" {
+		t.Errorf("hot-org summary = %+v", s)
+	}
+	if s := summaries["list-clusters@"]; s == nil || s.Requests != 1 {
+		t.Errorf("unlabelled summary = %+v", s)
+	}
+
+	var buf bytes.Buffer
+	err = WriteSummaries(&buf, []*Summary{summaries["list-clusters@hot-org"]})
+	if err != nil {
+		t.Fatalf("WriteSummaries() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "TEST") || !strings.Contains(buf.String(), "IDENTITY") || !strings.Contains(buf.String(), "Hot Org") {
+		t.Errorf("WriteSummaries() = %q, want identity columns", buf.String())
+	}
+
+	all, err := LoadDir(dir, "run1")
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	if s := all["list-clusters"]; s == nil || s.Requests != 4 || s.Identity != "" {
+		t.Errorf("LoadDir() summary = %+v, want 4 requests without identity", s)
+	}
+}
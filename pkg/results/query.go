@@ -0,0 +1,58 @@
+package results
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Parameters whose values are kept in the query shape, as they change the
+// way the server builds the query. The values of any other parameter are
+// dropped.
+var shapeValueParameters = map[string]bool{
+	"size":   true,
+	"order":  true,
+	"fields": true,
+}
+
+var (
+	searchStringLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+	searchNumberLiteral = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+)
+
+// QueryShape returns the shape of the query of a request URL, so requests
+// using the same search expression with different literals can be grouped.
+// Parameters are sorted by name, the literals of `search` are replaced with
+// `?` and only the values of `size`, `order` and `fields` are kept, e.g.:
+//
+//	fields=id,name&order=name asc&page&search=name like ?&size=100
+//
+// It returns an empty string when the URL has no query.
+func QueryShape(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return ""
+	}
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		value := query.Get(name)
+		switch {
+		case name == "search":
+			value = searchNumberLiteral.ReplaceAllString(searchStringLiteral.ReplaceAllString(value, "?"), "?")
+			parts = append(parts, name+"="+value)
+		case shapeValueParameters[name]:
+			parts = append(parts, name+"="+value)
+		default:
+			parts = append(parts, name)
+		}
+	}
+	return strings.Join(parts, "&")
+}
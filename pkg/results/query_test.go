@@ -0,0 +1,36 @@
+package results
+
+import "testing"
+
+func TestQueryShape(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"no query", "http://localhost/api/clusters_mgmt/v1/clusters", ""},
+		{"invalid", "http://local host/%zz", ""},
+		{
+			"search literals",
+			"http://localhost/api/clusters_mgmt/v1/clusters?search=name+like+%27pocm-%25%27+and+nodes.compute+%3E+3",
+			"search=name like ? and nodes.compute > ?",
+		},
+		{
+			"quoted quote",
+			"http://localhost/x?search=name+%3D+%27it%27%27s%27",
+			"search=name = ?",
+		},
+		{
+			"sorted and page dropped",
+			"http://localhost/x?size=100&page=7&order=name+asc&fields=id%2Cname&search=state+%3D+%27ready%27",
+			"fields=id,name&order=name asc&page&search=state = ?&size=100",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QueryShape(tt.url); got != tt.want {
+				t.Errorf("QueryShape() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package results
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// resultFileRegexp matches the result files written by the runner:
+// <test-id>_<test-name>_<connection index>.json, or
+// <test-id>_<test-name>_<connection index>.<segment>.json for the files
+// rotated by soak runs.
+var resultFileRegexp = regexp.MustCompile(`^(.+)_([^_]+)_(\d+)(?:\.\d+)?\.json$`)
+
+// Summary holds the aggregated metrics of a single test. Identity and
+// Organization are only set on summaries broken down by identity. Throttled
+// requests are counted apart and are not part of ErrorRatio.
+type Summary struct {
+	TestName      string        `json:"test_name"`
+	Identity      string        `json:"identity,omitempty"`
+	Organization  string        `json:"organization,omitempty"`
+	Requests      uint64        `json:"requests"`
+	Rate          float64       `json:"rate"`
+	Throughput    float64       `json:"throughput"`
+	ErrorRatio    float64       `json:"error_ratio"`
+	Throttled     uint64        `json:"throttled"`
+	ThrottleRatio float64       `json:"throttle_ratio"`
+	Mean          time.Duration `json:"mean"`
+	P50           time.Duration `json:"p50"`
+	P90           time.Duration `json:"p90"`
+	P95           time.Duration `json:"p95"`
+	P99           time.Duration `json:"p99"`
+	Max           time.Duration `json:"max"`
+}
+
+// Collector aggregates results per test. The test is taken from the attack name
+// of each result, which is always the test name.
+type Collector struct {
+	metrics   map[string]*vegeta.Metrics
+	throttled map[string]uint64
+
+	// byIdentity breaks the results of each test down by the identity that
+	// sent them.
+	byIdentity bool
+	tests      map[string]string
+	identities map[string]Identity
+
+	// from and to, when set, restrict the collector to the results sent
+	// within [from, to).
+	from, to time.Time
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		metrics:    map[string]*vegeta.Metrics{},
+		throttled:  map[string]uint64{},
+		tests:      map[string]string{},
+		identities: map[string]Identity{},
+	}
+}
+
+// NewIdentityCollector returns a collector that aggregates results per test
+// and identity, keyed by `<test>@<connection>`.
+func NewIdentityCollector() *Collector {
+	c := NewCollector()
+	c.byIdentity = true
+	return c
+}
+
+// Add adds a result to the metrics of its test.
+func (c *Collector) Add(res *vegeta.Result) {
+	c.AddIdentity(res, Identity{})
+}
+
+// AddIdentity adds a result sent by the identity to the metrics of its test.
+// The identity is ignored unless the collector breaks results down by it.
+// Results outside the window of the collector are left out. A backoff pause
+// ends before the request is sent, so it is not part of the latency.
+func (c *Collector) AddIdentity(res *vegeta.Result, identity Identity) {
+	if !c.from.IsZero() && res.Timestamp.Before(c.from) || !c.to.IsZero() && !res.Timestamp.Before(c.to) {
+		return
+	}
+	key := res.Attack
+	if c.byIdentity {
+		key = fmt.Sprintf("%s@%s", res.Attack, identity.Connection)
+	}
+	m, ok := c.metrics[key]
+	if !ok {
+		m = &vegeta.Metrics{}
+		c.metrics[key] = m
+		c.tests[key] = res.Attack
+		if c.byIdentity {
+			c.identities[key] = identity
+		}
+	}
+	if IsThrottled(res) {
+		c.throttled[key]++
+	}
+	m.Add(res)
+}
+
+// Summaries closes the metrics and returns a summary for each test.
+func (c *Collector) Summaries() map[string]*Summary {
+	summaries := make(map[string]*Summary, len(c.metrics))
+	for key, m := range c.metrics {
+		m.Close()
+		identity := c.identities[key]
+		organization := identity.Organization
+		if organization == "" {
+			organization = identity.OrganizationID
+		}
+		throttleRatio := 0.0
+		if m.Requests > 0 {
+			throttleRatio = float64(c.throttled[key]) / float64(m.Requests)
+		}
+		summaries[key] = &Summary{
+			TestName:      c.tests[key],
+			Identity:      identity.Connection,
+			Organization:  organization,
+			Requests:      m.Requests,
+			Rate:          m.Rate,
+			Throughput:    m.Throughput,
+			ErrorRatio:    math.Max(0, 1-m.Success-throttleRatio),
+			Throttled:     c.throttled[key],
+			ThrottleRatio: throttleRatio,
+			Mean:          m.Latencies.Mean,
+			P50:           m.Latencies.P50,
+			P90:           m.Latencies.P90,
+			P95:           m.Latencies.P95,
+			P99:           m.Latencies.P99,
+			Max:           m.Latencies.Max,
+		}
+	}
+	return summaries
+}
+
+// WriteSummaries writes a table with the summaries. Summaries broken down by
+// identity get the identity and organization columns.
+func WriteSummaries(w io.Writer, summaries []*Summary) error {
+	byIdentity := false
+	for _, s := range summaries {
+		if s.Identity != "" {
+			byIdentity = true
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if byIdentity {
+		fmt.Fprintf(tw, "TEST\tIDENTITY\tORGANIZATION\tREQUESTS\tRATE\tERRORS\tTHROTTLED\tMEAN\tP50\tP90\tP95\tP99\tMAX\n")
+	} else {
+		fmt.Fprintf(tw, "TEST\tREQUESTS\tRATE\tERRORS\tTHROTTLED\tMEAN\tP50\tP90\tP95\tP99\tMAX\n")
+	}
+	for _, s := range summaries {
+		if byIdentity {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t", s.TestName, s.Identity, s.Organization)
+		} else {
+			fmt.Fprintf(tw, "%s\t", s.TestName)
+		}
+		fmt.Fprintf(tw, "%d\t%.2f/s\t%.2f%%\t%.2f%%\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.Requests, s.Rate, s.ErrorRatio*100, s.ThrottleRatio*100, s.Mean, s.P50, s.P90, s.P95, s.P99, s.Max)
+	}
+	return tw.Flush()
+}
+
+// ResultFiles returns the result files found in dir, optionally filtered by test ID.
+func ResultFiles(dir, testID string) ([]string, error) {
+	return TestResultFiles(dir, testID, "")
+}
+
+// TestResultFiles is like ResultFiles, optionally filtered by test name too.
+func TestResultFiles(dir, testID, testName string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		parts := resultFileRegexp.FindStringSubmatch(e.Name())
+		if parts == nil {
+			continue
+		}
+		if testID != "" && parts[1] != testID {
+			continue
+		}
+		if testName != "" && parts[2] != testName {
+			continue
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// DecodeFile feeds every result stored in a JSON result file to the collector.
+func (c *Collector) DecodeFile(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	identity := Identity{}
+	if c.byIdentity {
+		identity, err = fileIdentity(fileName)
+		if err != nil {
+			return fmt.Errorf("decoding identity of %s: %v", fileName, err)
+		}
+	}
+	dec := vegeta.NewJSONDecoder(file)
+	for {
+		var res vegeta.Result
+		err := dec.Decode(&res)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("decoding %s: %v", fileName, err)
+		}
+		c.AddIdentity(&res, identity)
+	}
+}
+
+// LoadDir summarizes the result files of dir. If testID is empty every result
+// file in the directory is used.
+func LoadDir(dir, testID string) (map[string]*Summary, error) {
+	return loadDir(NewCollector(), dir, testID)
+}
+
+// LoadDirByIdentity is like LoadDir, with the summaries of each test broken
+// down by the identity that sent the results.
+func LoadDirByIdentity(dir, testID string) (map[string]*Summary, error) {
+	return loadDir(NewIdentityCollector(), dir, testID)
+}
+
+// LoadWindow is like LoadDir, restricted to the results sent within
+// [from, to). Files last modified before from are not read.
+func LoadWindow(dir, testID string, from, to time.Time) (map[string]*Summary, error) {
+	c := NewCollector()
+	c.from, c.to = from, to
+	return loadDir(c, dir, testID)
+}
+
+func loadDir(c *Collector, dir, testID string) (map[string]*Summary, error) {
+	files, err := ResultFiles(dir, testID)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no result files found in %s for test ID %q", dir, testID)
+	}
+	for _, f := range files {
+		if !c.from.IsZero() {
+			info, err := os.Stat(f)
+			if err != nil {
+				return nil, err
+			}
+			if info.ModTime().Before(c.from) {
+				continue
+			}
+		}
+		err := c.DecodeFile(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Summaries(), nil
+}
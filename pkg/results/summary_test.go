@@ -0,0 +1,104 @@
+package results
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func writeResults(t *testing.T, fileName string, results ...vegeta.Result) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		t.Fatalf("creating %s: %v", fileName, err)
+	}
+	defer f.Close()
+	enc := vegeta.NewJSONEncoder(f)
+	for i := range results {
+		if err := enc.Encode(&results[i]); err != nil {
+			t.Fatalf("encoding result: %v", err)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeResults(t, filepath.Join(dir, "run1_list-clusters_0.json"),
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now, Latency: 10 * time.Millisecond},
+		vegeta.Result{Attack: "list-clusters", Code: 500, Timestamp: now.Add(time.Second), Latency: 30 * time.Millisecond})
+	writeResults(t, filepath.Join(dir, "run1_list-clusters_1.json"),
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now, Latency: 20 * time.Millisecond})
+	writeResults(t, filepath.Join(dir, "run2_list-clusters_0.json"),
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now, Latency: time.Second})
+	os.WriteFile(filepath.Join(dir, "run1_report.txt"), []byte("not a result"), 0o644)
+
+	summaries, err := LoadDir(dir, "run1")
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	s, ok := summaries["list-clusters"]
+	if !ok {
+		t.Fatalf("LoadDir() = %v, missing list-clusters", summaries)
+	}
+	if s.Requests != 3 {
+		t.Errorf("Requests = %d, want 3", s.Requests)
+	}
+	if s.Max != 30*time.Millisecond {
+		t.Errorf("Max = %s, want 30ms", s.Max)
+	}
+	if s.ErrorRatio < 0.33 || s.ErrorRatio > 0.34 {
+		t.Errorf("ErrorRatio = %f, want 1/3", s.ErrorRatio)
+	}
+
+	if _, err := LoadDir(dir, "run3"); err == nil {
+		t.Errorf("LoadDir() should fail when there are no result files")
+	}
+}
+
+func TestLoadWindow(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeResults(t, filepath.Join(dir, "run1_list-clusters_0.0.json"),
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now.Add(-time.Hour), Latency: time.Second})
+	writeResults(t, filepath.Join(dir, "run1_list-clusters_0.1.json"),
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now.Add(-time.Minute), Latency: 10 * time.Millisecond},
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now.Add(time.Minute), Latency: time.Second})
+
+	files, err := ResultFiles(dir, "run1")
+	if err != nil || len(files) != 2 {
+		t.Fatalf("ResultFiles() = %v, %v, want the 2 segments", files, err)
+	}
+	summaries, err := LoadWindow(dir, "run1", now.Add(-10*time.Minute), now)
+	if err != nil {
+		t.Fatalf("LoadWindow() error = %v", err)
+	}
+	s, ok := summaries["list-clusters"]
+	if !ok {
+		t.Fatalf("LoadWindow() = %v, missing list-clusters", summaries)
+	}
+	if s.Requests != 1 || s.Max != 10*time.Millisecond {
+		t.Errorf("LoadWindow() = %d requests, max %s, want 1 request, max 10ms", s.Requests, s.Max)
+	}
+}
+
+func TestWriteSummaries(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteSummaries(&buf, []*Summary{{TestName: "sso-token", Requests: 3, ErrorRatio: 1.0 / 3, Mean: 120 * time.Millisecond}})
+	if err != nil {
+		t.Fatalf("WriteSummaries() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "TEST") {
+		t.Fatalf("WriteSummaries() = %q, want a header and a row", buf.String())
+	}
+	for _, want := range []string{"sso-token", "33.33%", "120ms"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("row %q does not contain %q", lines[1], want)
+		}
+	}
+}
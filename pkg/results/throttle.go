@@ -0,0 +1,92 @@
+package results
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// BackoffHeader is added by the runner to the response headers of the first
+// request sent after an attack paused by a backoff throttle policy, with the
+// time the attack paused. The pause is not part of the latency.
+const BackoffHeader = "X-Load-Backoff"
+
+// RateLimitHeaders are the response headers describing the rate limit the
+// gateway applies. They are always kept in the results.
+var RateLimitHeaders = []string{
+	"Retry-After",
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+}
+
+// IsThrottled reports whether the request was rejected by the rate limiter
+// of the gateway. Throttled requests are not counted as errors.
+func IsThrottled(res *vegeta.Result) bool {
+	return res.Code == http.StatusTooManyRequests
+}
+
+// RetryAfter returns how long after now the rate limit headers ask clients to
+// wait before the next request. Retry-After is used first, as delay seconds or
+// HTTP date, then the reset of the RateLimit headers, as delay seconds, or as
+// Unix time for X-RateLimit-Reset.
+func RetryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	if value := h.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			return nonNegative(time.Duration(seconds * float64(time.Second))), true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return nonNegative(date.Sub(now)), true
+		}
+	}
+	for _, name := range []string{"RateLimit-Reset", "X-RateLimit-Reset"} {
+		seconds, err := strconv.ParseFloat(h.Get(name), 64)
+		if err != nil {
+			continue
+		}
+		// Resets larger than a year of seconds can only be timestamps.
+		if seconds > 365*24*60*60 {
+			return nonNegative(time.Unix(int64(seconds), 0).Sub(now)), true
+		}
+		return nonNegative(time.Duration(seconds * float64(time.Second))), true
+	}
+	return 0, false
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// backoff returns the time the attack paused before sending the request.
+func backoff(res *vegeta.Result) time.Duration {
+	d, err := time.ParseDuration(res.Headers.Get(BackoffHeader))
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// appendThrottleFields appends, for throttled requests, the throttled flag
+// and the retry delay asked by the gateway in seconds, and the backoff of
+// the request in nanoseconds, like the latency, as JSON fields.
+func appendThrottleFields(line []byte, res *vegeta.Result) []byte {
+	if IsThrottled(res) {
+		line = append(line, `,"throttled":true`...)
+		if d, ok := RetryAfter(res.Headers, res.Timestamp.Add(res.Latency)); ok {
+			line = append(line, fmt.Sprintf(`,"retry_after":%g`, d.Seconds())...)
+		}
+	}
+	if d := backoff(res); d > 0 {
+		line = append(line, fmt.Sprintf(`,"backoff":%d`, d)...)
+	}
+	return line
+}
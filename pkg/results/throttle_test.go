@@ -0,0 +1,93 @@
+package results
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+		ok      bool
+	}{
+		{"none", map[string]string{}, 0, false},
+		{"seconds", map[string]string{"Retry-After": "2"}, 2 * time.Second, true},
+		{"date", map[string]string{"Retry-After": now.Add(time.Minute).Format(http.TimeFormat)}, time.Minute, true},
+		{"past date", map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)}, 0, true},
+		{"ratelimit reset", map[string]string{"RateLimit-Reset": "30"}, 30 * time.Second, true},
+		{"x-ratelimit reset time", map[string]string{"X-RateLimit-Reset": "1659355210"}, 10 * time.Second, true},
+		{"retry-after first", map[string]string{"Retry-After": "1", "RateLimit-Reset": "30"}, time.Second, true},
+		{"invalid", map[string]string{"Retry-After": "soon"}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for name, value := range tt.headers {
+				h.Set(name, value)
+			}
+			got, ok := RetryAfter(h, now)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("RetryAfter() = %s, %v, want %s, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNewLabeledEncoder_ThrottleFields(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewLabeledEncoder(&buf, Identity{Connection: "auth-0"})
+	headers := http.Header{}
+	headers.Set("Retry-After", "1.5")
+	headers.Set(BackoffHeader, "250ms")
+	res := vegeta.Result{Attack: "list-clusters", Code: http.StatusTooManyRequests, Timestamp: time.Now(), Headers: headers}
+	if err := enc.Encode(&res); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	res = vegeta.Result{Attack: "list-clusters", Code: http.StatusOK}
+	if err := enc.Encode(&res); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for _, want := range []string{`"throttled":true`, `"retry_after":1.5`, `"backoff":250000000`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("line %q does not contain %s", lines[0], want)
+		}
+	}
+	if strings.Contains(lines[1], "throttled") || strings.Contains(lines[1], "backoff") {
+		t.Errorf("line %q has throttle fields without being throttled", lines[1])
+	}
+}
+
+func TestLoadDir_Throttled(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	resumed := http.Header{}
+	resumed.Set(BackoffHeader, "1s")
+	writeResults(t, filepath.Join(dir, "run1_list-clusters_0.json"),
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now, Latency: 10 * time.Millisecond},
+		vegeta.Result{Attack: "list-clusters", Code: 429, Timestamp: now, Latency: 10 * time.Millisecond, Error: "429 Too Many Requests"},
+		vegeta.Result{Attack: "list-clusters", Code: 500, Timestamp: now, Latency: 10 * time.Millisecond, Error: "500 Internal Server Error"},
+		vegeta.Result{Attack: "list-clusters", Code: 200, Timestamp: now, Latency: 10 * time.Millisecond, Headers: resumed})
+
+	summaries, err := LoadDir(dir, "run1")
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	s := summaries["list-clusters"]
+	if s.Throttled != 1 || s.ThrottleRatio != 0.25 || s.ErrorRatio != 0.25 {
+		t.Errorf("summary = %+v, want 1 throttled request and 1 error out of 4", s)
+	}
+	if s.Max != 10*time.Millisecond {
+		t.Errorf("max latency = %s, want the pause left out", s.Max)
+	}
+}
@@ -0,0 +1,216 @@
+package results
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// TraceIDHeader is added by the runner to the response headers of every
+	// result with the trace ID sent in the traceparent of the request.
+	TraceIDHeader = "X-Load-Trace-Id"
+	// OperationIDHeader is added by the runner to the response headers of
+	// every result with the operation or trace ID returned by the gateway.
+	OperationIDHeader = "X-Load-Operation-Id"
+)
+
+// appendTraceFields appends the trace and operation IDs of the result, when
+// known, as JSON fields.
+func appendTraceFields(line []byte, res *vegeta.Result) []byte {
+	for _, f := range []struct {
+		name   string
+		header string
+	}{
+		{"trace_id", TraceIDHeader},
+		{"operation_id", OperationIDHeader},
+	} {
+		value := res.Headers.Get(f.header)
+		if value == "" {
+			continue
+		}
+		quoted, _ := json.Marshal(value)
+		line = append(line, fmt.Sprintf(",%q:", f.name)...)
+		line = append(line, quoted...)
+	}
+	return line
+}
+
+// ErrorTraces holds the trace IDs of the requests of an attack that failed
+// without a response, such as timeouts, until their result is encoded. They
+// are keyed by the attack name and sequence number vegeta sets on every
+// request and result.
+type ErrorTraces struct {
+	mu  sync.Mutex
+	ids map[errorTraceKey]string
+}
+
+type errorTraceKey struct {
+	attack string
+	seq    uint64
+}
+
+// NewErrorTraces returns an empty ErrorTraces.
+func NewErrorTraces() *ErrorTraces {
+	return &ErrorTraces{ids: map[errorTraceKey]string{}}
+}
+
+// Record records the trace ID of a request sent by a vegeta attacker. Requests
+// without a sequence number are ignored.
+func (t *ErrorTraces) Record(request *http.Request, traceID string) {
+	seq, err := strconv.ParseUint(request.Header.Get("X-Vegeta-Seq"), 10, 64)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ids[errorTraceKey{attack: request.Header.Get("X-Vegeta-Attack"), seq: seq}] = traceID
+}
+
+// take returns and forgets the trace ID recorded for the result.
+func (t *ErrorTraces) take(res *vegeta.Result) string {
+	key := errorTraceKey{attack: res.Attack, seq: res.Seq}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	traceID := t.ids[key]
+	delete(t.ids, key)
+	return traceID
+}
+
+// NewErrorTraceEncoder wraps enc so that the results of the requests that
+// failed without a response get the trace ID recorded in traces.
+func NewErrorTraceEncoder(enc vegeta.Encoder, traces *ErrorTraces) vegeta.Encoder {
+	return func(res *vegeta.Result) error {
+		if res.Headers.Get(TraceIDHeader) == "" {
+			if traceID := traces.take(res); traceID != "" {
+				if res.Headers == nil {
+					res.Headers = http.Header{}
+				}
+				res.Headers.Set(TraceIDHeader, traceID)
+			}
+		}
+		return enc.Encode(res)
+	}
+}
+
+// SlowRequest is one of the slowest requests of a test, with the IDs to look
+// it up in tracing backends.
+type SlowRequest struct {
+	Attack      string        `json:"attack"`
+	Timestamp   time.Time     `json:"timestamp"`
+	Latency     time.Duration `json:"latency"`
+	Code        uint16        `json:"code"`
+	Method      string        `json:"method"`
+	URL         string        `json:"url"`
+	TraceID     string        `json:"trace_id"`
+	OperationID string        `json:"operation_id"`
+}
+
+// slowHeap is a min heap of requests by latency, to keep the slowest ones.
+type slowHeap []SlowRequest
+
+func (h slowHeap) Len() int            { return len(h) }
+func (h slowHeap) Less(i, j int) bool  { return h[i].Latency < h[j].Latency }
+func (h slowHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *slowHeap) Push(x interface{}) { *h = append(*h, x.(SlowRequest)) }
+func (h *slowHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// SlowestRequests returns the n slowest requests of each test in the result
+// files of dir, the slowest first. If testID is empty every result file in
+// the directory is used.
+func SlowestRequests(dir, testID string, n int) (map[string][]SlowRequest, error) {
+	files, err := ResultFiles(dir, testID)
+	if err != nil {
+		return nil, err
+	}
+	heaps := map[string]*slowHeap{}
+	for _, f := range files {
+		err := decodeSlowest(f, n, heaps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slowest := make(map[string][]SlowRequest, len(heaps))
+	for attack, h := range heaps {
+		requests := []SlowRequest(*h)
+		sort.Slice(requests, func(i, j int) bool { return requests[i].Latency > requests[j].Latency })
+		slowest[attack] = requests
+	}
+	return slowest, nil
+}
+
+func decodeSlowest(fileName string, n int, heaps map[string]*slowHeap) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dec := vegeta.NewJSONDecoder(file)
+	for {
+		var res vegeta.Result
+		err := dec.Decode(&res)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("decoding %s: %v", fileName, err)
+		}
+		h, ok := heaps[res.Attack]
+		if !ok {
+			h = &slowHeap{}
+			heaps[res.Attack] = h
+		}
+		if h.Len() == n && (n == 0 || (*h)[0].Latency >= res.Latency) {
+			continue
+		}
+		heap.Push(h, SlowRequest{
+			Attack:      res.Attack,
+			Timestamp:   res.Timestamp,
+			Latency:     res.Latency,
+			Code:        res.Code,
+			Method:      res.Method,
+			URL:         res.URL,
+			TraceID:     res.Headers.Get(TraceIDHeader),
+			OperationID: res.Headers.Get(OperationIDHeader),
+		})
+		if h.Len() > n {
+			heap.Pop(h)
+		}
+	}
+}
+
+// WriteSlowest writes a table with the slowest requests of each test.
+func WriteSlowest(w io.Writer, slowest map[string][]SlowRequest) error {
+	attacks := make([]string, 0, len(slowest))
+	for attack := range slowest {
+		attacks = append(attacks, attack)
+	}
+	sort.Strings(attacks)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "TEST\tLATENCY\tCODE\tTIMESTAMP\tTRACE ID\tOPERATION ID\tURL\n")
+	for _, attack := range attacks {
+		for _, r := range slowest[attack] {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s %s\n",
+				attack, r.Latency, r.Code, r.Timestamp.Format(time.RFC3339), r.TraceID, r.OperationID, r.Method, r.URL)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,130 @@
+package results
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func tracedResult(attack string, latency time.Duration, traceID, operationID string) vegeta.Result {
+	headers := http.Header{}
+	headers.Set(TraceIDHeader, traceID)
+	if operationID != "" {
+		headers.Set(OperationIDHeader, operationID)
+	}
+	return vegeta.Result{Attack: attack, Code: 200, Timestamp: time.Now(), Latency: latency, Method: "GET", URL: "/api", Headers: headers}
+}
+
+func TestNewLabeledEncoder_TraceFields(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewLabeledEncoder(&buf, Identity{Connection: "auth-0"})
+	res := tracedResult("list-clusters", time.Millisecond, "4bf92f3577b34da6a3ce929d0e0e4736", "op-1")
+	if err := enc.Encode(&res); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	res = vegeta.Result{Attack: "list-clusters"}
+	if err := enc.Encode(&res); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for _, want := range []string{`"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`, `"operation_id":"op-1"`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("line %q does not contain %s", lines[0], want)
+		}
+	}
+	if strings.Contains(lines[1], "trace_id") {
+		t.Errorf("line %q has a trace ID without one being sent", lines[1])
+	}
+}
+
+func TestCapturePolicy_KeepsTraceHeaders(t *testing.T) {
+	res := tracedResult("list-clusters", time.Millisecond, "trace", "op")
+	res.Headers.Set("Date", "today")
+	res.Headers.Set("Server", "envoy")
+	CapturePolicy{HeaderAllowList: []string{"Date"}}.Apply(&res)
+	if res.Headers.Get(TraceIDHeader) != "trace" || res.Headers.Get(OperationIDHeader) != "op" || res.Headers.Get("Server") != "" {
+		t.Errorf("headers = %v, want Date and the trace headers", res.Headers)
+	}
+}
+
+func TestNewErrorTraceEncoder(t *testing.T) {
+	traces := NewErrorTraces()
+	for seq, traceID := range []string{"trace-0", "trace-1"} {
+		request, _ := http.NewRequest(http.MethodGet, "/api", nil)
+		request.Header.Set("X-Vegeta-Attack", "list-clusters")
+		request.Header.Set("X-Vegeta-Seq", fmt.Sprint(seq))
+		traces.Record(request, traceID)
+	}
+	request, _ := http.NewRequest(http.MethodGet, "/api", nil)
+	traces.Record(request, "not-an-attack")
+
+	encoded := []*vegeta.Result{}
+	enc := NewErrorTraceEncoder(func(res *vegeta.Result) error {
+		encoded = append(encoded, res)
+		return nil
+	}, traces)
+	failed := vegeta.Result{Attack: "list-clusters", Seq: 1, Error: "context deadline exceeded"}
+	succeeded := tracedResult("list-clusters", time.Millisecond, "trace-sent", "")
+	other := vegeta.Result{Attack: "list-subscriptions", Seq: 0, Error: "EOF"}
+	for _, res := range []*vegeta.Result{&failed, &succeeded, &other} {
+		if err := enc.Encode(res); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+
+	if failed.Headers.Get(TraceIDHeader) != "trace-1" {
+		t.Errorf("failed trace ID = %q, want trace-1", failed.Headers.Get(TraceIDHeader))
+	}
+	if succeeded.Headers.Get(TraceIDHeader) != "trace-sent" {
+		t.Errorf("succeeded trace ID = %q, want the one of its response", succeeded.Headers.Get(TraceIDHeader))
+	}
+	if other.Headers.Get(TraceIDHeader) != "" {
+		t.Errorf("other trace ID = %q, want none", other.Headers.Get(TraceIDHeader))
+	}
+	if len(traces.ids) != 1 {
+		t.Errorf("%d trace IDs left, want only the one of the result not encoded", len(traces.ids))
+	}
+}
+
+func TestSlowestRequests(t *testing.T) {
+	dir := t.TempDir()
+	writeResults(t, filepath.Join(dir, "run1_list-clusters_0.json"),
+		tracedResult("list-clusters", 10*time.Millisecond, "t10", ""),
+		tracedResult("list-clusters", 50*time.Millisecond, "t50", "op50"),
+		tracedResult("list-clusters", 20*time.Millisecond, "t20", ""))
+	writeResults(t, filepath.Join(dir, "run1_list-clusters_1.json"),
+		tracedResult("list-clusters", 40*time.Millisecond, "t40", ""),
+		tracedResult("list-clusters", 5*time.Millisecond, "t5", ""))
+	writeResults(t, filepath.Join(dir, "run1_get-cluster_0.json"),
+		tracedResult("get-cluster", time.Second, "t1s", ""))
+	writeResults(t, filepath.Join(dir, "run2_get-cluster_0.json"),
+		tracedResult("get-cluster", time.Minute, "other-run", ""))
+
+	slowest, err := SlowestRequests(dir, "run1", 2)
+	if err != nil {
+		t.Fatalf("SlowestRequests() error = %v", err)
+	}
+	got := slowest["list-clusters"]
+	if len(got) != 2 || got[0].TraceID != "t50" || got[0].OperationID != "op50" || got[1].TraceID != "t40" {
+		t.Errorf("slowest list-clusters = %+v, want t50 and t40", got)
+	}
+	if got := slowest["get-cluster"]; len(got) != 1 || got[0].TraceID != "t1s" {
+		t.Errorf("slowest get-cluster = %+v, want t1s", got)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSlowest(&buf, slowest); err != nil {
+		t.Fatalf("WriteSlowest() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[1], "t1s") || !strings.Contains(lines[2], "op50") {
+		t.Errorf("WriteSlowest() = %s", buf.String())
+	}
+}
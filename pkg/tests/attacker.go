@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/config"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// attackerConfig holds the vegeta attacker tuning of a test. Zero values keep
+// the attacker defaults.
+type attackerConfig struct {
+	// Workers is the initial number of workers of the attack.
+	Workers int
+	// MaxWorkers caps the number of workers, so a slow server shows up as
+	// latency instead of an unbounded number of in-flight requests.
+	MaxWorkers int
+	// Timeout of every request, no timeout when 0.
+	Timeout time.Duration
+	// Redirects is the maximum number of redirects followed, -1 to not
+	// follow any.
+	Redirects int
+}
+
+// connectionSettings are the transport settings of the connections. The
+// connections are shared by all the tests, so they can't be set per test.
+var connectionSettings = []string{"keep-alive", "http2", "local-address"}
+
+// buildAttackerConfig resolves the attacker tuning of a test, falling back to
+// the global values. The connection settings set for the test are reported as
+// ignored.
+func buildAttackerConfig(ctx context.Context, confHelper *config.ConfigHelper, testName string, defaults attackerConfig, r *Runner) attackerConfig {
+	for _, setting := range connectionSettings {
+		if confHelper.IsSet(fmt.Sprintf("%s.%s", testName, setting)) {
+			r.logger.Warn(ctx, "%s is a global setting of the connections, shared by all the tests. Ignoring it for test %s", setting, testName)
+		}
+	}
+	timeout := defaults.Timeout
+	currentTimeout := confHelper.ResolveStringConfig(ctx, "", fmt.Sprintf("%s.timeout", testName))
+	if currentTimeout != "" {
+		d, err := time.ParseDuration(currentTimeout)
+		if err != nil {
+			r.logger.Warn(ctx, "error parsing timeout for test %s: %s. Using default", testName, err)
+		} else {
+			timeout = d
+		}
+	}
+	return attackerConfig{
+		Workers:    confHelper.ResolveIntConfig(ctx, defaults.Workers, fmt.Sprintf("%s.workers", testName)),
+		MaxWorkers: confHelper.ResolveIntConfig(ctx, defaults.MaxWorkers, fmt.Sprintf("%s.max-workers", testName)),
+		Timeout:    timeout,
+		Redirects:  confHelper.ResolveIntConfig(ctx, defaults.Redirects, fmt.Sprintf("%s.redirects", testName)),
+	}
+}
+
+// options returns the attacker options of the tuning. They must be applied
+// after vegeta.Client, which replaces the whole HTTP client.
+func (c attackerConfig) options() []func(*vegeta.Attacker) {
+	opts := []func(*vegeta.Attacker){}
+	if c.Workers > 0 {
+		opts = append(opts, vegeta.Workers(uint64(c.Workers)))
+	}
+	if c.MaxWorkers > 0 {
+		opts = append(opts, vegeta.MaxWorkers(uint64(c.MaxWorkers)))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, vegeta.Timeout(c.Timeout))
+	}
+	if c.Redirects != 0 {
+		opts = append(opts, vegeta.Redirects(c.Redirects))
+	}
+	return opts
+}
+
+// client returns an HTTP client with the timeout and redirects of the
+// attacker, for the handlers sending their own requests instead of attacking.
+func (c attackerConfig) client(transport http.RoundTripper) *http.Client {
+	client := &http.Client{Transport: transport, Timeout: c.Timeout}
+	if c.Redirects != 0 {
+		redirects := c.Redirects
+		client.CheckRedirect = func(_ *http.Request, via []*http.Request) error {
+			if redirects == vegeta.NoFollow {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > redirects {
+				return fmt.Errorf("stopped after %d redirects", redirects)
+			}
+			return nil
+		}
+	}
+	return client
+}
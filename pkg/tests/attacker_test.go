@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/config"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func Test_buildAttackerConfig(t *testing.T) {
+	conf := viper.New()
+	conf.Set("slow-test", map[string]interface{}{"max-workers": 5, "timeout": "2m", "redirects": -1})
+	conf.Set("broken-test", map[string]interface{}{"timeout": "soon"})
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	confHelper := config.NewConfigHelper(logger, conf)
+	r := &Runner{logger: logger}
+	defaults := attackerConfig{Workers: 10, MaxWorkers: 50, Timeout: 30 * time.Second, Redirects: 10}
+
+	tests := []struct {
+		name     string
+		testName string
+		want     attackerConfig
+	}{
+		{"defaults", "other-test", defaults},
+		{"overrides", "slow-test", attackerConfig{Workers: 10, MaxWorkers: 5, Timeout: 2 * time.Minute, Redirects: -1}},
+		{"invalid timeout", "broken-test", defaults},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildAttackerConfig(context.TODO(), confHelper, tt.testName, defaults, r)
+			if got != tt.want {
+				t.Errorf("buildAttackerConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_attackerConfig_options(t *testing.T) {
+	var (
+		mu            sync.Mutex
+		inFlight, max int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > max {
+			max = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	attack := func(c attackerConfig) []*vegeta.Result {
+		opts := append([]func(*vegeta.Attacker){vegeta.Client(&http.Client{})}, c.options()...)
+		attacker := vegeta.NewAttacker(opts...)
+		targeter := vegeta.NewStaticTargeter(vegeta.Target{Method: http.MethodGet, URL: server.URL})
+		results := []*vegeta.Result{}
+		for res := range attacker.Attack(targeter, vegeta.Rate{Freq: 100, Per: time.Second}, 300*time.Millisecond, "tuning") {
+			results = append(results, res)
+		}
+		return results
+	}
+
+	results := attack(attackerConfig{Workers: 1, MaxWorkers: 2})
+	if max > 2 {
+		t.Errorf("concurrent requests = %d, want at most 2 with max-workers 2", max)
+	}
+	if len(results) >= 30 {
+		t.Errorf("sent %d requests, want the rate held back by max-workers", len(results))
+	}
+
+	results = attack(attackerConfig{Timeout: 10 * time.Millisecond, MaxWorkers: 1})
+	for _, res := range results {
+		if !strings.Contains(res.Error, "Client.Timeout") {
+			t.Errorf("result error = %q, want a client timeout", res.Error)
+		}
+	}
+
+	if opts := (attackerConfig{}).options(); len(opts) != 0 {
+		t.Errorf("options() = %d options, want none for the zero config", len(opts))
+	}
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/elastic"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/spf13/viper"
+)
+
+// recordProfile stores the rate profile of a test, used to find runs with the
+// same load to compare with.
+func (r *Runner) recordProfile(testName, profile string) {
+	r.profilesMu.Lock()
+	defer r.profilesMu.Unlock()
+	r.profiles[testName] = profile
+}
+
+// Profiles returns the rate profile of each executed test.
+func (r *Runner) Profiles() map[string]string {
+	r.profilesMu.Lock()
+	defer r.profilesMu.Unlock()
+	profiles := make(map[string]string, len(r.profiles))
+	for testName, profile := range r.profiles {
+		profiles[testName] = profile
+	}
+	return profiles
+}
+
+// ServerVersion returns the version of the server the tests are sent to.
+func (r *Runner) ServerVersion(ctx context.Context) string {
+	if len(r.clients) == 0 {
+		return r.version
+	}
+	return helpers.GetServerVersion(ctx, r.clients[0].Connection)
+}
+
+// Collected records the rate profiles and the server version of a run executed
+// by agents, whose results were collected in the output directory, so Report
+// can look up its baseline.
+func (r *Runner) Collected(profiles map[string]string, version string) {
+	for testName, profile := range profiles {
+		r.recordProfile(testName, profile)
+	}
+	r.version = version
+}
+
+// compareWithBaseline looks up in Elasticsearch the most recent passing run
+// with the same tests and rate profile, compares this run against it and
+// stores this run with its verdict so it can become the next baseline.
+func (r *Runner) compareWithBaseline(ctx context.Context) error {
+	if len(r.profiles) == 0 {
+		return nil
+	}
+	version := r.ServerVersion(ctx)
+	run := elastic.NewRunDocument(r.testID, version, r.profiles)
+	run.Verdict = elastic.VerdictNoBaseline
+
+	baselineRun, err := elastic.FindBaselineRun(ctx, run, r.logger)
+	if err != nil {
+		r.logger.Error(ctx, "looking up baseline run: %s", err)
+		return nil
+	}
+	if baselineRun == nil {
+		r.logger.Info(ctx, "No baseline run found for rate profile %s", run.RateProfile)
+	} else {
+		r.logger.Info(ctx, "Comparing with baseline run %s (version %s)", baselineRun.Uuid, baselineRun.Version)
+		comparison, err := r.compare(ctx, baselineRun.Uuid)
+		if err != nil {
+			r.logger.Error(ctx, "comparing with baseline run %s: %s", baselineRun.Uuid, err)
+			return nil
+		}
+		run.Baseline = baselineRun.Uuid
+		run.Verdict = elastic.VerdictPass
+		if comparison.Regressed() {
+			run.Verdict = elastic.VerdictFail
+		}
+		r.logger.Info(ctx, "Verdict against baseline %s: %s", baselineRun.Uuid, run.Verdict)
+	}
+
+	err = elastic.IndexRun(ctx, run, r.logger)
+	if err != nil {
+		r.logger.Error(ctx, "indexing run: %s", err)
+	}
+	if run.Verdict == elastic.VerdictFail && viper.GetBool("fail-on-regression") {
+		return fmt.Errorf("regressions detected against baseline run %s", run.Baseline)
+	}
+	return nil
+}
+
+// compare compares the local results of this run with the indexed results of
+// the baseline and writes the report to the output directory.
+func (r *Runner) compare(ctx context.Context, baselineID string) (*results.Comparison, error) {
+	candidate, err := results.LoadDir(r.outputDirectory, r.testID)
+	if err != nil {
+		return nil, err
+	}
+	collector := results.NewCollector()
+	err = elastic.SearchResults(ctx, baselineID, r.logger, collector.Add)
+	if err != nil {
+		return nil, err
+	}
+	comparison := results.Compare(collector.Summaries(), candidate, results.ConfiguredTolerances())
+
+	reportName := filepath.Join(r.outputDirectory, fmt.Sprintf("%s_baseline_report.txt", r.testID))
+	report, err := os.Create(reportName)
+	if err != nil {
+		return nil, fmt.Errorf("writing report: %v", err)
+	}
+	defer report.Close()
+	err = comparison.Write(report)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Info(ctx, "Baseline report written to: %s", reportName)
+	return comparison, nil
+}
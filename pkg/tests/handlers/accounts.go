@@ -6,13 +6,15 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	sdk "github.com/openshift-online/ocm-sdk-go"
 	v1 "github.com/openshift-online/ocm-sdk-go/accountsmgmt/v1"
 	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
 	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
@@ -26,7 +28,7 @@ func TestRegisterNewCluster(ctx context.Context, options *types.TestOptions) err
 	// building valid HTTP Requests
 	targeter := generateClusterRegistrationTargeter(ctx, options)
 
-	for res := range options.Attacker.Attack(targeter, options.Rate, options.Duration, testName) {
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, testName) {
 		options.Encoder.Encode(res)
 	}
 
@@ -34,17 +36,27 @@ func TestRegisterNewCluster(ctx context.Context, options *types.TestOptions) err
 	return nil
 }
 
+// TestRegisterExistingCluster performs a load test re-registering a pool of
+// clusters registered before the attack. The pool is registered at the first
+// ramp step and reused by the following ones.
 func TestRegisterExistingCluster(ctx context.Context, options *types.TestOptions) error {
 
 	testName := options.TestName
-	quantity := options.Rate.Freq
-	targeter := generateClusterReRegistrationTargeter(ctx, quantity, options)
+	pool, err := reRegistrationPool(ctx, options)
+	if err != nil {
+		helpers.Cleanup(ctx, options.Connection)
+		return err
+	}
+	targeter := generateClusterReRegistrationTargeter(pool, options)
 
-	for res := range options.Attacker.Attack(targeter, options.Rate, options.Duration, testName) {
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, testName) {
 		options.Encoder.Encode(res)
 	}
 
-	helpers.Cleanup(ctx, options.Connection)
+	if options.LastStep() {
+		releaseReRegistrationPool(options)
+		helpers.Cleanup(ctx, options.Connection)
+	}
 	return nil
 }
 
@@ -54,6 +66,7 @@ func getAuthorizationToken(ctx context.Context, options *types.TestOptions) stri
 	result, err := options.Connection.AccountsMgmt().V1().AccessToken().Post().Send()
 	if err != nil {
 		options.Logger.Error(ctx, "Unable to retrieve authorization token: %s", err)
+		return ""
 	}
 	body := result.Body().Auths()
 	token := body["cloud.openshift.com"].Auth()
@@ -65,54 +78,112 @@ func getAuthorizationToken(ctx context.Context, options *types.TestOptions) stri
 	return token
 }
 
-// generateClusterReRegistrationTargeter registers fake clusters and then
-// returns a targeter which uses those fake clusters.
-func generateClusterReRegistrationTargeter(ctx context.Context, qty int, options *types.TestOptions) vegeta.Targeter {
-
-	clusterIds := make([]string, qty)
-	var currentTarget = 0
+const (
+	defaultReRegistrationSeedWorkers = 5
+	defaultReRegistrationSeedRate    = 5
+)
 
-	// Cache the Authorization Token to avoid retrieving it with every request
-	var authorizationToken = ""
-	if len(authorizationToken) == 0 {
-		authorizationToken = getAuthorizationToken(ctx, options)
-	}
+// reRegistrationClusters is a pool of registered clusters along with the
+// authorization token used to register them.
+type reRegistrationClusters struct {
+	authorizationToken string
+	clusterIDs         []string
+}
 
-	// Register multiple mock clusters and store their IDs
-	options.Logger.Info(ctx, "Registering %d clusters to use for re-registration test", qty)
-	for i := range clusterIds {
+type reRegistrationPoolKey struct {
+	testName   string
+	connection *sdk.Connection
+}
 
-		clusterID := uuid.NewV4().String()
+// reRegistrationPools keeps the pool of each connection across ramp steps.
+var (
+	reRegistrationPools   = map[reRegistrationPoolKey]*reRegistrationClusters{}
+	reRegistrationPoolsMu sync.Mutex
+)
 
-		body, err := v1.NewClusterRegistrationRequest().AuthorizationToken(authorizationToken).ClusterID(clusterID).Build()
-		if err != nil {
-			options.Logger.Fatal(ctx, "Unable to build cluster registration request: %v", err)
-		}
+// reRegistrationPool returns the pool of registered clusters of the
+// connection, registering `pool-size` clusters the first time. Clusters are
+// registered concurrently by `seed-workers` at up to `seed-rate` per second.
+func reRegistrationPool(ctx context.Context, options *types.TestOptions) (*reRegistrationClusters, error) {
+	key := reRegistrationPoolKey{testName: options.TestName, connection: options.Connection}
+	reRegistrationPoolsMu.Lock()
+	pool, ok := reRegistrationPools[key]
+	reRegistrationPoolsMu.Unlock()
+	if ok {
+		options.Logger.Info(ctx, "Reusing %d registered clusters for %s test", len(pool.clusterIDs), options.TestName)
+		return pool, nil
+	}
 
-		var rawBody bytes.Buffer
-		err = v1.MarshalClusterRegistrationRequest(body, &rawBody)
-		if err != nil {
-			options.Logger.Fatal(ctx, "Unable to serialize cluster registration request body: ", err)
-		}
+	poolSize := viper.GetInt(fmt.Sprintf("tests.%s.pool-size", options.TestName))
+	if poolSize <= 0 {
+		poolSize = defaultClusterPoolSize
+	}
+	workers := viper.GetInt(fmt.Sprintf("tests.%s.seed-workers", options.TestName))
+	if workers <= 0 {
+		workers = defaultReRegistrationSeedWorkers
+	}
+	seedRate := viper.GetInt(fmt.Sprintf("tests.%s.seed-rate", options.TestName))
+	if seedRate <= 0 {
+		seedRate = defaultReRegistrationSeedRate
+	}
 
-		resp, err := options.Connection.AccountsMgmt().V1().ClusterRegistrations().Post().Request(body).Send()
-		if err != nil {
-			options.Logger.Fatal(ctx, "Unable to register cluster: ", err)
-		}
+	authorizationToken := getAuthorizationToken(ctx, options)
+	options.Logger.Info(ctx, "Registering %d clusters to use for %s test, %d at a time at %d/s",
+		poolSize, options.TestName, workers, seedRate)
+	clusterIDs, failures := seedPool(ctx, "registered clusters", poolSize, workers,
+		vegeta.Rate{Freq: seedRate, Per: time.Second}, options.Logger,
+		func(ctx context.Context, i int) (string, error) {
+			return registerCluster(ctx, options, authorizationToken)
+		})
+	if len(clusterIDs) == 0 {
+		return nil, fmt.Errorf("no clusters could be registered for %s test", options.TestName)
+	}
+	if failures > 0 {
+		options.Logger.Warn(ctx, "%d of %d clusters failed to register, using %d clusters", failures, poolSize, len(clusterIDs))
+	}
 
-		options.Logger.Info(ctx, "[%d/%d] Registered Cluster: '%s'. Response: %d\n", i, len(clusterIds), clusterID, resp.Status())
-		clusterIds[i] = clusterID
+	pool = &reRegistrationClusters{authorizationToken: authorizationToken, clusterIDs: clusterIDs}
+	reRegistrationPoolsMu.Lock()
+	reRegistrationPools[key] = pool
+	reRegistrationPoolsMu.Unlock()
+	return pool, nil
+}
 
-		// Avoid hitting rate limiting
-		time.Sleep(time.Second * 1)
+// releaseReRegistrationPool forgets the pool of the connection, the clusters
+// are removed by the cleanup.
+func releaseReRegistrationPool(options *types.TestOptions) {
+	reRegistrationPoolsMu.Lock()
+	defer reRegistrationPoolsMu.Unlock()
+	delete(reRegistrationPools, reRegistrationPoolKey{testName: options.TestName, connection: options.Connection})
+}
 
+// registerCluster registers a fake cluster and returns its ID.
+func registerCluster(ctx context.Context, options *types.TestOptions, authorizationToken string) (string, error) {
+	clusterID := uuid.NewV4().String()
+	body, err := v1.NewClusterRegistrationRequest().AuthorizationToken(authorizationToken).ClusterID(clusterID).Build()
+	if err != nil {
+		return "", fmt.Errorf("building cluster registration request: %v", err)
+	}
+	resp, err := options.Connection.AccountsMgmt().V1().ClusterRegistrations().Post().Request(body).SendContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("registering cluster: %v", err)
 	}
+	if resp.Status() != http.StatusCreated && resp.Status() != http.StatusOK {
+		return "", fmt.Errorf("registering cluster: expected response code 201, instead found: %d", resp.Status())
+	}
+	return clusterID, nil
+}
+
+// generateClusterReRegistrationTargeter returns a targeter which re-registers
+// the clusters of the pool in round-robin.
+func generateClusterReRegistrationTargeter(pool *reRegistrationClusters, options *types.TestOptions) vegeta.Targeter {
+	pick := poolPicker("", len(pool.clusterIDs))
 
 	targeter := func(t *vegeta.Target) error {
 
-		clusterId := clusterIds[currentTarget]
+		clusterId := pool.clusterIDs[pick()]
 
-		body, err := v1.NewClusterRegistrationRequest().AuthorizationToken(authorizationToken).ClusterID(clusterId).Build()
+		body, err := v1.NewClusterRegistrationRequest().AuthorizationToken(pool.authorizationToken).ClusterID(clusterId).Build()
 		if err != nil {
 			return err
 		}
@@ -127,12 +198,6 @@ func generateClusterReRegistrationTargeter(ctx context.Context, qty int, options
 		t.URL = options.Path
 		t.Body = rawBody.Bytes()
 
-		// Loop through Cluster IDs
-		currentTarget = currentTarget + 1
-		if currentTarget > (qty - 1) {
-			currentTarget = 0
-		}
-
 		return nil
 	}
 
@@ -178,47 +243,30 @@ func generateClusterRegistrationTargeter(ctx context.Context, options *types.Tes
 
 // Test quota cost
 func TestQuotaCost(ctx context.Context, options *types.TestOptions) error {
+	return TestOrganizationEndpoint(ctx, options)
+}
 
-	conn := options.Connection
+// Test Cluster Authorizations
+func TestClusterAuthorizations(ctx context.Context, options *types.TestOptions) error {
 
-	acct, err := conn.AccountsMgmt().V1().CurrentAccount().Get().Send()
+	matrix, err := authorizationMatrixConfig(options.TestName)
 	if err != nil {
 		return err
 	}
 
-	org, ok := acct.Body().GetOrganization()
-	if !ok {
-		return fmt.Errorf("no organizations where found for this account")
-	}
-
-	orgID, ok := org.GetID()
-	if !ok {
-		return fmt.Errorf("no organizations where found for this account")
-	}
-
-	options.Logger.Info(ctx, "Using Organization id: %s.", orgID)
-	options.Path = strings.Replace(options.Path, "{orgId}", orgID, 1)
-
-	return TestStaticEndpoint(ctx, options)
-
-}
-
-// Test Cluster Authorizations
-func TestClusterAuthorizations(ctx context.Context, options *types.TestOptions) error {
-
 	targeter := func(t *vegeta.Target) error {
 
 		// Each Cluster uses a UUID to ensure uniqueness
 		clusterId := uuid.NewV4().String()
 		t.Method = http.MethodPost
 		t.URL = options.Path
-		t.Body = clusterAuthorizationsBody(ctx, clusterId, options)
+		t.Body = clusterAuthorizationsBody(ctx, clusterId, matrix, options)
 
 		return nil
 	}
 
 	// Execute the HTTP Requests; repeating as needed to meet the specified duration
-	for res := range options.Attacker.Attack(targeter, options.Rate, options.Duration, options.TestName) {
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, options.TestName) {
 		options.Encoder.Encode(res)
 	}
 
@@ -240,24 +288,26 @@ func randomizeCount() int {
 	return count
 }
 
-func clusterAuthorizationsBody(ctx context.Context, clusterID string, options *types.TestOptions) []byte {
+// clusterAuthorizationsBody builds a cluster authorization request sampling
+// every field from the matrix.
+func clusterAuthorizationsBody(ctx context.Context, clusterID string, matrix authorizationMatrix, options *types.TestOptions) []byte {
 	rand.Seed(time.Now().UnixNano())
 	buff := &bytes.Buffer{}
 	reservedResource := v1.NewReservedResource().
-		ResourceName(randomizeResourceName()).
-		ResourceType(helpers.AWSComputeNodeResourceType).
-		Count(randomizeCount()).
-		BillingModel(helpers.StandardBillingModel)
+		ResourceName(matrix.ResourceName()).
+		ResourceType(matrix.String(matrixResourceType, helpers.AWSComputeNodeResourceType)).
+		Count(matrix.Count()).
+		BillingModel(v1.BillingModel(matrix.String(matrixBillingModel, helpers.StandardBillingModel)))
 
 	clusterAuthReq, err := v1.NewClusterAuthorizationRequest().
 		ClusterID(clusterID).
-		ProductID(helpers.OsdProductID).
-		CloudProviderID(helpers.AWSCloudProvider).
-		AccountUsername(helpers.ClusterAuthAccountUsername).
-		Managed(helpers.ClusterAuthManaged).
-		Reserve(helpers.ClusterAuthReserve).
-		BYOC(helpers.ClusterAuthBYOC).
-		AvailabilityZone(helpers.SingleAvailabilityZone).
+		ProductID(matrix.String(matrixProductID, helpers.OsdProductID)).
+		CloudProviderID(matrix.String(matrixCloudProvider, helpers.AWSCloudProvider)).
+		AccountUsername(matrix.String(matrixAccountUsername, helpers.ClusterAuthAccountUsername)).
+		Managed(matrix.Bool(matrixManaged, helpers.ClusterAuthManaged)).
+		Reserve(matrix.Bool(matrixReserve, helpers.ClusterAuthReserve)).
+		BYOC(matrix.Bool(matrixBYOC, helpers.ClusterAuthBYOC)).
+		AvailabilityZone(matrix.String(matrixAvailabilityZone, helpers.SingleAvailabilityZone)).
 		Resources(reservedResource).
 		Build()
 	if err != nil {
@@ -3,12 +3,14 @@ package handlers
 import (
 	"context"
 	"math/rand"
+	"net/http"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
 func Test_clusterAuthorizationsBody(t *testing.T) {
@@ -32,7 +34,7 @@ func Test_clusterAuthorizationsBody(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := clusterAuthorizationsBody(tt.args.ctx, tt.args.clusterID, tt.args.options)
+			got := clusterAuthorizationsBody(tt.args.ctx, tt.args.clusterID, defaultAuthorizationMatrix, tt.args.options)
 			if got == nil {
 				t.Errorf("clusterAuthorizationsBody() = %v", got)
 			} else {
@@ -98,3 +100,21 @@ func Test_randomizeResourceName(t *testing.T) {
 		})
 	}
 }
+
+func Test_generateClusterReRegistrationTargeter(t *testing.T) {
+	pool := &reRegistrationClusters{authorizationToken: "token", clusterIDs: []string{"c1", "c2", "c3"}}
+	targeter := generateClusterReRegistrationTargeter(pool, &types.TestOptions{Path: "/api/accounts_mgmt/v1/cluster_registrations"})
+
+	for i, want := range []string{"c1", "c2", "c3", "c1"} {
+		var target vegeta.Target
+		if err := targeter(&target); err != nil {
+			t.Fatalf("targeter() error = %v", err)
+		}
+		if !strings.Contains(string(target.Body), want) {
+			t.Errorf("target %d body = %s, want cluster %s", i, target.Body, want)
+		}
+		if target.Method != http.MethodPost || target.URL != "/api/accounts_mgmt/v1/cluster_registrations" {
+			t.Errorf("target %d = %s %s", i, target.Method, target.URL)
+		}
+	}
+}
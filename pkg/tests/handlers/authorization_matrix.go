@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Fields of the cluster authorizations matrix, configured under
+// `tests.<name>.matrix`.
+const (
+	matrixProductID        = "product-id"
+	matrixCloudProvider    = "cloud-provider"
+	matrixBillingModel     = "billing-model"
+	matrixAvailabilityZone = "availability-zone"
+	matrixAccountUsername  = "account-username"
+	matrixBYOC             = "byoc"
+	matrixManaged          = "managed"
+	matrixReserve          = "reserve"
+	matrixResourceName     = "resource-name"
+	matrixResourceType     = "resource-type"
+	matrixCount            = "count"
+)
+
+var (
+	authorizationMatrixFields = []string{
+		matrixProductID, matrixCloudProvider, matrixBillingModel, matrixAvailabilityZone, matrixAccountUsername,
+		matrixBYOC, matrixManaged, matrixReserve, matrixResourceName, matrixResourceType, matrixCount,
+	}
+	authorizationMatrixBoolFields = map[string]bool{
+		matrixBYOC:    true,
+		matrixManaged: true,
+		matrixReserve: true,
+	}
+)
+
+// authorizationMatrix holds the distribution of the values of each field of a
+// cluster authorization request. Fields that are not configured use the
+// values in pkg/helpers/constants.go.
+type authorizationMatrix map[string]weightedValues
+
+// authorizationMatrixConfig reads the distributions under `tests.<name>.matrix`.
+func authorizationMatrixConfig(testName string) (authorizationMatrix, error) {
+	matrix := authorizationMatrix{}
+	for _, field := range authorizationMatrixFields {
+		key := fmt.Sprintf("tests.%s.matrix.%s", testName, field)
+		if !viper.IsSet(key) {
+			continue
+		}
+		values, err := parseWeightedValues(viper.Get(key))
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", key, err)
+		}
+		if values.totalWeight() == 0 {
+			return nil, fmt.Errorf("parsing %s: at least one value with a positive weight is required", key)
+		}
+		for _, v := range values {
+			if authorizationMatrixBoolFields[field] {
+				_, err = strconv.ParseBool(v.value)
+			} else if field == matrixCount {
+				_, _, err = parseCountRange(v.value)
+			} else if v.value == "" {
+				err = fmt.Errorf("empty value")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s: '%s': %v", key, v.value, err)
+			}
+		}
+		matrix[field] = values
+	}
+	return matrix, nil
+}
+
+func (m authorizationMatrix) String(field, def string) string {
+	values, ok := m[field]
+	if !ok {
+		return def
+	}
+	return values.pick()
+}
+
+func (m authorizationMatrix) Bool(field string, def bool) bool {
+	values, ok := m[field]
+	if !ok {
+		return def
+	}
+	value, _ := strconv.ParseBool(values.pick())
+	return value
+}
+
+func (m authorizationMatrix) ResourceName() string {
+	if _, ok := m[matrixResourceName]; !ok {
+		return randomizeResourceName()
+	}
+	return m.String(matrixResourceName, "")
+}
+
+// Count picks a count range and returns a random count within it.
+func (m authorizationMatrix) Count() int {
+	values, ok := m[matrixCount]
+	if !ok {
+		return randomizeCount()
+	}
+	min, max, _ := parseCountRange(values.pick())
+	return min + rand.Intn(max-min+1)
+}
+
+// parseCountRange parses a count, e.g. `4`, or an inclusive range of counts,
+// e.g. `4-10`.
+func parseCountRange(value string) (int, int, error) {
+	parts := strings.SplitN(value, "-", 2)
+	min, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	max := min
+	if len(parts) == 2 {
+		max, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if min < 0 || max < min {
+		return 0, 0, fmt.Errorf("invalid count range")
+	}
+	return min, max, nil
+}
+
+// defaultAuthorizationMatrix uses the values in pkg/helpers/constants.go for
+// every field.
+var defaultAuthorizationMatrix = authorizationMatrix{}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	"github.com/spf13/viper"
+)
+
+func Test_parseCountRange(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max int
+		wantErr  bool
+	}{
+		{"4", 4, 4, false},
+		{"4-10", 4, 10, false},
+		{" 1 - 2 ", 1, 2, false},
+		{"10-4", 0, 0, true},
+		{"-1", 0, 0, true},
+		{"a-b", 0, 0, true},
+		{"", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			min, max, err := parseCountRange(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCountRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if min != tt.min || max != tt.max {
+				t.Errorf("parseCountRange() = %d, %d, want %d, %d", min, max, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func Test_authorizationMatrixConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		matrix  map[string]interface{}
+		wantErr bool
+	}{
+		{"empty", map[string]interface{}{}, false},
+		{"valid", map[string]interface{}{
+			"product-id": []interface{}{"osd", map[string]interface{}{"value": "rosa", "weight": 3}},
+			"byoc":       []interface{}{true, false},
+			"count":      []interface{}{"1-3", 8},
+		}, false},
+		{"invalid bool", map[string]interface{}{"reserve": []interface{}{"maybe"}}, true},
+		{"invalid count", map[string]interface{}{"count": []interface{}{"many"}}, true},
+		{"empty value", map[string]interface{}{"cloud-provider": []interface{}{""}}, true},
+		{"empty list", map[string]interface{}{"count": []interface{}{}}, true},
+		{"zero weights", map[string]interface{}{"product-id": []interface{}{map[string]interface{}{"value": "osd", "weight": 0}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set("tests.cluster-authorizations.matrix", tt.matrix)
+			_, err := authorizationMatrixConfig("cluster-authorizations")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("authorizationMatrixConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_clusterAuthorizationsBody_matrix(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("tests.cluster-authorizations.matrix", map[string]interface{}{
+		"product-id":        []interface{}{"rosa"},
+		"cloud-provider":    []interface{}{"gcp"},
+		"billing-model":     []interface{}{"marketplace"},
+		"availability-zone": []interface{}{"multi"},
+		"byoc":              []interface{}{false},
+		"reserve":           []interface{}{map[string]interface{}{"value": true, "weight": 0}, false},
+		"resource-name":     []interface{}{"n1-standard-4"},
+		"resource-type":     []interface{}{"compute.node.gcp"},
+		"count":             []interface{}{"2-3"},
+	})
+	matrix, err := authorizationMatrixConfig("cluster-authorizations")
+	if err != nil {
+		t.Fatalf("authorizationMatrixConfig() error = %v", err)
+	}
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+
+	for i := 0; i < 10; i++ {
+		body, err := helpers.Parse(clusterAuthorizationsBody(context.TODO(), "cluster-1", matrix, &types.TestOptions{Logger: logger}))
+		if err != nil {
+			t.Fatalf("parsing body: %v", err)
+		}
+		if body["product_id"] != "rosa" || body["cloud_provider_id"] != "gcp" || body["availability_zone"] != "multi" ||
+			body["byoc"] != false || body["reserve"] != false {
+			t.Errorf("clusterAuthorizationsBody() = %v", body)
+		}
+		if body["account_username"] != helpers.ClusterAuthAccountUsername {
+			t.Errorf("account_username = %v, want the default %s", body["account_username"], helpers.ClusterAuthAccountUsername)
+		}
+		resources, _ := body["resources"].([]interface{})
+		if len(resources) != 1 {
+			t.Fatalf("resources = %v", body["resources"])
+		}
+		resource := resources[0].(map[string]interface{})
+		count, _ := resource["count"].(float64)
+		if resource["resource_name"] != "n1-standard-4" || resource["resource_type"] != "compute.node.gcp" ||
+			resource["billing_model"] != "marketplace" || count < 2 || count > 3 {
+			t.Errorf("resource = %v", resource)
+		}
+	}
+}
@@ -4,6 +4,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
@@ -11,15 +17,35 @@ import (
 	"github.com/spf13/viper"
 
 	v1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	uuid "github.com/satori/go.uuid"
 	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
+const (
+	// defaultClusterPoolSize is the number of clusters created for the tests
+	// reading existing clusters when `pool-size` is not configured.
+	defaultClusterPoolSize = 10
+
+	// Defaults of the seeding of the cluster pool
+	defaultClusterPoolSeedWorkers = 5
+	defaultClusterPoolSeedRate    = 5
+
+	// randomSelection picks a random element of a pool for each request,
+	// otherwise they are used in round-robin.
+	randomSelection = "random"
+
+	// Defaults of the cluster lifecycle test
+	defaultTargetState  = "ready"
+	defaultPollInterval = 5 * time.Second
+	defaultPollTimeout  = 10 * time.Minute
+)
+
 func TestCreateCluster(ctx context.Context, options *types.TestOptions) error {
 
 	testName := options.TestName
 	targeter := generateCreateClusterTargeter(ctx, options.ID, options.Method, options.Path, options.Logger)
 
-	for res := range options.Attacker.Attack(targeter, options.Rate, options.Duration, testName) {
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, testName) {
 		options.Encoder.Encode(res)
 	}
 
@@ -38,6 +64,34 @@ func generateCreateClusterTargeter(ctx context.Context, ID, method, url string,
 	// ^[a-z]([-a-z0-9]*[a-z0-9])?$
 	id := ID[:4]
 
+	creds := getCCSCredentials(ctx, log)
+
+	targeter := func(t *vegeta.Target) error {
+		body, err := fakeClusterBody(fmt.Sprintf("pocm-%s-%d", id, idx), creds)
+		if err != nil {
+			return err
+		}
+
+		t.Method = method
+		t.URL = url
+		t.Body = body
+
+		idx += 1
+		return nil
+	}
+	return targeter
+}
+
+// ccsCredentials are the AWS credentials used to create CCS fake clusters.
+type ccsCredentials struct {
+	region    string
+	accessKey string
+	secretKey string
+	accountID string
+}
+
+// getCCSCredentials returns the AWS credentials of the configuration.
+func getCCSCredentials(ctx context.Context, log logging.Logger) ccsCredentials {
 	awsCreds := viper.Get("aws").([]interface{})
 	if len(awsCreds) < 1 {
 		log.Fatal(ctx, "No aws credentials found")
@@ -46,48 +100,226 @@ func generateCreateClusterTargeter(ctx context.Context, ID, method, url string,
 	// CCS is used to create fake clusters within the AWS
 	// environment supplied by the user executing this test.
 	// Not fully supporting multi account now, so using first accaunt always
-	ccsRegion := awsCreds[0].(map[string]interface{})["region"].(string)
-	ccsAccessKey := awsCreds[0].(map[string]interface{})["access-key"].(string)
-	ccsSecretKey := awsCreds[0].(map[string]interface{})["secret-access-key"].(string)
-	ccsAccountID := awsCreds[0].(map[string]interface{})["account-id"].(string)
+	return ccsCredentials{
+		region:    awsCreds[0].(map[string]interface{})["region"].(string),
+		accessKey: awsCreds[0].(map[string]interface{})["access-key"].(string),
+		secretKey: awsCreds[0].(map[string]interface{})["secret-access-key"].(string),
+		accountID: awsCreds[0].(map[string]interface{})["account-id"].(string),
+	}
+}
 
-	targeter := func(t *vegeta.Target) error {
-		fakeClusterProps := map[string]string{
-			"fake_cluster": "true",
+// fakeClusterBody builds the body of a "fake cluster" creation request.
+func fakeClusterBody(name string, creds ccsCredentials) ([]byte, error) {
+	fakeClusterProps := map[string]string{
+		"fake_cluster": "true",
+	}
+	awsTags := map[string]string{
+		"User": "pocm-perf",
+	}
+	body, err := v1.NewCluster().
+		Name(name).
+		Properties(fakeClusterProps).
+		MultiAZ(true).
+		Region(v1.NewCloudRegion().ID(creds.region)).
+		CCS(v1.NewCCS().Enabled(true)).
+		AWS(
+			v1.NewAWS().
+				AccessKeyID(creds.accessKey).
+				SecretAccessKey(creds.secretKey).
+				AccountID(creds.accountID).
+				Tags(awsTags),
+		).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	var raw bytes.Buffer
+	err = v1.MarshalCluster(body, &raw)
+	if err != nil {
+		return nil, err
+	}
+	return raw.Bytes(), nil
+}
+
+// TestGetCluster performs a load test on "GET /api/clusters_mgmt/v1/clusters/{id}"
+// reading back a pool of fake clusters created before the attack. The clusters
+// are removed by the cleanup at the end of the test.
+func TestGetCluster(ctx context.Context, options *types.TestOptions) error {
+	return testClusterPool(ctx, options, func(clusterIDs []string) vegeta.Targeter {
+		return generateClusterPoolTargeter(options.Method, options.Path, clusterPoolSelection(options), clusterIDs)
+	})
+}
+
+// seedClusterPool creates qty fake clusters, `seed-workers` at a time at up to
+// `seed-rate` per second, and returns the IDs of the ones created. Every
+// cluster is tracked for cleanup by the CleanTestTransport.
+func seedClusterPool(ctx context.Context, qty int, options *types.TestOptions) ([]string, error) {
+	workers := viper.GetInt(fmt.Sprintf("tests.%s.seed-workers", options.TestName))
+	if workers <= 0 {
+		workers = defaultClusterPoolSeedWorkers
+	}
+	seedRate := viper.GetInt(fmt.Sprintf("tests.%s.seed-rate", options.TestName))
+	if seedRate <= 0 {
+		seedRate = defaultClusterPoolSeedRate
+	}
+	// Cluster names must be unique across connections, so a random suffix is
+	// added to the test ID prefix.
+	id := options.ID[:4]
+	creds := getCCSCredentials(ctx, options.Logger)
+
+	options.Logger.Info(ctx, "Creating %d clusters to use for %s test, %d at a time at %d/s",
+		qty, options.TestName, workers, seedRate)
+	clusterIDs, failures := seedPool(ctx, "clusters", qty, workers,
+		vegeta.Rate{Freq: seedRate, Per: time.Second}, options.Logger,
+		func(ctx context.Context, i int) (string, error) {
+			body, err := fakeClusterBody(fmt.Sprintf("pocm-%s-%s", id, uuid.NewV4().String()[:4]), creds)
+			if err != nil {
+				return "", err
+			}
+			clusterID, _, err := helpers.CreateCluster(ctx, string(body), options.Connection)
+			return clusterID, err
+		})
+	if len(clusterIDs) == 0 {
+		return nil, fmt.Errorf("no clusters could be created for %s test", options.TestName)
+	}
+	if failures > 0 {
+		options.Logger.Warn(ctx, "%d of %d clusters failed to be created, using %d clusters", failures, qty, len(clusterIDs))
+	}
+	return clusterIDs, nil
+}
+
+// generateClusterPoolTargeter returns a targeter that replaces `{clusterId}`
+// in the url with the IDs of the pool, picked randomly or in round-robin.
+func generateClusterPoolTargeter(method, url, selection string, clusterIDs []string) vegeta.Targeter {
+	pick := poolPicker(selection, len(clusterIDs))
+	return func(t *vegeta.Target) error {
+		if len(clusterIDs) == 0 {
+			return vegeta.ErrNoTargets
 		}
-		awsTags := map[string]string{
-			"User": "pocm-perf",
+		t.Method = method
+		t.URL = strings.Replace(url, "{clusterId}", clusterIDs[pick()], 1)
+		return nil
+	}
+}
+
+// poolPicker returns a function returning the index of the next element of a
+// pool of the given size, picked randomly or in round-robin. It is safe to
+// use from concurrent targeters.
+func poolPicker(selection string, size int) func() int {
+	var next uint64
+	return func() int {
+		if size == 0 {
+			return 0
+		}
+		if selection == randomSelection {
+			return rand.Intn(size)
 		}
-		body, err := v1.NewCluster().
-			Name(fmt.Sprintf("pocm-%s-%d", id, idx)).
-			Properties(fakeClusterProps).
-			MultiAZ(true).
-			Region(v1.NewCloudRegion().ID(ccsRegion)).
-			CCS(v1.NewCCS().Enabled(true)).
-			AWS(
-				v1.NewAWS().
-					AccessKeyID(ccsAccessKey).
-					SecretAccessKey(ccsSecretKey).
-					AccountID(ccsAccountID).
-					Tags(awsTags),
-			).
-			Build()
+		return int((atomic.AddUint64(&next, 1) - 1) % uint64(size))
+	}
+}
+
+// TestClusterLifecycle runs the whole lifecycle of a fake cluster on each
+// iteration: create, poll until it reaches the configured state, patch, list
+// and delete. The latency of each step is recorded as `<test-name>/<step>`.
+func TestClusterLifecycle(ctx context.Context, options *types.TestOptions) error {
+	targetState := viper.GetString(fmt.Sprintf("tests.%s.target-state", options.TestName))
+	if targetState == "" {
+		targetState = defaultTargetState
+	}
+	pollInterval := time.Duration(viper.GetInt(fmt.Sprintf("tests.%s.poll-interval", options.TestName))) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	pollTimeout := time.Duration(viper.GetInt(fmt.Sprintf("tests.%s.poll-timeout", options.TestName))) * time.Second
+	if pollTimeout <= 0 {
+		pollTimeout = defaultPollTimeout
+	}
+	options.Logger.Info(ctx, "Waiting up to %s for clusters to reach state '%s'", pollTimeout, targetState)
+
+	// This will take the first 4 characters of the UUID
+	// Cluster Names must match the following regex:
+	// ^[a-z]([-a-z0-9]*[a-z0-9])?$
+	id := options.ID[:4]
+	creds := getCCSCredentials(ctx, options.Logger)
+	conn := options.Connection
+
+	runIterations(ctx, options, func(ctx context.Context, seq uint64, rec *stepRecorder) {
+		body, err := fakeClusterBody(fmt.Sprintf("pocm-%s-%s", id, uuid.NewV4().String()[:4]), creds)
 		if err != nil {
-			return err
+			options.Logger.Error(ctx, "building cluster body: %v", err)
+			return
 		}
+		response, err := rec.Send(ctx, "create", http.MethodPost, options.Path, nil, body, http.StatusCreated)
+		if err != nil {
+			return
+		}
+		cluster, err := helpers.Parse(response.Bytes())
+		if err != nil {
+			options.Logger.Error(ctx, "parsing created cluster: %v", err)
+			return
+		}
+		clusterID, _ := cluster["id"].(string)
+		clusterPath := helpers.ClustersEndpoint + clusterID
+
+		// Whatever happens the cluster has to be deleted at the end of the iteration
+		defer func() {
+			began := time.Now()
+			err := helpers.DeleteCluster(ctx, clusterID, true, conn)
+			code := http.StatusNoContent
+			if err != nil {
+				code = 0
+			}
+			rec.Record("delete", http.MethodDelete, clusterPath, began, code, 0, 0, err)
+		}()
 
+		if !waitForClusterState(ctx, rec, clusterPath, targetState, pollInterval, pollTimeout) {
+			return
+		}
+
+		patch, err := v1.NewCluster().ExpirationTimestamp(time.Now().Add(time.Hour)).Build()
+		if err != nil {
+			options.Logger.Error(ctx, "building cluster patch: %v", err)
+			return
+		}
 		var raw bytes.Buffer
-		err = v1.MarshalCluster(body, &raw)
+		err = v1.MarshalCluster(patch, &raw)
 		if err != nil {
-			return err
+			options.Logger.Error(ctx, "marshaling cluster patch: %v", err)
+			return
+		}
+		_, err = rec.Send(ctx, "patch", http.MethodPatch, clusterPath, nil, raw.Bytes(), http.StatusOK)
+		if err != nil {
+			return
 		}
 
-		t.Method = method
-		t.URL = url
-		t.Body = raw.Bytes()
+		rec.Send(ctx, "list", http.MethodGet, options.Path, url.Values{"search": {fmt.Sprintf("id = '%s'", clusterID)}}, nil, http.StatusOK)
+	})
 
-		idx += 1
-		return nil
+	helpers.Cleanup(ctx, options.Connection)
+	return nil
+}
+
+// waitForClusterState polls the cluster, recording every request as the `poll`
+// step, and records the time it took to reach the state as the `wait` step.
+func waitForClusterState(ctx context.Context, rec *stepRecorder, clusterPath, state string, interval, timeout time.Duration) bool {
+	began := time.Now()
+	for time.Since(began) < timeout {
+		response, err := rec.Send(ctx, "poll", http.MethodGet, clusterPath, nil, nil, http.StatusOK)
+		if err == nil {
+			cluster, err := helpers.Parse(response.Bytes())
+			if err == nil && cluster["state"] == state {
+				rec.Record("wait", http.MethodGet, clusterPath, began, http.StatusOK, 0, 0, nil)
+				return true
+			}
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return false
+		}
 	}
-	return targeter
+	rec.Record("wait", http.MethodGet, clusterPath, began, 0, 0, 0,
+		fmt.Errorf("cluster did not reach state '%s' in %s", state, timeout))
+	return false
 }
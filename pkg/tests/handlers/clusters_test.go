@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func Test_generateClusterPoolTargeter(t *testing.T) {
+	ids := []string{"id-1", "id-2", "id-3"}
+	path := "/api/clusters_mgmt/v1/clusters/{clusterId}"
+
+	t.Run("round-robin", func(t *testing.T) {
+		targeter := generateClusterPoolTargeter(http.MethodGet, path, "round-robin", ids)
+		for i := 0; i < 6; i++ {
+			var target vegeta.Target
+			if err := targeter(&target); err != nil {
+				t.Fatalf("targeter() error = %v", err)
+			}
+			want := "/api/clusters_mgmt/v1/clusters/" + ids[i%len(ids)]
+			if target.URL != want || target.Method != http.MethodGet {
+				t.Errorf("targeter() = %s %s, want GET %s", target.Method, target.URL, want)
+			}
+		}
+	})
+
+	t.Run("random", func(t *testing.T) {
+		targeter := generateClusterPoolTargeter(http.MethodGet, path, randomSelection, ids)
+		for i := 0; i < 10; i++ {
+			var target vegeta.Target
+			if err := targeter(&target); err != nil {
+				t.Fatalf("targeter() error = %v", err)
+			}
+			id := strings.TrimPrefix(target.URL, "/api/clusters_mgmt/v1/clusters/")
+			if id != "id-1" && id != "id-2" && id != "id-3" {
+				t.Errorf("targeter() = %s, not in the pool", target.URL)
+			}
+		}
+	})
+
+	t.Run("empty_pool", func(t *testing.T) {
+		targeter := generateClusterPoolTargeter(http.MethodGet, path, randomSelection, nil)
+		var target vegeta.Target
+		if err := targeter(&target); err != vegeta.ErrNoTargets {
+			t.Errorf("targeter() error = %v, want %v", err, vegeta.ErrNoTargets)
+		}
+	})
+}
+
+func Test_fakeClusterBody(t *testing.T) {
+	body, err := fakeClusterBody("pocm-abcd-0", ccsCredentials{region: "us-west-1", accessKey: "key", secretKey: "secret", accountID: "123"})
+	if err != nil {
+		t.Fatalf("fakeClusterBody() error = %v", err)
+	}
+	cluster, err := helpers.Parse(body)
+	if err != nil {
+		t.Fatalf("fakeClusterBody() = %s, not valid JSON: %v", body, err)
+	}
+	if cluster["name"] != "pocm-abcd-0" {
+		t.Errorf("fakeClusterBody() name = %v, want pocm-abcd-0", cluster["name"])
+	}
+	if cluster["properties"].(map[string]interface{})["fake_cluster"] != "true" {
+		t.Errorf("fakeClusterBody() = %s, must be a fake cluster", body)
+	}
+	if cluster["aws"].(map[string]interface{})["account_id"] != "123" {
+		t.Errorf("fakeClusterBody() = %s, wrong AWS account", body)
+	}
+}
+
+func Test_poolPicker(t *testing.T) {
+	pick := poolPicker("", 3)
+	for i, want := range []int{0, 1, 2, 0, 1} {
+		if got := pick(); got != want {
+			t.Errorf("pick %d = %d, want %d", i, got, want)
+		}
+	}
+	pick = poolPicker(randomSelection, 3)
+	for i := 0; i < 20; i++ {
+		if got := pick(); got < 0 || got >= 3 {
+			t.Fatalf("random pick = %d, out of range", got)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/ocm"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// iterationFunc runs a single iteration of a scenario made of several requests,
+// recording each one of them with the given recorder.
+type iterationFunc func(ctx context.Context, seq uint64, rec *stepRecorder)
+
+// runIterations starts iterations at the pace of the test until the test
+// duration is reached, as vegeta does with single requests, and encodes every
+// recorded step. A zero rate runs one iteration after the other, otherwise at
+// most MaxWorkers iterations run at the same time.
+func runIterations(ctx context.Context, options *types.TestOptions, iteration iterationFunc) {
+	steps := make(chan *vegeta.Result)
+	rec := &stepRecorder{testName: options.TestName, client: options.Client, results: steps}
+	if rec.client == nil {
+		rec.client = &http.Client{Transport: options.Connection}
+	}
+	var workers chan struct{}
+	if options.MaxWorkers > 0 {
+		workers = make(chan struct{}, options.MaxWorkers)
+	}
+
+	var wg sync.WaitGroup
+	go func() {
+		defer close(steps)
+		defer wg.Wait()
+
+		pacer := options.Pacer()
+		began, count := time.Now(), uint64(0)
+		for {
+			elapsed := time.Since(began)
+			if options.Duration > 0 && elapsed > options.Duration {
+				return
+			}
+			wait, stop := pacer.Pace(elapsed, count)
+			if stop {
+				return
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+			seq := count
+			count++
+			if options.Rate.Freq == 0 {
+				iteration(ctx, seq, rec)
+				continue
+			}
+			if workers != nil {
+				select {
+				case workers <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if workers != nil {
+					defer func() { <-workers }()
+				}
+				iteration(ctx, seq, rec)
+			}()
+		}
+	}()
+
+	for res := range steps {
+		options.Encoder.Encode(res)
+	}
+}
+
+// stepRecorder turns the requests of a scenario into vegeta results named
+// `<test-name>/<step>`, so the latency of each step can be reported apart.
+type stepRecorder struct {
+	testName string
+	client   *http.Client
+	results  chan<- *vegeta.Result
+	seq      uint64
+	mu       sync.Mutex
+}
+
+// stepResponse is the response to a request sent by a stepRecorder.
+type stepResponse struct {
+	status int
+	body   []byte
+}
+
+// Status returns the status code of the response.
+func (r *stepResponse) Status() int {
+	return r.status
+}
+
+// Bytes returns the body of the response.
+func (r *stepResponse) Bytes() []byte {
+	return r.body
+}
+
+// Record stores the outcome of a step that started at `began`.
+func (r *stepRecorder) Record(step, method, url string, began time.Time, code int, bytesOut, bytesIn int, err error) {
+	r.results <- r.result(step, method, url, began, code, bytesOut, bytesIn, err)
+}
+
+func (r *stepRecorder) result(step, method, url string, began time.Time, code int, bytesOut, bytesIn int, err error) *vegeta.Result {
+	r.mu.Lock()
+	seq := r.seq
+	r.seq++
+	r.mu.Unlock()
+
+	res := &vegeta.Result{
+		Attack:    fmt.Sprintf("%s/%s", r.testName, step),
+		Seq:       seq,
+		Code:      uint16(code),
+		Timestamp: began,
+		Latency:   time.Since(began),
+		BytesOut:  uint64(bytesOut),
+		BytesIn:   uint64(bytesIn),
+		Method:    method,
+		URL:       url,
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// Send sends the request with the client of the test and records it as the
+// given step, with the trace and operation IDs of the request, as the results
+// of the attacks have them. Responses with an unexpected status are returned
+// with an error.
+func (r *stepRecorder) Send(ctx context.Context, step, method, path string, query url.Values, body []byte, expected ...int) (*stepResponse, error) {
+	target := path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	traceID, traceparent := ocm.NewTraceparent()
+	request.Header.Set(ocm.TraceparentHeader, traceparent)
+
+	began := time.Now()
+	response, err := r.client.Do(request)
+	if err != nil {
+		res := r.result(step, method, target, began, 0, len(body), 0, err)
+		res.Headers = http.Header{results.TraceIDHeader: []string{traceID}}
+		r.results <- res
+		return nil, err
+	}
+	defer response.Body.Close()
+	received, err := io.ReadAll(response.Body)
+	if err == nil {
+		err = fmt.Errorf("%s %s: unexpected status %d", method, target, response.StatusCode)
+		for _, status := range expected {
+			if response.StatusCode == status {
+				err = nil
+				break
+			}
+		}
+	}
+	res := r.result(step, method, target, began, response.StatusCode, len(body), len(received), err)
+	res.Headers = http.Header{}
+	for _, header := range []string{results.TraceIDHeader, results.OperationIDHeader} {
+		if value := response.Header.Get(header); value != "" {
+			res.Headers.Set(header, value)
+		}
+	}
+	if res.Headers.Get(results.TraceIDHeader) == "" {
+		res.Headers.Set(results.TraceIDHeader, traceID)
+	}
+	r.results <- res
+	return &stepResponse{status: response.StatusCode, body: received}, err
+}
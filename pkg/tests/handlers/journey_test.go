@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func Test_runIterations(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := vegeta.NewJSONEncoder(&buf)
+	options := &types.TestOptions{
+		TestName: "journey",
+		Rate:     vegeta.Rate{Freq: 20, Per: time.Second},
+		Duration: 500 * time.Millisecond,
+		Encoder:  &encoder,
+	}
+	runIterations(context.TODO(), options, func(ctx context.Context, seq uint64, rec *stepRecorder) {
+		rec.Record("first", http.MethodPost, "/first", time.Now(), http.StatusCreated, 10, 20, nil)
+		rec.Record("second", http.MethodGet, "/second", time.Now(), 0, 0, 0, fmt.Errorf("failed"))
+	})
+
+	steps := map[string]int{}
+	dec := vegeta.NewJSONDecoder(&buf)
+	for {
+		var res vegeta.Result
+		err := dec.Decode(&res)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("decoding results: %v", err)
+		}
+		steps[res.Attack]++
+		if res.Attack == "journey/second" && res.Error != "failed" {
+			t.Errorf("result %+v should have the step error", res)
+		}
+	}
+	if steps["journey/first"] == 0 || steps["journey/first"] != steps["journey/second"] {
+		t.Errorf("runIterations() steps = %v, want the same number of both steps", steps)
+	}
+	// 20/s during 500ms, give some room for slow machines
+	if steps["journey/first"] < 5 || steps["journey/first"] > 12 {
+		t.Errorf("runIterations() ran %d iterations, want about 10", steps["journey/first"])
+	}
+}
+
+// stopPacer stops the attack after its hits.
+type stopPacer struct {
+	hits uint64
+}
+
+func (p stopPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	return 0, hits >= p.hits
+}
+
+func (p stopPacer) Rate(elapsed time.Duration) float64 {
+	return 0
+}
+
+func Test_runIterations_pacer(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := vegeta.NewJSONEncoder(&buf)
+	// The throttle policy of the test paces the iterations, not its rate.
+	options := &types.TestOptions{
+		TestName: "journey",
+		Rate:     vegeta.Rate{Freq: 20, Per: time.Second},
+		Duration: 500 * time.Millisecond,
+		Encoder:  &encoder,
+		Throttle: func(rate vegeta.Rate, duration time.Duration) vegeta.Pacer {
+			return stopPacer{hits: 3}
+		},
+	}
+	iterations := 0
+	runIterations(context.TODO(), options, func(ctx context.Context, seq uint64, rec *stepRecorder) {
+		rec.Record("first", http.MethodGet, "/first", time.Now(), http.StatusOK, 0, 0, nil)
+	})
+	dec := vegeta.NewJSONDecoder(&buf)
+	for {
+		var res vegeta.Result
+		if err := dec.Decode(&res); err != nil {
+			break
+		}
+		iterations++
+	}
+	if iterations != 3 {
+		t.Errorf("runIterations() ran %d iterations, want the 3 allowed by the pacer", iterations)
+	}
+}
+
+func Test_runIterations_maxWorkers(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := vegeta.NewJSONEncoder(&buf)
+	options := &types.TestOptions{
+		TestName:   "journey",
+		Rate:       vegeta.Rate{Freq: 100, Per: time.Second},
+		Duration:   300 * time.Millisecond,
+		Encoder:    &encoder,
+		MaxWorkers: 2,
+	}
+	var inFlight, max int32
+	runIterations(context.TODO(), options, func(ctx context.Context, seq uint64, rec *stepRecorder) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			seen := atomic.LoadInt32(&max)
+			if current <= seen || atomic.CompareAndSwapInt32(&max, seen, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	})
+	if max > 2 {
+		t.Errorf("runIterations() ran %d concurrent iterations, want at most 2", max)
+	}
+}
+
+func Test_stepRecorder_Send(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("search") != "id = 'a'" {
+			t.Errorf("request query = %q, want the search", r.URL.RawQuery)
+		}
+		w.Header().Set(results.OperationIDHeader, "op-1")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	steps := make(chan *vegeta.Result, 1)
+	rec := &stepRecorder{testName: "journey", client: server.Client(), results: steps}
+	response, err := rec.Send(context.TODO(), "get", http.MethodGet, server.URL+"/items", url.Values{"search": {"id = 'a'"}}, nil, http.StatusOK)
+	if err == nil {
+		t.Errorf("Send() error = nil, want an unexpected status error")
+	}
+	if response == nil || response.Status() != http.StatusNotFound || string(response.Bytes()) != "{}" {
+		t.Errorf("Send() response = %+v, want the 404 response", response)
+	}
+	res := <-steps
+	if res.Attack != "journey/get" || res.Code != http.StatusNotFound || res.BytesIn != 2 {
+		t.Errorf("Send() result = %+v, want the get step with the 404", res)
+	}
+	if res.Headers.Get(results.OperationIDHeader) != "op-1" {
+		t.Errorf("Send() result headers = %v, want the operation ID", res.Headers)
+	}
+	if res.Headers.Get(results.TraceIDHeader) == "" {
+		t.Errorf("Send() result headers = %v, want a trace ID", res.Headers)
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// listQueryParameters are the parameters of the list endpoints that can be
+// configured under `tests.<name>.query`, in the order they are added to the URL.
+var listQueryParameters = []string{"search", "page", "size", "order", "fields"}
+
+// weightedValue is a value that is picked with a probability proportional to
+// its weight. An empty value means the parameter is not sent.
+type weightedValue struct {
+	value  string
+	weight int
+}
+
+// weightedValues picks one of its values randomly, following their weights.
+type weightedValues []weightedValue
+
+func (w weightedValues) pick() string {
+	total := w.totalWeight()
+	if total == 0 {
+		return ""
+	}
+	n := rand.Intn(total)
+	for _, v := range w {
+		if n < v.weight {
+			return v.value
+		}
+		n -= v.weight
+	}
+	return ""
+}
+
+func (w weightedValues) totalWeight() int {
+	total := 0
+	for _, v := range w {
+		total += v.weight
+	}
+	return total
+}
+
+// parseWeightedValues reads a list of values, each of them either a plain
+// value with weight 1 or a map with `value` and `weight`, e.g.:
+//
+//   - "name like 'pocm-%'"
+//   - value: "state = 'ready'"
+//     weight: 3
+func parseWeightedValues(raw interface{}) (weightedValues, error) {
+	items, err := cast.ToSliceE(raw)
+	if err != nil {
+		return nil, err
+	}
+	values := make(weightedValues, 0, len(items))
+	for _, item := range items {
+		m, err := cast.ToStringMapE(item)
+		if err != nil {
+			value, err := cast.ToStringE(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, weightedValue{value: value, weight: 1})
+			continue
+		}
+		value, err := cast.ToStringE(m["value"])
+		if err != nil {
+			return nil, err
+		}
+		weight := 1
+		if w, ok := m["weight"]; ok {
+			weight, err = cast.ToIntE(w)
+			if err != nil {
+				return nil, err
+			}
+			if weight < 0 {
+				return nil, fmt.Errorf("weight of '%s' must not be negative", value)
+			}
+		}
+		values = append(values, weightedValue{value: value, weight: weight})
+	}
+	return values, nil
+}
+
+// listQuery holds the distribution of every configured list parameter.
+type listQuery map[string]weightedValues
+
+// listQueryConfig reads the distributions under `tests.<name>.query`.
+func listQueryConfig(testName string) (listQuery, error) {
+	query := listQuery{}
+	for _, parameter := range listQueryParameters {
+		key := fmt.Sprintf("tests.%s.query.%s", testName, parameter)
+		if !viper.IsSet(key) {
+			continue
+		}
+		values, err := parseWeightedValues(viper.Get(key))
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", key, err)
+		}
+		query[parameter] = values
+	}
+	return query, nil
+}
+
+// url returns the path with a query built picking a value of each parameter.
+func (q listQuery) url(path string) string {
+	values := make([]string, 0, len(q))
+	for _, parameter := range listQueryParameters {
+		distribution, ok := q[parameter]
+		if !ok {
+			continue
+		}
+		if value := distribution.pick(); value != "" {
+			values = append(values, fmt.Sprintf("%s=%s", parameter, url.QueryEscape(value)))
+		}
+	}
+	if len(values) == 0 {
+		return path
+	}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + strings.Join(values, "&")
+}
+
+// TestListQuery performs a load test on a list endpoint picking the `search`,
+// `page`, `size`, `order` and `fields` parameters of each request from the
+// distributions configured under `tests.<name>.query`. The URL of every result
+// holds the query, its shape is indexed as `query_shape`.
+func TestListQuery(ctx context.Context, options *types.TestOptions) error {
+	query, err := listQueryConfig(options.TestName)
+	if err != nil {
+		return err
+	}
+
+	targeter := func(t *vegeta.Target) error {
+		t.Method = options.Method
+		t.URL = query.url(options.Path)
+		return nil
+	}
+
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, options.TestName) {
+		options.Encoder.Encode(res)
+	}
+
+	return nil
+}
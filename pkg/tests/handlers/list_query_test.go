@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func Test_parseWeightedValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     interface{}
+		want    weightedValues
+		wantErr bool
+	}{
+		{
+			name: "plain values",
+			raw:  []interface{}{"name asc", 100},
+			want: weightedValues{{"name asc", 1}, {"100", 1}},
+		},
+		{
+			name: "weighted values",
+			raw: []interface{}{
+				map[string]interface{}{"value": "state = 'ready'", "weight": 3},
+				map[interface{}]interface{}{"value": "", "weight": 1},
+			},
+			want: weightedValues{{"state = 'ready'", 3}, {"", 1}},
+		},
+		{
+			name:    "negative weight",
+			raw:     []interface{}{map[string]interface{}{"value": "x", "weight": -1}},
+			wantErr: true,
+		},
+		{
+			name:    "not a list",
+			raw:     "name asc",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWeightedValues(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWeightedValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWeightedValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_weightedValues_pick(t *testing.T) {
+	values := weightedValues{{"a", 0}, {"b", 1}}
+	for i := 0; i < 20; i++ {
+		if got := values.pick(); got != "b" {
+			t.Fatalf("pick() = %q, values with weight 0 must never be picked", got)
+		}
+	}
+	if got := (weightedValues{}).pick(); got != "" {
+		t.Errorf("pick() of no values = %q, want empty", got)
+	}
+}
+
+func Test_listQuery_url(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("tests.list-clusters.query", map[string]interface{}{
+		"search": []interface{}{"name like 'pocm-%'"},
+		"size":   []interface{}{100},
+		"page":   []interface{}{""},
+	})
+	query, err := listQueryConfig("list-clusters")
+	if err != nil {
+		t.Fatalf("listQueryConfig() error = %v", err)
+	}
+
+	got, err := url.Parse(query.url("/api/clusters_mgmt/v1/clusters"))
+	if err != nil {
+		t.Fatalf("parsing url: %v", err)
+	}
+	want := url.Values{"search": {"name like 'pocm-%'"}, "size": {"100"}}
+	if got.Path != "/api/clusters_mgmt/v1/clusters" || !reflect.DeepEqual(got.Query(), want) {
+		t.Errorf("url() = %s, want query %v", got, want)
+	}
+
+	if got := (listQuery{}).url("/api/clusters_mgmt/v1/clusters"); got != "/api/clusters_mgmt/v1/clusters" {
+		t.Errorf("url() without parameters = %s", got)
+	}
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	v1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	defaultMachinePoolInstanceType = "m5.xlarge"
+	defaultAddOnID                 = "ocm-addon-test-operator"
+)
+
+// machinePool identifies a machine pool of a cluster of the pool.
+type machinePool struct {
+	clusterID string
+	id        string
+}
+
+// TestCreateMachinePools performs a load test on
+// "POST /api/clusters_mgmt/v1/clusters/{clusterId}/machine_pools", spreading
+// the machine pools across a pool of fake clusters.
+func TestCreateMachinePools(ctx context.Context, options *types.TestOptions) error {
+	return testClusterPool(ctx, options, func(clusterIDs []string) vegeta.Targeter {
+		pick := poolPicker(clusterPoolSelection(options), len(clusterIDs))
+		instanceType := machinePoolInstanceType(options)
+		return func(t *vegeta.Target) error {
+			body, err := machinePoolBody(newMachinePoolID(), instanceType, 2)
+			if err != nil {
+				return err
+			}
+			t.Method = options.Method
+			t.URL = strings.Replace(options.Path, "{clusterId}", clusterIDs[pick()], 1)
+			t.Body = body
+			return nil
+		}
+	})
+}
+
+// TestListMachinePools performs a load test on
+// "GET /api/clusters_mgmt/v1/clusters/{clusterId}/machine_pools".
+func TestListMachinePools(ctx context.Context, options *types.TestOptions) error {
+	return testClusterPool(ctx, options, func(clusterIDs []string) vegeta.Targeter {
+		return generateClusterPoolTargeter(options.Method, options.Path, clusterPoolSelection(options), clusterIDs)
+	})
+}
+
+// TestPatchMachinePools performs a load test on
+// "PATCH /api/clusters_mgmt/v1/clusters/{clusterId}/machine_pools/{machinePoolId}"
+// changing the replicas of a machine pool created on each cluster of the pool.
+// The machine pools are created along with the cluster pool and reused by the
+// following ramp steps.
+func TestPatchMachinePools(ctx context.Context, options *types.TestOptions) error {
+	return testClusterPool(ctx, options, func(clusterIDs []string) vegeta.Targeter {
+		pools := reusedMachinePools(ctx, options, clusterIDs)
+		pick := poolPicker(clusterPoolSelection(options), len(pools))
+		return func(t *vegeta.Target) error {
+			if len(pools) == 0 {
+				return vegeta.ErrNoTargets
+			}
+			pool := pools[pick()]
+			body, err := machinePoolBody("", "", 1+rand.Intn(3))
+			if err != nil {
+				return err
+			}
+			t.Method = options.Method
+			t.URL = machinePoolURL(options.Path, pool)
+			t.Body = body
+			return nil
+		}
+	})
+}
+
+// TestDeleteMachinePools performs a load test on
+// "DELETE /api/clusters_mgmt/v1/clusters/{clusterId}/machine_pools/{machinePoolId}".
+// The machine pools deleted are created on the clusters of the pool before
+// the attack of each ramp step, one for every request of the step unless
+// `machine-pools` is set. The attack stops once they are all deleted.
+func TestDeleteMachinePools(ctx context.Context, options *types.TestOptions) error {
+	return testClusterPool(ctx, options, func(clusterIDs []string) vegeta.Targeter {
+		qty := viper.GetInt(fmt.Sprintf("tests.%s.machine-pools", options.TestName))
+		if qty <= 0 {
+			qty = expectedRequests(options)
+		}
+		pools := seedMachinePools(ctx, options, clusterIDs, qty)
+		var next uint64
+		return func(t *vegeta.Target) error {
+			i := atomic.AddUint64(&next, 1) - 1
+			if i >= uint64(len(pools)) {
+				return vegeta.ErrNoTargets
+			}
+			t.Method = options.Method
+			t.URL = machinePoolURL(options.Path, pools[i])
+			return nil
+		}
+	})
+}
+
+// TestCreateAddOns performs a load test on
+// "POST /api/clusters_mgmt/v1/clusters/{clusterId}/addons". An add-on can be
+// installed only once per cluster, so `pool-size` should be at least the
+// number of requests of the test; once every cluster has the add-on the
+// requests are expected to fail.
+func TestCreateAddOns(ctx context.Context, options *types.TestOptions) error {
+	addOnID := viper.GetString(fmt.Sprintf("tests.%s.addon-id", options.TestName))
+	if addOnID == "" {
+		addOnID = defaultAddOnID
+	}
+	return testClusterPool(ctx, options, func(clusterIDs []string) vegeta.Targeter {
+		pick := poolPicker(clusterPoolSelection(options), len(clusterIDs))
+		return func(t *vegeta.Target) error {
+			body, err := v1.NewAddOnInstallation().
+				Addon(v1.NewAddOn().ID(addOnID)).
+				Build()
+			if err != nil {
+				return err
+			}
+			var raw bytes.Buffer
+			err = v1.MarshalAddOnInstallation(body, &raw)
+			if err != nil {
+				return err
+			}
+			t.Method = options.Method
+			t.URL = strings.Replace(options.Path, "{clusterId}", clusterIDs[pick()], 1)
+			t.Body = raw.Bytes()
+			return nil
+		}
+	})
+}
+
+// clusterPoolKey identifies the cluster pool of a connection in a test.
+type clusterPoolKey struct {
+	testName   string
+	connection *sdk.Connection
+}
+
+// clusterPools keeps the cluster pool of each connection across ramp steps.
+var (
+	clusterPools   = map[clusterPoolKey][]string{}
+	clusterPoolsMu sync.Mutex
+)
+
+// testClusterPool attacks with the targeter built for the pool of fake
+// clusters of the connection. The pool is seeded at the first ramp step and
+// reused by the following ones. At the last step, the clusters and everything
+// created on them are cleaned up; the resources of the other connections,
+// which may still be attacking, are left alone.
+func testClusterPool(ctx context.Context, options *types.TestOptions, buildTargeter func(clusterIDs []string) vegeta.Targeter) error {
+	key := clusterPoolKey{testName: options.TestName, connection: options.Connection}
+	clusterPoolsMu.Lock()
+	clusterIDs, ok := clusterPools[key]
+	clusterPoolsMu.Unlock()
+	if ok {
+		options.Logger.Info(ctx, "Reusing %d clusters for %s test", len(clusterIDs), options.TestName)
+	} else {
+		poolSize := viper.GetInt(fmt.Sprintf("tests.%s.pool-size", options.TestName))
+		if poolSize <= 0 {
+			poolSize = defaultClusterPoolSize
+		}
+		var err error
+		clusterIDs, err = seedClusterPool(ctx, poolSize, options)
+		if err != nil {
+			return err
+		}
+		clusterPoolsMu.Lock()
+		clusterPools[key] = clusterIDs
+		clusterPoolsMu.Unlock()
+	}
+
+	targeter := buildTargeter(clusterIDs)
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, options.TestName) {
+		options.Encoder.Encode(res)
+	}
+
+	if options.LastStep() {
+		clusterPoolsMu.Lock()
+		delete(clusterPools, key)
+		clusterPoolsMu.Unlock()
+		helpers.CleanupClusters(ctx, options.Connection, clusterIDs)
+	}
+	return nil
+}
+
+func clusterPoolSelection(options *types.TestOptions) string {
+	return viper.GetString(fmt.Sprintf("tests.%s.selection", options.TestName))
+}
+
+func machinePoolInstanceType(options *types.TestOptions) string {
+	instanceType := viper.GetString(fmt.Sprintf("tests.%s.instance-type", options.TestName))
+	if instanceType == "" {
+		return defaultMachinePoolInstanceType
+	}
+	return instanceType
+}
+
+// newMachinePoolID returns a unique machine pool ID. They must match
+// ^[a-z]([-a-z0-9]*[a-z0-9])?$
+func newMachinePoolID() string {
+	return fmt.Sprintf("mp-%s", uuid.NewV4().String()[:8])
+}
+
+// machinePoolBody builds a machine pool request body, empty values are omitted
+// so it can be used for patching.
+func machinePoolBody(id, instanceType string, replicas int) ([]byte, error) {
+	builder := v1.NewMachinePool().Replicas(replicas)
+	if id != "" {
+		builder = builder.ID(id)
+	}
+	if instanceType != "" {
+		builder = builder.InstanceType(instanceType)
+	}
+	body, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	var raw bytes.Buffer
+	err = v1.MarshalMachinePool(body, &raw)
+	if err != nil {
+		return nil, err
+	}
+	return raw.Bytes(), nil
+}
+
+// seedMachinePools creates qty machine pools spread across the clusters,
+// `seed-workers` at a time at up to `seed-rate` per second. The ones that
+// could not be created are left out.
+func seedMachinePools(ctx context.Context, options *types.TestOptions, clusterIDs []string, qty int) []machinePool {
+	workers := viper.GetInt(fmt.Sprintf("tests.%s.seed-workers", options.TestName))
+	if workers <= 0 {
+		workers = defaultClusterPoolSeedWorkers
+	}
+	seedRate := viper.GetInt(fmt.Sprintf("tests.%s.seed-rate", options.TestName))
+	if seedRate <= 0 {
+		seedRate = defaultClusterPoolSeedRate
+	}
+	instanceType := machinePoolInstanceType(options)
+	// Every seed writes its own element, read once seedPool returns.
+	seeded := make([]machinePool, qty)
+	ids, failures := seedPool(ctx, "machine pools", qty, workers,
+		vegeta.Rate{Freq: seedRate, Per: time.Second}, options.Logger,
+		func(ctx context.Context, i int) (string, error) {
+			pool, err := createMachinePool(ctx, options.Connection, clusterIDs[i%len(clusterIDs)], instanceType)
+			seeded[i] = pool
+			return pool.id, err
+		})
+	if failures > 0 {
+		options.Logger.Warn(ctx, "%d of %d machine pools failed to be created, using %d machine pools", failures, qty, len(ids))
+	}
+	pools := make([]machinePool, 0, len(ids))
+	for _, pool := range seeded {
+		if pool.id != "" {
+			pools = append(pools, pool)
+		}
+	}
+	return pools
+}
+
+// machinePools keeps the machine pools patched by each connection across ramp
+// steps, like the cluster pool they are created on.
+var (
+	machinePools   = map[clusterPoolKey][]machinePool{}
+	machinePoolsMu sync.Mutex
+)
+
+// reusedMachinePools returns a machine pool for every cluster of the pool of
+// the connection, created at the first ramp step. They are forgotten at the
+// last one, as testClusterPool then deletes the clusters along with everything
+// created on them.
+func reusedMachinePools(ctx context.Context, options *types.TestOptions, clusterIDs []string) []machinePool {
+	key := clusterPoolKey{testName: options.TestName, connection: options.Connection}
+	machinePoolsMu.Lock()
+	pools, ok := machinePools[key]
+	if options.LastStep() {
+		delete(machinePools, key)
+	}
+	machinePoolsMu.Unlock()
+	if ok {
+		options.Logger.Info(ctx, "Reusing %d machine pools for %s test", len(pools), options.TestName)
+		return pools
+	}
+	pools = seedMachinePools(ctx, options, clusterIDs, len(clusterIDs))
+	if !options.LastStep() {
+		machinePoolsMu.Lock()
+		machinePools[key] = pools
+		machinePoolsMu.Unlock()
+	}
+	return pools
+}
+
+// expectedRequests returns the number of requests sent by the attack of the
+// test at its rate.
+func expectedRequests(options *types.TestOptions) int {
+	if options.Rate.Per <= 0 {
+		return options.Rate.Freq
+	}
+	return int(math.Ceil(float64(options.Rate.Freq) * float64(options.Duration) / float64(options.Rate.Per)))
+}
+
+// createMachinePool creates a machine pool, which is tracked for cleanup by the
+// CleanTestTransport.
+func createMachinePool(ctx context.Context, connection *sdk.Connection, clusterID, instanceType string) (machinePool, error) {
+	id := newMachinePoolID()
+	body, err := machinePoolBody(id, instanceType, 2)
+	if err != nil {
+		return machinePool{}, err
+	}
+	response, err := connection.Post().
+		Path(fmt.Sprintf("%s%s/%s", helpers.ClustersEndpoint, clusterID, helpers.MachinePoolsResource)).
+		Bytes(body).
+		SendContext(ctx)
+	if err != nil {
+		return machinePool{}, err
+	}
+	if response.Status() != 201 {
+		return machinePool{}, fmt.Errorf("creating machine pool: expected response code 201, instead found: %d", response.Status())
+	}
+	return machinePool{clusterID: clusterID, id: id}, nil
+}
+
+func machinePoolURL(path string, pool machinePool) string {
+	url := strings.Replace(path, "{clusterId}", pool.clusterID, 1)
+	return strings.Replace(url, "{machinePoolId}", pool.id, 1)
+}
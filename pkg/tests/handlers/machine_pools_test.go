@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func Test_newMachinePoolID(t *testing.T) {
+	valid := regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+	id := newMachinePoolID()
+	if !valid.MatchString(id) {
+		t.Errorf("newMachinePoolID() = %q, is not a valid machine pool ID", id)
+	}
+	if id == newMachinePoolID() {
+		t.Errorf("newMachinePoolID() returned the same ID twice")
+	}
+}
+
+func Test_machinePoolBody(t *testing.T) {
+	raw, err := machinePoolBody("mp-1", "m5.xlarge", 2)
+	if err != nil {
+		t.Fatalf("machinePoolBody() error = %v", err)
+	}
+	body, err := helpers.Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing body: %v", err)
+	}
+	if body["id"] != "mp-1" || body["instance_type"] != "m5.xlarge" || body["replicas"] != float64(2) {
+		t.Errorf("machinePoolBody() = %s", raw)
+	}
+
+	raw, err = machinePoolBody("", "", 3)
+	if err != nil {
+		t.Fatalf("machinePoolBody() error = %v", err)
+	}
+	body, err = helpers.Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing body: %v", err)
+	}
+	if _, ok := body["id"]; ok {
+		t.Errorf("patch body should not contain an id: %s", raw)
+	}
+	if _, ok := body["instance_type"]; ok {
+		t.Errorf("patch body should not contain an instance type: %s", raw)
+	}
+	if body["replicas"] != float64(3) {
+		t.Errorf("patch body replicas = %v, want 3", body["replicas"])
+	}
+}
+
+func Test_machinePoolURL(t *testing.T) {
+	got := machinePoolURL("/api/clusters_mgmt/v1/clusters/{clusterId}/machine_pools/{machinePoolId}", machinePool{clusterID: "abc", id: "mp-1"})
+	want := "/api/clusters_mgmt/v1/clusters/abc/machine_pools/mp-1"
+	if got != want {
+		t.Errorf("machinePoolURL() = %q, want %q", got, want)
+	}
+}
+
+func Test_expectedRequests(t *testing.T) {
+	tests := []struct {
+		rate     vegeta.Rate
+		duration time.Duration
+		want     int
+	}{
+		{vegeta.Rate{Freq: 5, Per: time.Second}, time.Minute, 300},
+		{vegeta.Rate{Freq: 10, Per: time.Minute}, 90 * time.Second, 15},
+		{vegeta.Rate{Freq: 1, Per: time.Hour}, time.Minute, 1},
+	}
+	for _, tt := range tests {
+		options := &types.TestOptions{Rate: tt.rate, Duration: tt.duration}
+		if got := expectedRequests(options); got != tt.want {
+			t.Errorf("expectedRequests(%s for %s) = %d, want %d", tt.rate, tt.duration, got, tt.want)
+		}
+	}
+}
+
+func Test_reusedMachinePools(t *testing.T) {
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	options := &types.TestOptions{TestName: "patch-machine-pools", Logger: logger, RampSteps: 2}
+	key := clusterPoolKey{testName: options.TestName}
+	seeded := []machinePool{{clusterID: "abc", id: "mp-1"}}
+	machinePools[key] = seeded
+	defer delete(machinePools, key)
+
+	if got := reusedMachinePools(context.TODO(), options, []string{"abc"}); len(got) != 1 || got[0] != seeded[0] {
+		t.Errorf("reusedMachinePools() = %v, want the seeded machine pools", got)
+	}
+	if _, ok := machinePools[key]; !ok {
+		t.Errorf("machine pools forgotten before the last step")
+	}
+
+	options.RampStep = 1
+	if got := reusedMachinePools(context.TODO(), options, []string{"abc"}); len(got) != 1 || got[0] != seeded[0] {
+		t.Errorf("reusedMachinePools() = %v, want the seeded machine pools", got)
+	}
+	if _, ok := machinePools[key]; ok {
+		t.Errorf("machine pools kept after the last step")
+	}
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	v1 "github.com/openshift-online/ocm-sdk-go/accountsmgmt/v1"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	defaultRoleBindingRole     = "ClusterEditor"
+	subscriptionRoleBinding    = "Subscription"
+	clusterAuthorizationsPath  = "/api/accounts_mgmt/v1/cluster_authorizations"
+	maxQuotaAuthorizationItems = 3
+
+	// Defaults of the seeding of the role binding tests
+	defaultRoleBindingSeedWorkers = 5
+	defaultRoleBindingSeedRate    = 10
+)
+
+// TestOrganizationEndpoint performs a load test on an endpoint of the
+// organization of the current account, replacing `{orgId}` in the path.
+func TestOrganizationEndpoint(ctx context.Context, options *types.TestOptions) error {
+	orgID, err := currentOrganizationID(ctx, options.Connection)
+	if err != nil {
+		return err
+	}
+
+	options.Logger.Info(ctx, "Using Organization id: %s.", orgID)
+	options.Path = strings.Replace(options.Path, "{orgId}", orgID, 1)
+
+	return TestStaticEndpoint(ctx, options)
+}
+
+// currentOrganizationID returns the ID of the organization of the account of
+// the connection.
+func currentOrganizationID(ctx context.Context, conn *sdk.Connection) (string, error) {
+	acct, err := conn.AccountsMgmt().V1().CurrentAccount().Get().SendContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	org, ok := acct.Body().GetOrganization()
+	if !ok {
+		return "", fmt.Errorf("no organizations where found for this account")
+	}
+
+	orgID, ok := org.GetID()
+	if !ok {
+		return "", fmt.Errorf("no organizations where found for this account")
+	}
+	return orgID, nil
+}
+
+// TestCreateRoleBindings performs a load test on
+// "POST /api/accounts_mgmt/v1/role_bindings" binding the current account to a
+// new subscription on each request. The subscriptions are created by cluster
+// authorizations before the attack of each ramp step, one for every request
+// of the step, and are not part of the results.
+func TestCreateRoleBindings(ctx context.Context, options *types.TestOptions) error {
+	accountID, roleID, err := roleBindingConfig(ctx, options)
+	if err != nil {
+		return err
+	}
+
+	subscriptionIDs := seedRoleBindingPool(ctx, options, "subscriptions", func(ctx context.Context, i int) (string, error) {
+		return authorizeCluster(ctx, options)
+	})
+	var next uint64
+	targeter := func(t *vegeta.Target) error {
+		i := atomic.AddUint64(&next, 1) - 1
+		if i >= uint64(len(subscriptionIDs)) {
+			return vegeta.ErrNoTargets
+		}
+		body, err := roleBindingBody(accountID, roleID, subscriptionIDs[i])
+		if err != nil {
+			return err
+		}
+		t.Method = options.Method
+		t.URL = options.Path
+		t.Body = body
+		return nil
+	}
+
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, options.TestName) {
+		options.Encoder.Encode(res)
+	}
+
+	helpers.Cleanup(ctx, options.Connection)
+	return nil
+}
+
+// TestDeleteRoleBindings performs a load test on
+// "DELETE /api/accounts_mgmt/v1/role_bindings/{roleBindingId}". The role
+// bindings deleted, and their subscriptions, are created before the attack of
+// each ramp step, one for every request of the step, and are not part of the
+// results.
+func TestDeleteRoleBindings(ctx context.Context, options *types.TestOptions) error {
+	accountID, roleID, err := roleBindingConfig(ctx, options)
+	if err != nil {
+		return err
+	}
+
+	roleBindingIDs := seedRoleBindingPool(ctx, options, "role bindings", func(ctx context.Context, i int) (string, error) {
+		subscriptionID, err := authorizeCluster(ctx, options)
+		if err != nil {
+			return "", err
+		}
+		return createRoleBinding(ctx, options.Connection, accountID, roleID, subscriptionID)
+	})
+	var next uint64
+	targeter := func(t *vegeta.Target) error {
+		i := atomic.AddUint64(&next, 1) - 1
+		if i >= uint64(len(roleBindingIDs)) {
+			return vegeta.ErrNoTargets
+		}
+		t.Method = options.Method
+		t.URL = strings.Replace(options.Path, "{roleBindingId}", roleBindingIDs[i], 1)
+		return nil
+	}
+
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, options.TestName) {
+		options.Encoder.Encode(res)
+	}
+
+	helpers.Cleanup(ctx, options.Connection)
+	return nil
+}
+
+// seedRoleBindingPool creates one element for every request of the attack,
+// `seed-workers` at a time at up to `seed-rate` per second, and returns the
+// IDs of the ones created.
+func seedRoleBindingPool(ctx context.Context, options *types.TestOptions, name string, seed seedFunc) []string {
+	workers := viper.GetInt(fmt.Sprintf("tests.%s.seed-workers", options.TestName))
+	if workers <= 0 {
+		workers = defaultRoleBindingSeedWorkers
+	}
+	seedRate := viper.GetInt(fmt.Sprintf("tests.%s.seed-rate", options.TestName))
+	if seedRate <= 0 {
+		seedRate = defaultRoleBindingSeedRate
+	}
+	qty := expectedRequests(options)
+	options.Logger.Info(ctx, "Creating %d %s to use for %s test, %d at a time at %d/s",
+		qty, name, options.TestName, workers, seedRate)
+	ids, failures := seedPool(ctx, name, qty, workers,
+		vegeta.Rate{Freq: seedRate, Per: time.Second}, options.Logger, seed)
+	if failures > 0 {
+		options.Logger.Warn(ctx, "%d of %d %s failed to be created, using %d %s", failures, qty, name, len(ids), name)
+	}
+	return ids
+}
+
+// roleBindingConfig returns the ID of the current account and the role to bind
+// it to, from `role-id`.
+func roleBindingConfig(ctx context.Context, options *types.TestOptions) (string, string, error) {
+	acct, err := options.Connection.AccountsMgmt().V1().CurrentAccount().Get().SendContext(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	accountID, ok := acct.Body().GetID()
+	if !ok {
+		return "", "", fmt.Errorf("no ID found for the current account")
+	}
+	roleID := viper.GetString(fmt.Sprintf("tests.%s.role-id", options.TestName))
+	if roleID == "" {
+		roleID = defaultRoleBindingRole
+	}
+	options.Logger.Info(ctx, "Binding account '%s' to role '%s'", accountID, roleID)
+	return accountID, roleID, nil
+}
+
+func roleBindingBody(accountID, roleID, subscriptionID string) ([]byte, error) {
+	body, err := v1.NewRoleBinding().
+		AccountID(accountID).
+		RoleID(roleID).
+		SubscriptionID(subscriptionID).
+		Type(subscriptionRoleBinding).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	var raw bytes.Buffer
+	err = v1.MarshalRoleBinding(body, &raw)
+	if err != nil {
+		return nil, err
+	}
+	return raw.Bytes(), nil
+}
+
+// createRoleBinding creates a role binding, which is tracked for cleanup by the
+// CleanTestTransport, and returns its ID.
+func createRoleBinding(ctx context.Context, conn *sdk.Connection, accountID, roleID, subscriptionID string) (string, error) {
+	body, err := roleBindingBody(accountID, roleID, subscriptionID)
+	if err != nil {
+		return "", err
+	}
+	response, err := conn.Post().
+		Path(strings.TrimSuffix(helpers.RoleBindingsEndpoint, "/")).
+		Bytes(body).
+		SendContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if response.Status() != http.StatusCreated {
+		return "", fmt.Errorf("creating role binding: expected response code 201, instead found: %d", response.Status())
+	}
+	roleBinding, err := helpers.Parse(response.Bytes())
+	if err != nil {
+		return "", err
+	}
+	id, ok := roleBinding["id"].(string)
+	if !ok {
+		return "", fmt.Errorf("creating role binding: no ID in response")
+	}
+	return id, nil
+}
+
+// authorizeCluster reserves the quota of a fake cluster and returns the ID of
+// the subscription created for it, which is archived by the cleanup.
+func authorizeCluster(ctx context.Context, options *types.TestOptions) (string, error) {
+	response, err := options.Connection.Post().
+		Path(clusterAuthorizationsPath).
+		Bytes(clusterAuthorizationsBody(ctx, uuid.NewV4().String(), defaultAuthorizationMatrix, options)).
+		SendContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if response.Status() != http.StatusOK {
+		return "", fmt.Errorf("authorizing cluster: expected response code 200, instead found: %d", response.Status())
+	}
+	authorization, err := helpers.Parse(response.Bytes())
+	if err != nil {
+		return "", err
+	}
+	subscription, _ := authorization["subscription"].(map[string]interface{})
+	id, ok := subscription["id"].(string)
+	if !ok {
+		return "", fmt.Errorf("authorizing cluster: no subscription in response")
+	}
+	return id, nil
+}
+
+type quotaAuthorizationRequest struct {
+	AccountUsername  string                       `json:"account_username"`
+	AvailabilityZone string                       `json:"availability_zone"`
+	ProductID        string                       `json:"product_id"`
+	Reserve          bool                         `json:"reserve"`
+	Resources        []quotaAuthorizationResource `json:"resources"`
+}
+
+type quotaAuthorizationResource struct {
+	ResourceName string `json:"resource_name"`
+	ResourceType string `json:"resource_type"`
+	Count        int    `json:"count"`
+	BillingModel string `json:"billing_model"`
+	BYOC         bool   `json:"byoc"`
+}
+
+// TestQuotaAuthorizations performs a load test on
+// "POST /api/accounts_mgmt/v1/quota_authorizations" requesting a random set of
+// resources on each request. Quota is only reserved when `reserve` is set, the
+// subscriptions created then are archived by the cleanup.
+func TestQuotaAuthorizations(ctx context.Context, options *types.TestOptions) error {
+	reserve := viper.GetBool(fmt.Sprintf("tests.%s.reserve", options.TestName))
+
+	targeter := func(t *vegeta.Target) error {
+		body, err := quotaAuthorizationBody(reserve)
+		if err != nil {
+			return err
+		}
+		t.Method = options.Method
+		t.URL = options.Path
+		t.Body = body
+		return nil
+	}
+
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, options.TestName) {
+		options.Encoder.Encode(res)
+	}
+
+	helpers.Cleanup(ctx, options.Connection)
+	return nil
+}
+
+// quotaAuthorizationBody builds a request for 1 to 3 random compute resources.
+func quotaAuthorizationBody(reserve bool) ([]byte, error) {
+	resources := make([]quotaAuthorizationResource, 1+rand.Intn(maxQuotaAuthorizationItems))
+	for i := range resources {
+		resources[i] = quotaAuthorizationResource{
+			ResourceName: randomizeResourceName(),
+			ResourceType: helpers.AWSComputeNodeResourceType,
+			Count:        randomizeCount(),
+			BillingModel: helpers.StandardBillingModel,
+			BYOC:         rand.Intn(2) == 0,
+		}
+	}
+	return json.Marshal(quotaAuthorizationRequest{
+		AccountUsername:  helpers.ClusterAuthAccountUsername,
+		AvailabilityZone: helpers.SingleAvailabilityZone,
+		ProductID:        helpers.OsdProductID,
+		Reserve:          reserve,
+		Resources:        resources,
+	})
+}
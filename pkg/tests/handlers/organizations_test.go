@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+)
+
+func Test_roleBindingBody(t *testing.T) {
+	raw, err := roleBindingBody("account-1", "ClusterEditor", "subscription-1")
+	if err != nil {
+		t.Fatalf("roleBindingBody() error = %v", err)
+	}
+	body, err := helpers.Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing body: %v", err)
+	}
+	if body["account_id"] != "account-1" || body["role_id"] != "ClusterEditor" || body["subscription_id"] != "subscription-1" {
+		t.Errorf("roleBindingBody() = %s", raw)
+	}
+	if body["type"] != subscriptionRoleBinding {
+		t.Errorf("type = %v, want %s", body["type"], subscriptionRoleBinding)
+	}
+}
+
+func Test_quotaAuthorizationBody(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		raw, err := quotaAuthorizationBody(false)
+		if err != nil {
+			t.Fatalf("quotaAuthorizationBody() error = %v", err)
+		}
+		var body quotaAuthorizationRequest
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Fatalf("parsing body: %v", err)
+		}
+		if body.Reserve {
+			t.Errorf("reserve = true, want false")
+		}
+		if len(body.Resources) < 1 || len(body.Resources) > maxQuotaAuthorizationItems {
+			t.Fatalf("got %d resources, want between 1 and %d", len(body.Resources), maxQuotaAuthorizationItems)
+		}
+		for _, resource := range body.Resources {
+			if resource.Count < helpers.MinClusterCount || resource.Count >= helpers.MaxClusterCount {
+				t.Errorf("count = %d, out of range", resource.Count)
+			}
+			if resource.ResourceType != helpers.AWSComputeNodeResourceType || resource.ResourceName == "" {
+				t.Errorf("resource = %+v", resource)
+			}
+		}
+	}
+}
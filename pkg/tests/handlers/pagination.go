@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	"github.com/spf13/viper"
+)
+
+const defaultWalkPageSize = 100
+
+// TestPaginationWalk walks every page of a collection on each iteration,
+// following `page` and `size` until `total` items are read, as console-like
+// clients do. Every page request is recorded as the `<test-name>/page` step,
+// with the page number in its URL, and the whole traversal as the
+// `<test-name>/walk` step.
+func TestPaginationWalk(ctx context.Context, options *types.TestOptions) error {
+	pageSize := viper.GetInt(fmt.Sprintf("tests.%s.page-size", options.TestName))
+	if pageSize <= 0 {
+		pageSize = defaultWalkPageSize
+	}
+	maxPages := viper.GetInt(fmt.Sprintf("tests.%s.max-pages", options.TestName))
+	search := viper.GetString(fmt.Sprintf("tests.%s.search", options.TestName))
+
+	runIterations(ctx, options, func(ctx context.Context, seq uint64, rec *stepRecorder) {
+		walkCollection(ctx, rec, options.Path, search, pageSize, maxPages)
+	})
+
+	return nil
+}
+
+// walkCollection reads every page of the collection, or the first `maxPages`
+// ones when it is not zero.
+func walkCollection(ctx context.Context, rec *stepRecorder, path, search string, pageSize, maxPages int) {
+	began := time.Now()
+	bytesIn, code := 0, 0
+	read := 0
+	var err error
+	for page := 1; ; page++ {
+		var length int
+		var result map[string]interface{}
+		code, length, result, err = getPage(ctx, rec, path, search, page, pageSize)
+		bytesIn += length
+		if err != nil {
+			break
+		}
+		total, _ := result["total"].(float64)
+		size := pageItems(result)
+		read += size
+		if !morePages(page, size, read, int(total), maxPages) {
+			break
+		}
+	}
+	rec.Record("walk", http.MethodGet, path, began, code, 0, bytesIn, err)
+}
+
+// getPage reads one page of the collection and records it as the `page` step,
+// returning the status code, the size of the response and the parsed page.
+func getPage(ctx context.Context, rec *stepRecorder, path, search string, page, size int) (int, int, map[string]interface{}, error) {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("size", strconv.Itoa(size))
+	if search != "" {
+		query.Set("search", search)
+	}
+
+	response, err := rec.Send(ctx, "page", http.MethodGet, path, query, nil, http.StatusOK)
+	if response == nil {
+		return 0, 0, nil, err
+	}
+	if err != nil {
+		return response.Status(), len(response.Bytes()), nil, err
+	}
+
+	result, err := helpers.Parse(response.Bytes())
+	if err != nil {
+		return response.Status(), len(response.Bytes()), nil, fmt.Errorf("GET %s: parsing page %d: %v", path, page, err)
+	}
+	return response.Status(), len(response.Bytes()), result, nil
+}
+
+// pageItems returns the number of items of a page, the `size` returned by the
+// server, which may be less than the one requested.
+func pageItems(result map[string]interface{}) int {
+	if size, ok := result["size"].(float64); ok {
+		return int(size)
+	}
+	items, _ := result["items"].([]interface{})
+	return len(items)
+}
+
+// morePages tells if there is a page after the given one, according to the
+// total of the collection, the items read so far and the size of the current
+// page.
+func morePages(page, size, read, total, maxPages int) bool {
+	if maxPages > 0 && page >= maxPages {
+		return false
+	}
+	return size > 0 && read < total
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import "testing"
+
+func Test_morePages(t *testing.T) {
+	tests := []struct {
+		name                             string
+		page, size, read, total, maxPage int
+		want                             bool
+	}{
+		{"first of many", 1, 100, 100, 250, 0, true},
+		{"last partial page", 3, 50, 250, 250, 0, false},
+		{"exact last page", 2, 100, 200, 200, 0, false},
+		{"empty page", 2, 0, 100, 250, 0, false},
+		{"empty collection", 1, 0, 0, 0, 0, false},
+		{"max pages reached", 2, 100, 200, 1000, 2, false},
+		{"under max pages", 1, 100, 100, 1000, 2, true},
+		{"size capped by the server", 2, 50, 100, 250, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := morePages(tt.page, tt.size, tt.read, tt.total, tt.maxPage); got != tt.want {
+				t.Errorf("morePages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pageItems(t *testing.T) {
+	items := []interface{}{map[string]interface{}{}, map[string]interface{}{}}
+	if got := pageItems(map[string]interface{}{"size": float64(50), "items": items}); got != 50 {
+		t.Errorf("pageItems() = %d, want the size returned, 50", got)
+	}
+	if got := pageItems(map[string]interface{}{"items": items}); got != 2 {
+		t.Errorf("pageItems() without size = %d, want the number of items, 2", got)
+	}
+}
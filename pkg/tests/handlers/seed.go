@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// seedFunc creates the i-th element of a pool and returns its ID.
+type seedFunc func(ctx context.Context, i int) (string, error)
+
+// seedPool creates qty elements running up to `workers` seedFunc at the same
+// time, started at no more than `rate`. A zero rate does not limit them.
+// Progress is logged every 10% and the elements that could not be created are
+// logged and left out of the returned IDs, along with the number of failures.
+func seedPool(ctx context.Context, name string, qty, workers int, rate vegeta.Rate, logger logging.Logger, seed seedFunc) ([]string, int) {
+	if workers <= 0 {
+		workers = 1
+	}
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		began := time.Now()
+		for i := 0; i < qty; i++ {
+			if rate.Freq > 0 {
+				wait, _ := rate.Pace(time.Since(began), uint64(i))
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		ids      = make([]string, 0, qty)
+		failures = 0
+		done     = 0
+		step     = qty / 10
+	)
+	if step == 0 {
+		step = 1
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				id, err := seed(ctx, i)
+				mu.Lock()
+				done++
+				if err != nil {
+					failures++
+					logger.Error(ctx, "Seeding %s [%d/%d]: %v", name, i+1, qty, err)
+				} else {
+					ids = append(ids, id)
+				}
+				if done%step == 0 || done == qty {
+					logger.Info(ctx, "Seeding %s: %d/%d done, %d failed", name, done, qty, failures)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return ids, failures
+}
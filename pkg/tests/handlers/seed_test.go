@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func Test_seedPool(t *testing.T) {
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+
+	var running, maxRunning int32
+	ids, failures := seedPool(context.TODO(), "test", 20, 4, vegeta.Rate{}, logger, func(ctx context.Context, i int) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		if i%5 == 0 {
+			return "", fmt.Errorf("failed %d", i)
+		}
+		return fmt.Sprint(i), nil
+	})
+	if failures != 4 || len(ids) != 16 {
+		t.Errorf("seedPool() = %d ids, %d failures, want 16 ids, 4 failures", len(ids), failures)
+	}
+	sort.Strings(ids)
+	for i := 1; i < len(ids); i++ {
+		if ids[i] == ids[i-1] {
+			t.Errorf("seedPool() returned %s twice", ids[i])
+		}
+	}
+	if maxRunning > 4 {
+		t.Errorf("seedPool() ran %d seeds at the same time, want at most 4", maxRunning)
+	}
+}
+
+func Test_seedPool_rate(t *testing.T) {
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	began := time.Now()
+	ids, _ := seedPool(context.TODO(), "test", 5, 5, vegeta.Rate{Freq: 50, Per: time.Second}, logger, func(ctx context.Context, i int) (string, error) {
+		return fmt.Sprint(i), nil
+	})
+	if len(ids) != 5 {
+		t.Fatalf("seedPool() = %d ids, want 5", len(ids))
+	}
+	// 5 seeds at 50/s start over 80ms
+	if elapsed := time.Since(began); elapsed < 60*time.Millisecond {
+		t.Errorf("seedPool() took %s, the rate was not applied", elapsed)
+	}
+}
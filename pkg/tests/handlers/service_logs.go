@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	v1 "github.com/openshift-online/ocm-sdk-go/servicelogs/v1"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	defaultServiceLogClusters = 10
+	defaultServiceLogSeed     = 5
+	serviceLogServiceName     = "ocm-api-load"
+)
+
+var serviceLogSeverities = []v1.Severity{
+	v1.SeverityDebug,
+	v1.SeverityInfo,
+	v1.SeverityWarning,
+	v1.SeverityError,
+	v1.SeverityFatal,
+}
+
+// TestCreateServiceLogs performs a load test on
+// "POST /api/service_logs/v1/cluster_logs" posting random entries for a pool of
+// cluster UUIDs. The entries are deleted at the end of the test.
+func TestCreateServiceLogs(ctx context.Context, options *types.TestOptions) error {
+	return testServiceLogPool(ctx, options, 0, func(clusterUUIDs []string) vegeta.Targeter {
+		pick := poolPicker(clusterPoolSelection(options), len(clusterUUIDs))
+		return func(t *vegeta.Target) error {
+			body, err := serviceLogBody(clusterUUIDs[pick()])
+			if err != nil {
+				return err
+			}
+			t.Method = options.Method
+			t.URL = options.Path
+			t.Body = body
+			return nil
+		}
+	})
+}
+
+// TestListServiceLogs performs a load test on
+// "GET /api/service_logs/v1/cluster_logs" searching the entries of one cluster
+// UUID of the pool per request. `seed` entries are posted for every cluster
+// UUID before the attack.
+func TestListServiceLogs(ctx context.Context, options *types.TestOptions) error {
+	seed := viper.GetInt(fmt.Sprintf("tests.%s.seed", options.TestName))
+	if seed <= 0 {
+		seed = defaultServiceLogSeed
+	}
+	return testServiceLogPool(ctx, options, seed, func(clusterUUIDs []string) vegeta.Targeter {
+		pick := poolPicker(clusterPoolSelection(options), len(clusterUUIDs))
+		return func(t *vegeta.Target) error {
+			t.Method = options.Method
+			t.URL = serviceLogSearchURL(options.Path, clusterUUIDs[pick()])
+			return nil
+		}
+	})
+}
+
+// serviceLogPool is the pool of cluster UUIDs of a connection, with the IDs of
+// the entries posted for them.
+type serviceLogPool struct {
+	clusterUUIDs []string
+	entryIDs     []string
+	mu           sync.Mutex
+}
+
+func (p *serviceLogPool) add(ids ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entryIDs = append(p.entryIDs, ids...)
+}
+
+// serviceLogPools keeps the pool of each connection across ramp steps.
+var (
+	serviceLogPools   = map[clusterPoolKey]*serviceLogPool{}
+	serviceLogPoolsMu sync.Mutex
+)
+
+// testServiceLogPool attacks with the targeter built for the pool of cluster
+// UUIDs of the connection. The pool is created at the first ramp step, with
+// `seed` entries posted for every cluster UUID, and reused by the following
+// ones. At the last step, the entries posted by the connection are deleted;
+// the ones of the other connections, which may still be attacking, are left
+// alone.
+func testServiceLogPool(ctx context.Context, options *types.TestOptions, seed int, buildTargeter func(clusterUUIDs []string) vegeta.Targeter) error {
+	key := clusterPoolKey{testName: options.TestName, connection: options.Connection}
+	serviceLogPoolsMu.Lock()
+	pool, ok := serviceLogPools[key]
+	serviceLogPoolsMu.Unlock()
+	if ok {
+		options.Logger.Info(ctx, "Reusing %d cluster UUIDs for %s test", len(pool.clusterUUIDs), options.TestName)
+	} else {
+		pool = &serviceLogPool{clusterUUIDs: newClusterUUIDs(serviceLogClusters(options))}
+		if seed > 0 {
+			err := seedServiceLogs(ctx, options, pool, seed)
+			if err != nil {
+				helpers.CleanupResources(ctx, options.Connection, helpers.ServiceLogsEndpoint, pool.entryIDs)
+				return err
+			}
+		}
+		serviceLogPoolsMu.Lock()
+		serviceLogPools[key] = pool
+		serviceLogPoolsMu.Unlock()
+	}
+
+	targeter := buildTargeter(pool.clusterUUIDs)
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, options.TestName) {
+		if res.Code == http.StatusCreated {
+			if id := serviceLogID(res.Body); id != "" {
+				pool.add(id)
+			}
+		}
+		options.Encoder.Encode(res)
+	}
+
+	if options.LastStep() {
+		serviceLogPoolsMu.Lock()
+		delete(serviceLogPools, key)
+		serviceLogPoolsMu.Unlock()
+		helpers.CleanupResources(ctx, options.Connection, helpers.ServiceLogsEndpoint, pool.entryIDs)
+	}
+	return nil
+}
+
+// seedServiceLogs posts `seed` entries for every cluster UUID of the pool,
+// `seed-workers` at a time at up to `seed-rate` per second.
+func seedServiceLogs(ctx context.Context, options *types.TestOptions, pool *serviceLogPool, seed int) error {
+	workers := viper.GetInt(fmt.Sprintf("tests.%s.seed-workers", options.TestName))
+	if workers <= 0 {
+		workers = defaultClusterPoolSeedWorkers
+	}
+	seedRate := viper.GetInt(fmt.Sprintf("tests.%s.seed-rate", options.TestName))
+	if seedRate <= 0 {
+		seedRate = defaultClusterPoolSeedRate
+	}
+	qty := seed * len(pool.clusterUUIDs)
+
+	options.Logger.Info(ctx, "Posting %d service logs for %d clusters to use for %s test, %d at a time at %d/s",
+		seed, len(pool.clusterUUIDs), options.TestName, workers, seedRate)
+	ids, failures := seedPool(ctx, "service logs", qty, workers,
+		vegeta.Rate{Freq: seedRate, Per: time.Second}, options.Logger,
+		func(ctx context.Context, i int) (string, error) {
+			return postServiceLog(ctx, options.Connection, pool.clusterUUIDs[i%len(pool.clusterUUIDs)])
+		})
+	pool.add(ids...)
+	if failures > 0 {
+		return fmt.Errorf("%d of %d service logs could not be posted for %s test", failures, qty, options.TestName)
+	}
+	return nil
+}
+
+func serviceLogClusters(options *types.TestOptions) int {
+	clusters := viper.GetInt(fmt.Sprintf("tests.%s.pool-size", options.TestName))
+	if clusters <= 0 {
+		return defaultServiceLogClusters
+	}
+	return clusters
+}
+
+// newClusterUUIDs returns qty random cluster UUIDs. Service logs do not need
+// the clusters to exist.
+func newClusterUUIDs(qty int) []string {
+	clusterUUIDs := make([]string, qty)
+	for i := range clusterUUIDs {
+		clusterUUIDs[i] = uuid.NewV4().String()
+	}
+	return clusterUUIDs
+}
+
+// serviceLogBody builds a cluster log entry with a random severity and text.
+// Entries are internal only, so they are never shown to customers.
+func serviceLogBody(clusterUUID string) ([]byte, error) {
+	id := uuid.NewV4().String()[:8]
+	body, err := v1.NewLogEntry().
+		ClusterUUID(clusterUUID).
+		ServiceName(serviceLogServiceName).
+		Severity(serviceLogSeverities[rand.Intn(len(serviceLogSeverities))]).
+		Summary(fmt.Sprintf("pocm load test %s", id)).
+		Description(fmt.Sprintf("Entry %s posted by the load test: %s", id, randomText(64))).
+		InternalOnly(true).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	var raw bytes.Buffer
+	err = v1.MarshalLogEntry(body, &raw)
+	if err != nil {
+		return nil, err
+	}
+	return raw.Bytes(), nil
+}
+
+// postServiceLog posts a random cluster log entry, which is tracked for cleanup
+// by the CleanTestTransport, and returns its ID.
+func postServiceLog(ctx context.Context, connection *sdk.Connection, clusterUUID string) (string, error) {
+	body, err := serviceLogBody(clusterUUID)
+	if err != nil {
+		return "", err
+	}
+	response, err := connection.Post().
+		Path(helpers.ServiceLogsEndpoint).
+		Bytes(body).
+		SendContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if response.Status() != http.StatusCreated {
+		return "", fmt.Errorf("posting service log: expected response code 201, instead found: %d", response.Status())
+	}
+	id := serviceLogID(response.Bytes())
+	if id == "" {
+		return "", fmt.Errorf("posting service log: no ID in the response")
+	}
+	return id, nil
+}
+
+// serviceLogID returns the ID of the entry of a response body, empty when
+// there is none.
+func serviceLogID(body []byte) string {
+	entry, err := helpers.Parse(body)
+	if err != nil {
+		return ""
+	}
+	id, _ := entry["id"].(string)
+	return id
+}
+
+func serviceLogSearchURL(path, clusterUUID string) string {
+	query := url.Values{}
+	query.Set("search", fmt.Sprintf("cluster_uuid = '%s'", clusterUUID))
+	return fmt.Sprintf("%s?%s", path, query.Encode())
+}
+
+const randomTextLetters = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+func randomText(n int) string {
+	text := make([]byte, n)
+	for i := range text {
+		text[i] = randomTextLetters[rand.Intn(len(randomTextLetters))]
+	}
+	return string(text)
+}
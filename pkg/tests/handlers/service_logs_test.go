@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+)
+
+func Test_serviceLogBody(t *testing.T) {
+	raw, err := serviceLogBody("5b3b4d1e-0000-4000-8000-000000000000")
+	if err != nil {
+		t.Fatalf("serviceLogBody() error = %v", err)
+	}
+	body, err := helpers.Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing body: %v", err)
+	}
+	if body["cluster_uuid"] != "5b3b4d1e-0000-4000-8000-000000000000" {
+		t.Errorf("cluster_uuid = %v", body["cluster_uuid"])
+	}
+	if body["internal_only"] != true {
+		t.Errorf("internal_only = %v, want true", body["internal_only"])
+	}
+	if body["service_name"] != serviceLogServiceName {
+		t.Errorf("service_name = %v, want %s", body["service_name"], serviceLogServiceName)
+	}
+	if body["severity"] == "" || body["summary"] == "" || body["description"] == "" {
+		t.Errorf("serviceLogBody() = %s, missing severity, summary or description", raw)
+	}
+
+	other, err := serviceLogBody("5b3b4d1e-0000-4000-8000-000000000000")
+	if err != nil {
+		t.Fatalf("serviceLogBody() error = %v", err)
+	}
+	if string(other) == string(raw) {
+		t.Errorf("serviceLogBody() returned the same body twice")
+	}
+}
+
+func Test_serviceLogSearchURL(t *testing.T) {
+	got := serviceLogSearchURL("/api/service_logs/v1/cluster_logs", "abc")
+	parsed, err := url.Parse(got)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", got, err)
+	}
+	if parsed.Path != "/api/service_logs/v1/cluster_logs" {
+		t.Errorf("path = %q", parsed.Path)
+	}
+	if search := parsed.Query().Get("search"); search != "cluster_uuid = 'abc'" {
+		t.Errorf("search = %q, want %q", search, "cluster_uuid = 'abc'")
+	}
+}
+
+func Test_serviceLogID(t *testing.T) {
+	if got := serviceLogID([]byte(`{"kind":"ClusterLog","id":"2a5b7c"}`)); got != "2a5b7c" {
+		t.Errorf("serviceLogID() = %q, want 2a5b7c", got)
+	}
+	if got := serviceLogID([]byte(`not json`)); got != "" {
+		t.Errorf("serviceLogID() = %q, want none for an invalid body", got)
+	}
+}
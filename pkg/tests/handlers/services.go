@@ -20,7 +20,7 @@ func TestCreateService(ctx context.Context, options *types.TestOptions) error {
 	testName := options.TestName
 	targeter := generateCreateServiceTargeter(ctx, options.ID, options.Method, options.Path, options.Logger)
 
-	for res := range options.Attacker.Attack(targeter, options.Rate, options.Duration, testName) {
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, testName) {
 		options.Encoder.Encode(res)
 	}
 
@@ -195,7 +195,7 @@ func TestPatchService(ctx context.Context, options *types.TestOptions) error {
 	testName := options.TestName
 	targeter := generatePatchServiceTargeter(ctx, options.ID, options.Method, options.Path, options.Logger, serviceIds)
 
-	for res := range options.Attacker.Attack(targeter, options.Rate, options.Duration, testName) {
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, testName) {
 		options.Encoder.Encode(res)
 	}
 
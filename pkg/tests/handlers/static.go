@@ -20,7 +20,7 @@ func TestStaticEndpoint(ctx context.Context, options *types.TestOptions) error {
 	targeter := vegeta.NewStaticTargeter(target)
 
 	// Execute the HTTP Requests; repeating as needed to meet the specified duration
-	for res := range options.Attacker.Attack(targeter, options.Rate, options.Duration, options.TestName) {
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, options.TestName) {
 		options.Encoder.Encode(res)
 	}
 
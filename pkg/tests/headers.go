@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/ocm"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/spf13/viper"
+)
+
+// runHeaders returns the `headers` of the config, sent with every request of
+// the run.
+func runHeaders() http.Header {
+	return configuredHeaders("headers")
+}
+
+// testHeaders returns the `headers` of a test, which take precedence over the
+// ones of the run.
+func testHeaders(testName string) http.Header {
+	return configuredHeaders(fmt.Sprintf("tests.%s.headers", testName))
+}
+
+func configuredHeaders(key string) http.Header {
+	headers := http.Header{}
+	for name, value := range viper.GetStringMapString(key) {
+		headers.Set(name, value)
+	}
+	return headers
+}
+
+// setRunHeaders sets the test ID and the headers of the run on the clients, so
+// every request to the gateway carries them, see ocm.RunHeaders.
+func (r *Runner) setRunHeaders(s runSettings) {
+	headers := runHeaders()
+	for _, client := range r.clients {
+		if client.Headers != nil {
+			client.Headers.Set(r.testID, headers, s.operationIDHeaders)
+		}
+	}
+}
+
+// attackTransport sends the requests of an attack with the headers of the
+// test. Vegeta builds its requests without the context of the test, so they
+// are added to the context of every request. The traceparent is set here, so
+// the trace ID of the requests failing without a response is recorded too.
+type attackTransport struct {
+	wrapped http.RoundTripper
+	headers http.Header
+	traces  *results.ErrorTraces
+}
+
+func (t *attackTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(ocm.WithTestHeaders(request.Context(), t.headers))
+	traceID := ocm.TraceID(request.Header.Get(ocm.TraceparentHeader))
+	if traceID == "" {
+		var traceparent string
+		traceID, traceparent = ocm.NewTraceparent()
+		request.Header.Set(ocm.TraceparentHeader, traceparent)
+	}
+	response, err := t.wrapped.RoundTrip(request)
+	if err != nil {
+		t.traces.Record(request, traceID)
+	}
+	return response, err
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func Test_testHeaders(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("headers", map[string]interface{}{"X-Team": "perfscale", "X-Env": "stage"})
+	viper.Set("tests", map[string]interface{}{
+		"list-clusters": map[string]interface{}{"headers": map[string]interface{}{"X-Env": "integration"}},
+	})
+
+	headers := runHeaders()
+	if headers.Get("X-Team") != "perfscale" || headers.Get("X-Env") != "stage" || len(headers) != 2 {
+		t.Errorf("runHeaders() = %v, want the global headers", headers)
+	}
+	headers = testHeaders("list-clusters")
+	if headers.Get("X-Env") != "integration" || len(headers) != 1 {
+		t.Errorf("testHeaders() = %v, want the headers of the test", headers)
+	}
+	headers = testHeaders("list-subscriptions")
+	if len(headers) != 0 {
+		t.Errorf("testHeaders() = %v, want no headers", headers)
+	}
+}
+
+func Test_attackTransport_errorTraces(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	traces := results.NewErrorTraces()
+	transport := &attackTransport{wrapped: http.DefaultTransport, headers: http.Header{}, traces: traces}
+	attacker := vegeta.NewAttacker(vegeta.Client(&http.Client{Transport: transport}))
+	encoded := []*vegeta.Result{}
+	encoder := results.NewErrorTraceEncoder(func(res *vegeta.Result) error {
+		encoded = append(encoded, res)
+		return nil
+	}, traces)
+	targeter := vegeta.NewStaticTargeter(vegeta.Target{Method: http.MethodGet, URL: server.URL})
+	for res := range attacker.Attack(targeter, vegeta.Rate{Freq: 20, Per: time.Second}, 200*time.Millisecond, "list-clusters") {
+		if err := encoder.Encode(res); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+
+	traceIDs := map[string]bool{}
+	for _, res := range encoded {
+		traceID := res.Headers.Get(results.TraceIDHeader)
+		if res.Error == "" || len(traceID) != 32 || traceIDs[traceID] {
+			t.Errorf("result %d: error = %q, trace ID = %q, want a failed request with its own trace ID", res.Seq, res.Error, traceID)
+		}
+		traceIDs[traceID] = true
+	}
+	if len(encoded) == 0 {
+		t.Errorf("no result encoded")
+	}
+}
@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+)
+
+// writeIdentitySummary writes the summary of every test broken down by the
+// identity that sent the requests to `<test-id>_identity_summary.txt`, to spot
+// users or organizations slower than the rest.
+func (r *Runner) writeIdentitySummary(ctx context.Context) {
+	summaries, err := results.LoadDirByIdentity(r.outputDirectory, r.testID)
+	if err != nil {
+		r.logger.Warn(ctx, "summarizing results by identity: %v", err)
+		return
+	}
+	keys := make([]string, 0, len(summaries))
+	for k := range summaries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sorted := make([]*results.Summary, 0, len(keys))
+	for _, k := range keys {
+		sorted = append(sorted, summaries[k])
+	}
+
+	summaryFile, err := helpers.CreateFile(fmt.Sprintf("%s_identity_summary.txt", r.testID), r.outputDirectory)
+	if err != nil {
+		r.logger.Error(ctx, "writing identity summary: %v", err)
+		return
+	}
+	defer summaryFile.Close()
+	err = results.WriteSummaries(summaryFile, sorted)
+	if err != nil {
+		r.logger.Error(ctx, "writing identity summary: %v", err)
+		return
+	}
+	r.logger.Info(ctx, "Identity summary written to: %s", summaryFile.Name())
+}
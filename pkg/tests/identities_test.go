@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/ocm"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestRunner_writeIdentitySummary(t *testing.T) {
+	dir := t.TempDir()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	clients := []*ocm.Client{
+		{Identity: results.Identity{Connection: "auth-0", OrganizationID: "org-a"}},
+		{Identity: results.Identity{Connection: "hot-org", ConnectionIndex: 1, Organization: "Hot Org"}},
+	}
+	runner := NewRunner("run", dir, logger, clients)
+	for i, client := range clients {
+		res := []*vegeta.Result{{Attack: "list-clusters", Code: 200, Timestamp: time.Now(), Latency: time.Duration(i+1) * time.Second}}
+		err := runner.writeResults(fmt.Sprintf("run_list-clusters_%d.json", i), client.Identity, res)
+		if err != nil {
+			t.Fatalf("writeResults() error = %v", err)
+		}
+	}
+
+	runner.writeIdentitySummary(context.TODO())
+
+	report, err := os.ReadFile(filepath.Join(dir, "run_identity_summary.txt"))
+	if err != nil {
+		t.Fatalf("reading identity summary: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(report)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("identity summary = %s, want a header and a row per identity", report)
+	}
+	for i, want := range []string{"auth-0", "hot-org"} {
+		if !strings.Contains(lines[i+1], want) {
+			t.Errorf("row %q does not contain %s", lines[i+1], want)
+		}
+	}
+	if !strings.Contains(lines[2], "Hot Org") {
+		t.Errorf("row %q does not contain the organization", lines[2])
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+)
+
+// suiteState is the progress of a run, saved to `<test-id>_state.json` so an
+// interrupted run can be resumed, see Runner.Resume. The tests completed are
+// recorded by their markers, `<test-id>_<test-name>.done`, written once every
+// client finished them and closed their result files.
+type suiteState struct {
+	TestID  string    `json:"test_id"`
+	Started time.Time `json:"started"`
+	// Tests are the tests of the run, in order.
+	Tests []string `json:"tests"`
+	// Running is the test being run, if any.
+	Running string `json:"running,omitempty"`
+	// Profiles holds the rate profile of each completed test.
+	Profiles map[string]string `json:"profiles"`
+	// Resumes is the number of times the run was resumed.
+	Resumes int `json:"resumes"`
+
+	path string
+}
+
+func statePath(outputDirectory, testID string) string {
+	return filepath.Join(outputDirectory, fmt.Sprintf("%s_state.json", testID))
+}
+
+func (r *Runner) markerPath(testName string) string {
+	return filepath.Join(r.outputDirectory, fmt.Sprintf("%s_%s.done", r.testID, testName))
+}
+
+// Resume makes the runner continue an interrupted run with the same test ID:
+// the tests completed by it are skipped, and the test it was running is run
+// again from the start.
+func (r *Runner) Resume() {
+	r.resume = true
+}
+
+// openState returns the state of the run, the one of the interrupted run
+// when resuming.
+func (r *Runner) openState(ctx context.Context, testNames []string) (*suiteState, error) {
+	path := statePath(r.outputDirectory, r.testID)
+	state := &suiteState{
+		TestID:   r.testID,
+		Started:  time.Now(),
+		Profiles: map[string]string{},
+		path:     path,
+	}
+	if r.resume {
+		b, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no run %s to resume in %s", r.testID, r.outputDirectory)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(b, state)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %v", path, err)
+		}
+		if state.Profiles == nil {
+			state.Profiles = map[string]string{}
+		}
+		state.Resumes++
+		r.logger.Info(ctx, "Resuming run %s started at %s", r.testID, state.Started.Format(time.RFC3339))
+		if state.Running != "" {
+			r.logger.Info(ctx, "Test %s was interrupted, it is run again", state.Running)
+		}
+	}
+	state.Tests = testNames
+	return state, state.save()
+}
+
+func (s *suiteState) save() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, b, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// completed reports whether a resumed run can skip the test, completed by the
+// interrupted run, restoring its rate profile.
+func (r *Runner) completed(state *suiteState, testName string) bool {
+	if !r.resume {
+		return false
+	}
+	if _, err := os.Stat(r.markerPath(testName)); err != nil {
+		return false
+	}
+	files, err := results.TestResultFiles(r.outputDirectory, r.testID, testName)
+	if err != nil || len(files) == 0 {
+		return false
+	}
+	if profile, ok := state.Profiles[testName]; ok {
+		r.recordProfile(testName, profile)
+	}
+	return true
+}
+
+// startTest removes the marker and the result files left by a previous run of
+// the test, and records it as running.
+func (r *Runner) startTest(ctx context.Context, state *suiteState, testName string) {
+	err := os.Remove(r.markerPath(testName))
+	if err != nil && !os.IsNotExist(err) {
+		r.logger.Warn(ctx, "removing completion marker of %s: %v", testName, err)
+	}
+	if r.resume {
+		files, _ := results.TestResultFiles(r.outputDirectory, r.testID, testName)
+		for _, f := range files {
+			r.logger.Info(ctx, "Removing result file %s of the interrupted run", filepath.Base(f))
+			err := os.Remove(f)
+			if err != nil {
+				r.logger.Warn(ctx, "removing %s: %v", f, err)
+			}
+		}
+	}
+	state.Running = testName
+	err = state.save()
+	if err != nil {
+		r.logger.Error(ctx, "saving run state: %v", err)
+	}
+}
+
+// completeTest writes the completion marker of the test, once its result
+// files are closed.
+func (r *Runner) completeTest(ctx context.Context, state *suiteState, testName string) {
+	if profile, ok := r.Profiles()[testName]; ok {
+		state.Profiles[testName] = profile
+	}
+	state.Running = ""
+	err := state.save()
+	if err != nil {
+		r.logger.Error(ctx, "saving run state: %v", err)
+	}
+	err = os.WriteFile(r.markerPath(testName), []byte(time.Now().Format(time.RFC3339)+"\n"), 0o644)
+	if err != nil {
+		r.logger.Error(ctx, "writing completion marker of %s: %v", testName, err)
+	}
+}
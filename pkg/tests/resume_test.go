@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestRunner_Resume(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("tests", map[string]interface{}{
+		"self-access-token":  map[string]interface{}{},
+		"list-subscriptions": map[string]interface{}{},
+	})
+	dir := t.TempDir()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	res := []*vegeta.Result{{Attack: "self-access-token", Code: 200, Timestamp: time.Now(), Latency: time.Second}}
+
+	// The interrupted run completed self-access-token and was running
+	// list-subscriptions.
+	runner := NewRunner("run", dir, logger, nil)
+	state, err := runner.openState(context.TODO(), []string{"self-access-token", "list-subscriptions"})
+	if err != nil {
+		t.Fatalf("openState() error = %v", err)
+	}
+	runner.startTest(context.TODO(), state, "self-access-token")
+	if err := runner.writeResults("run_self-access-token_0.json", results.Identity{}, res); err != nil {
+		t.Fatalf("writeResults() error = %v", err)
+	}
+	runner.recordProfile("self-access-token", "10/s")
+	runner.completeTest(context.TODO(), state, "self-access-token")
+	runner.startTest(context.TODO(), state, "list-subscriptions")
+	partial := filepath.Join(dir, "run_list-subscriptions_0.json")
+	if err := os.WriteFile(partial, []byte("{"), 0o644); err != nil {
+		t.Fatalf("writing partial result file: %v", err)
+	}
+
+	if err := NewRunner("other", dir, logger, nil).Execute(context.TODO()); err != nil {
+		t.Fatalf("Execute() of another run error = %v", err)
+	}
+	missing := NewRunner("missing", dir, logger, nil)
+	missing.Resume()
+	if err := missing.Execute(context.TODO()); err == nil {
+		t.Errorf("Execute() should fail to resume a run without state")
+	}
+
+	resumed := NewRunner("run", dir, logger, nil)
+	resumed.Resume()
+	if err := resumed.Execute(context.TODO()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("result file of the interrupted test was not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "run_self-access-token_0.json")); err != nil {
+		t.Errorf("result file of the completed test was removed: %v", err)
+	}
+	for _, testName := range []string{"self-access-token", "list-subscriptions"} {
+		if _, err := os.Stat(resumed.markerPath(testName)); err != nil {
+			t.Errorf("missing completion marker of %s: %v", testName, err)
+		}
+	}
+	if got := resumed.Profiles()["self-access-token"]; got != "10/s" {
+		t.Errorf("profile of the skipped test = %q, want 10/s", got)
+	}
+
+	state, err = resumed.openState(context.TODO(), nil)
+	if err != nil {
+		t.Fatalf("openState() error = %v", err)
+	}
+	if state.Resumes != 2 || state.Running != "" {
+		t.Errorf("state = %+v, want the run resumed twice and no test running", state)
+	}
+}
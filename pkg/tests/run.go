@@ -6,173 +6,377 @@ import (
 	"math"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/config"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/elastic"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/ocm"
 	ramp "github.com/cloud-bulldozer/ocm-api-load/pkg/ramping"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
-	sdk "github.com/openshift-online/ocm-sdk-go"
 	"github.com/spf13/viper"
 	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
 // Runner prepares config and runs tests
 type Runner struct {
-	connections     []*sdk.Connection
+	clients         []*ocm.Client
 	logger          logging.Logger
 	outputDirectory string
 	testID          string
+
+	// profiles holds the rate profile of each executed test
+	profiles   map[string]string
+	profilesMu sync.Mutex
+	// version is the server version of a run executed by agents, see
+	// Collected.
+	version string
+
+	// agent is the index of the runner among the agents of a distributed
+	// run, see Share.
+	agent  int
+	agents int
+
+	// resume skips the tests completed by an interrupted run, see Resume.
+	resume bool
 }
 
-func NewRunner(testID, outputDirectory string, logger logging.Logger, connections []*sdk.Connection) *Runner {
+func NewRunner(testID, outputDirectory string, logger logging.Logger, clients []*ocm.Client) *Runner {
 	return &Runner{
-		connections:     connections,
+		clients:         clients,
 		logger:          logger,
 		outputDirectory: outputDirectory,
 		testID:          testID,
+		profiles:        map[string]string{},
+		agents:          1,
 	}
 }
 
+// Share makes the runner send its share of the load of a run distributed
+// across agents: the rates of the tests are divided among them, and the result
+// files are numbered so the files of every agent can be collected in a single
+// output directory.
+func (r *Runner) Share(agent, agents int) {
+	r.agent = agent
+	r.agents = agents
+}
+
+// fileIndex returns the index in the result file names of the client at index
+// i, unique across the agents of a run.
+func (r *Runner) fileIndex(i int) int {
+	return i*r.agents + r.agent
+}
+
+// Run executes the tests and reports on their results.
 func (r *Runner) Run(ctx context.Context) error {
-	r.logger.Info(ctx, "UUID: %s", r.testID)
-	duration := viper.GetInt("duration")
-	cooldown := viper.GetInt("cooldown")
-	rate := viper.GetString("rate")
-	rampType := viper.GetString("ramp-type")
-	startRate := viper.GetInt("start-rate")
-	endRate := viper.GetInt("end-rate")
-	rampSteps := viper.GetInt("ramp-steps")
-	rampDuration := viper.GetInt("ramp-duration")
-
-	tests_conf := viper.Sub("tests")
-	confHelper := config.NewConfigHelper(r.logger, tests_conf)
+	err := r.Execute(ctx)
+	if err != nil {
+		return err
+	}
+	return r.Report(ctx)
+}
 
-	var wg sync.WaitGroup
-	concurrentConnections := len(r.connections)
+// runSettings holds the global settings of the tests, resolved once per run.
+type runSettings struct {
+	duration           int
+	cooldown           int
+	rate               string
+	rampType           string
+	startRate          int
+	endRate            int
+	rampSteps          int
+	rampDuration       int
+	captureDefaults    results.CapturePolicy
+	attackerDefaults   attackerConfig
+	throttleDefaults   throttleConfig
+	operationIDHeaders []string
+
+	tests      *viper.Viper
+	confHelper *config.ConfigHelper
+}
+
+func (r *Runner) settings() runSettings {
+	s := runSettings{
+		duration:     viper.GetInt("duration"),
+		cooldown:     viper.GetInt("cooldown"),
+		rate:         viper.GetString("rate"),
+		rampType:     viper.GetString("ramp-type"),
+		startRate:    viper.GetInt("start-rate"),
+		endRate:      viper.GetInt("end-rate"),
+		rampSteps:    viper.GetInt("ramp-steps"),
+		rampDuration: viper.GetInt("ramp-duration"),
+		captureDefaults: results.CapturePolicy{
+			Mode:            results.CaptureMode(viper.GetString("body-capture")),
+			MaxBytes:        viper.GetInt("body-max-bytes"),
+			SamplePercent:   viper.GetInt("body-sample-percent"),
+			HeaderAllowList: viper.GetStringSlice("header-allow-list"),
+		},
+		attackerDefaults: attackerConfig{
+			Workers:    viper.GetInt("workers"),
+			MaxWorkers: viper.GetInt("max-workers"),
+			Timeout:    viper.GetDuration("timeout"),
+			Redirects:  viper.GetInt("redirects"),
+		},
+		throttleDefaults: throttleConfig{
+			Policy:  ThrottlePolicy(viper.GetString("throttle-policy")),
+			Backoff: viper.GetDuration("throttle-backoff"),
+		},
+		operationIDHeaders: viper.GetStringSlice("operation-id-headers"),
+		tests:              viper.Sub("tests"),
+	}
+	if len(s.operationIDHeaders) == 0 {
+		s.operationIDHeaders = ocm.DefaultOperationIDHeaders
+	}
+	s.confHelper = config.NewConfigHelper(r.logger, s.tests)
+	return s
+}
+
+// selected reports whether the test is set to run.
+func (s runSettings) selected(t types.TestOptions) bool {
+	return s.tests.InConfig(t.TestName) || s.tests.InConfig("all")
+}
+
+// noSegment is the segment of the result files of runs that are not rotated.
+const noSegment = -1
+
+// resultFileName returns the name of the result file of a test for the client
+// at index, with the segment of the soak runs that rotate their files.
+func (r *Runner) resultFileName(testName string, index, segment int) string {
+	if segment == noSegment {
+		return fmt.Sprintf("%s_%s_%d.json", r.testID, testName, r.fileIndex(index))
+	}
+	return fmt.Sprintf("%s_%s_%d.%d.json", r.testID, testName, r.fileIndex(index), segment)
+}
+
+// Execute runs the tests, writing their results, and those of the token
+// requests, to the output directory.
+func (r *Runner) Execute(ctx context.Context) error {
+	r.logger.Info(ctx, "UUID: %s", r.testID)
+	s := r.settings()
+	r.setRunHeaders(s)
+	testNames := []string{}
+	for _, t := range tests {
+		if s.selected(t) {
+			testNames = append(testNames, t.TestName)
+		}
+	}
+	state, err := r.openState(ctx, testNames)
+	if err != nil {
+		return err
+	}
 	for i, t := range tests {
 		// Check if the test is set to run
-		if !tests_conf.InConfig(t.TestName) && !tests_conf.InConfig("all") {
+		if !s.selected(t) {
 			continue
+		}
+		if r.completed(state, t.TestName) {
+			r.logger.Info(ctx, "Skipping test %s, completed by the interrupted run", t.TestName)
+			continue
+		}
+		r.startTest(ctx, state, t.TestName)
+		finished := r.runTest(ctx, s, t, noSegment)
+		// The token requests get a segment per test, the one of its index.
+		r.writeTokenResults(ctx, i)
+		if finished {
+			r.completeTest(ctx, state, t.TestName)
 		} else {
-			wg.Add(concurrentConnections)
+			r.logger.Warn(ctx, "Test %s did not finish, it runs again when the run is resumed", t.TestName)
 		}
 
-		for i, conn := range r.connections {
-			go func(ctx context.Context, concurrentConnections int, index int, conn *sdk.Connection, testOptions types.TestOptions) error {
-				// Create an Attacker for each individual test. This is due to the
-				// fact that vegeta (and compatible parsers, such as benchmark-wrapper)
-				// expect the sequence to start at 0 for each result file. (Possibly a bug?)
-				connAttacker := vegeta.Client(&http.Client{Transport: conn})
-				attacker := vegeta.NewAttacker(connAttacker)
-
-				// Open a file and create an encoder that will be used to store the
-				// results for each test.
-				fileName := fmt.Sprintf("%s_%s_%d.json", r.testID, testOptions.TestName, index)
-				resultsFile, err := helpers.CreateFile(fileName, r.outputDirectory)
-				if err != nil {
-					return err
-				}
-				encoder := vegeta.NewJSONEncoder(resultsFile)
-
-				// Bind "Test Harness"
-				testOptions.ID = r.testID
-				testOptions.Attacker = attacker
-				testOptions.Connection = conn
-				testOptions.Encoder = &encoder
-				testOptions.Logger = r.logger
-
-				// Create the vegeta rate with the config values
-				currentTestRate := confHelper.ResolveStringConfig(ctx, rate, fmt.Sprintf("%s.rate", testOptions.TestName))
-				rate, err := helpers.ParseRate(currentTestRate, concurrentConnections)
-				if err != nil {
-					r.logger.Warn(ctx,
-						"error parsing rate for test %s: %s. Using default",
-						testOptions.TestName,
-						currentTestRate)
+		if i < len(s.tests.AllSettings()) {
+			r.coolDown(ctx, s)
+		}
+	}
+	r.closeClients(ctx, len(tests))
+	return nil
+}
+
+// runTest runs a test on every client and waits for them to be over. It
+// reports whether every client finished the test before ctx was done.
+func (r *Runner) runTest(ctx context.Context, s runSettings, t types.TestOptions, segment int) bool {
+	var failed int32
+	var wg sync.WaitGroup
+	wg.Add(len(r.clients))
+	// The rates are divided among the connections of every agent
+	concurrentConnections := len(r.clients) * r.agents
+	for i, client := range r.clients {
+		go func(ctx context.Context, concurrentConnections int, index int, client *ocm.Client, testOptions types.TestOptions) {
+			defer wg.Done()
+			finished := false
+			defer func() {
+				if !finished {
+					atomic.StoreInt32(&failed, 1)
 				}
-				testOptions.Rate = rate
+			}()
+			conn := client.Connection
+			// The setup and cleanup requests of the handler are sent with the
+			// headers of the test too.
+			headers := testHeaders(testOptions.TestName)
+			ctx = ocm.WithTestHeaders(ctx, headers)
+			// Create an Attacker for each individual test. This is due to the
+			// fact that vegeta (and compatible parsers, such as benchmark-wrapper)
+			// expect the sequence to start at 0 for each result file. (Possibly a bug?)
+			throttle := buildThrottleConfig(ctx, s.confHelper, testOptions.TestName, s.throttleDefaults, r)
+			traces := results.NewErrorTraces()
+			transport, pace := throttle.wrap(&attackTransport{wrapped: client.Transport(), headers: headers, traces: traces})
+			connAttacker := vegeta.Client(&http.Client{Transport: transport})
+			attackerTuning := buildAttackerConfig(ctx, s.confHelper, testOptions.TestName, s.attackerDefaults, r)
+			attacker := vegeta.NewAttacker(append([]func(*vegeta.Attacker){connAttacker}, attackerTuning.options()...)...)
+
+			// Open a file and create an encoder that will be used to store the
+			// results for each test.
+			fileName := r.resultFileName(testOptions.TestName, index, segment)
+			resultsFile, err := helpers.CreateFile(fileName, r.outputDirectory)
+			if err != nil {
+				r.logger.Error(ctx, "creating result file of test %s: %v", testOptions.TestName, err)
+				return
+			}
+			capture := buildCapturePolicy(ctx, s.confHelper, testOptions.TestName, s.captureDefaults, r)
+			encoder := results.NewErrorTraceEncoder(results.NewCaptureEncoder(results.NewLabeledEncoder(resultsFile, client.Identity), capture), traces)
+
+			// Bind "Test Harness"
+			testOptions.ID = r.testID
+			testOptions.Attacker = attacker
+			testOptions.Throttle = pace
+			testOptions.Connection = conn
+			testOptions.Client = attackerTuning.client(transport)
+			testOptions.MaxWorkers = attackerTuning.MaxWorkers
+			testOptions.Encoder = &encoder
+			testOptions.Logger = r.logger
+
+			// Create the vegeta rate with the config values
+			currentTestRate := s.confHelper.ResolveStringConfig(ctx, s.rate, fmt.Sprintf("%s.rate", testOptions.TestName))
+			rate, err := helpers.ParseRate(currentTestRate, concurrentConnections)
+			if err != nil {
+				r.logger.Warn(ctx,
+					"error parsing rate for test %s: %s. Using default",
+					testOptions.TestName,
+					currentTestRate)
+			}
+			testOptions.Rate = rate
 
-				// Check for an override on the test duration
-				currentTestDuration := confHelper.ResolveIntConfig(ctx, duration, fmt.Sprintf("%s.duration", testOptions.TestName))
-				testOptions.Duration = time.Duration(currentTestDuration) * time.Minute
+			// Check for an override on the test duration
+			currentTestDuration := s.confHelper.ResolveIntConfig(ctx, s.duration, fmt.Sprintf("%s.duration", testOptions.TestName))
+			testOptions.Duration = time.Duration(currentTestDuration) * time.Minute
 
-				remainingDuration := 0
-				currentTestRamp := confHelper.ResolveStringConfig(ctx, rampType, fmt.Sprintf("%s.ramp-type", testOptions.TestName))
-				currentRampDuration, ramper := buildRamper(ctx, currentTestRamp, confHelper, startRate, testOptions, endRate, rampSteps, rampDuration, r)
+			remainingDuration := 0
+			currentTestRamp := s.confHelper.ResolveStringConfig(ctx, s.rampType, fmt.Sprintf("%s.ramp-type", testOptions.TestName))
+			currentRampDuration, ramper := buildRamper(ctx, currentTestRamp, s.confHelper, s.startRate, testOptions, s.endRate, s.rampSteps, s.rampDuration, r)
+
+			if ramper == nil {
+				r.recordProfile(testOptions.TestName, currentTestRate)
+				r.logger.Info(ctx, "Executing Test: %s", testOptions.TestName)
+				r.logger.Info(ctx, "Rate: %s", testOptions.Rate.String())
+				r.logger.Info(ctx, "Duration: %s", testOptions.Duration.String())
+				r.logger.Info(ctx, "Endpoint: %s", testOptions.Path)
+				err = testOptions.Handler(ctx, &testOptions)
+				if err != nil {
+					r.logger.Error(ctx, "running test %s: %v", testOptions.TestName, err)
+					resultsFile.Close()
+					return
+				}
+			} else {
+				r.recordProfile(testOptions.TestName, ramper.GetProfile())
+				r.logger.Info(ctx, "Executing Test: %s", testOptions.TestName)
+				r.logger.Info(ctx, "Ramp type: %s", ramper.GetType())
+				r.logger.Info(ctx, "Endpoint: %s", testOptions.Path)
+				if currentRampDuration == 0 {
+					duration := math.Round(testOptions.Duration.Minutes() / float64(ramper.GetSteps()))
+					testOptions.Duration = time.Duration(duration) * time.Minute
+				} else {
+					remainingDuration = int(testOptions.Duration.Minutes()) - currentRampDuration
+					duration := math.Round(float64(currentRampDuration) / float64(ramper.GetSteps()))
+					testOptions.Duration = time.Duration(duration) * time.Minute
+				}
 
-				if ramper == nil {
-					r.logger.Info(ctx, "Executing Test: %s", testOptions.TestName)
+				for i := 0; i < ramper.GetSteps(); i++ {
+					r.logger.Info(ctx, "Ramping up... step %v", i+1)
+					rateInt := ramper.NextRate()
+					newRate, _ := helpers.ParseRate(fmt.Sprint(rateInt), concurrentConnections)
+					testOptions.Rate = newRate
+					testOptions.RampStep = i
+					testOptions.RampSteps = ramper.GetSteps()
+					if i+1 == ramper.GetSteps() && remainingDuration > 0 {
+						testOptions.Duration = testOptions.Duration + (time.Duration(remainingDuration) * time.Minute)
+					}
 					r.logger.Info(ctx, "Rate: %s", testOptions.Rate.String())
 					r.logger.Info(ctx, "Duration: %s", testOptions.Duration.String())
-					r.logger.Info(ctx, "Endpoint: %s", testOptions.Path)
 					err = testOptions.Handler(ctx, &testOptions)
 					if err != nil {
-						return err
-					}
-				} else {
-					r.logger.Info(ctx, "Executing Test: %s", testOptions.TestName)
-					r.logger.Info(ctx, "Ramp type: %s", ramper.GetType())
-					r.logger.Info(ctx, "Endpoint: %s", testOptions.Path)
-					if currentRampDuration == 0 {
-						duration := math.Round(testOptions.Duration.Minutes() / float64(ramper.GetSteps()))
-						testOptions.Duration = time.Duration(duration) * time.Minute
-					} else {
-						remainingDuration = int(testOptions.Duration.Minutes()) - currentRampDuration
-						duration := math.Round(float64(currentRampDuration) / float64(ramper.GetSteps()))
-						testOptions.Duration = time.Duration(duration) * time.Minute
-					}
-
-					for i := 0; i < ramper.GetSteps(); i++ {
-						r.logger.Info(ctx, "Ramping up... step %v", i+1)
-						rateInt := ramper.NextRate()
-						newRate, _ := helpers.ParseRate(fmt.Sprint(rateInt), concurrentConnections)
-						testOptions.Rate = newRate
-						if i+1 == ramper.GetSteps() && remainingDuration > 0 {
-							testOptions.Duration = testOptions.Duration + (time.Duration(remainingDuration) * time.Minute)
-						}
-						r.logger.Info(ctx, "Rate: %s", testOptions.Rate.String())
-						r.logger.Info(ctx, "Duration: %s", testOptions.Duration.String())
-						err = testOptions.Handler(ctx, &testOptions)
-						if err != nil {
-							return err
-						}
+						r.logger.Error(ctx, "running test %s: %v", testOptions.TestName, err)
+						resultsFile.Close()
+						return
 					}
 				}
+			}
 
-				// Cleanup (cannot defer as it must happen for each test in the loop)
-				r.logger.Info(ctx, "Results written to: %s", fileName)
-				err = resultsFile.Close()
+			// Cleanup (cannot defer as it must happen for each test in the loop)
+			r.logger.Info(ctx, "Results written to: %s", fileName)
+			err = resultsFile.Close()
+			if err != nil {
+				r.logger.Error(ctx, "closing result file of test %s: %v", testOptions.TestName, err)
+				return
+			}
+
+			// Index result file
+			if viper.GetString("elastic.server") != "" {
+				indexer, err := elastic.NewESIndexer(ctx, r.logger)
 				if err != nil {
-					return err
+					r.logger.Error(ctx, "obtaining indexer: %s", err)
 				}
-
-				// Index result file
-				if viper.GetString("elastic.server") != "" {
-					indexer, err := elastic.NewESIndexer(ctx, r.logger)
-					if err != nil {
-						r.logger.Error(ctx, "obtaining indexer: %s", err)
-					}
-					serverVersion := helpers.GetServerVersion(ctx, conn)
-					r.logger.Info(ctx, "server version %s", serverVersion)
-					err = indexer.IndexFile(ctx, r.testID, serverVersion, resultsFile.Name(), r.logger)
-					if err != nil {
-						r.logger.Error(ctx, "Error during ES indexing: %s", err)
-					}
+				serverVersion := helpers.GetServerVersion(ctx, conn)
+				r.logger.Info(ctx, "server version %s", serverVersion)
+				err = indexer.IndexFile(ctx, r.testID, serverVersion, resultsFile.Name(), r.logger)
+				if err != nil {
+					r.logger.Error(ctx, "Error during ES indexing: %s", err)
 				}
-				wg.Done()
-				return nil
-			}(ctx, concurrentConnections, i, conn, t)
-		}
-		wg.Wait()
+			}
+			finished = true
+		}(ctx, concurrentConnections, i, client, t)
+	}
+	wg.Wait()
+	return atomic.LoadInt32(&failed) == 0 && ctx.Err() == nil
+}
+
+// coolDown waits for the cooldown of the run before the next test, or until
+// the context is done.
+func (r *Runner) coolDown(ctx context.Context, s runSettings) {
+	r.logger.Info(ctx, "Cooling down for next test for: %v s", s.cooldown)
+	select {
+	case <-time.After(time.Duration(s.cooldown) * time.Second):
+	case <-ctx.Done():
+	}
+}
 
-		if i < len(tests_conf.AllSettings()) {
-			r.logger.Info(ctx, "Cooling down for next test for: %v s", cooldown)
-			time.Sleep(time.Duration(cooldown) * time.Second)
+// closeClients closes the connection pools and writes the results of the
+// token requests left to the segment.
+func (r *Runner) closeClients(ctx context.Context, segment int) {
+	for _, client := range r.clients {
+		if client.Pool != nil {
+			r.logger.Info(ctx, "Pool %s opened %d connections for %d identities", client.Identity.Connection, client.Pool.Created(), client.Pool.Size())
+			client.Pool.Close()
 		}
 	}
+	r.writeTokenResults(ctx, segment)
+}
+
+// Report writes the summaries of the results found in the output directory,
+// and compares them with the baseline run when `baseline-lookup` is set.
+func (r *Runner) Report(ctx context.Context) error {
+	r.writeTokenSummary(ctx)
+	r.writeIdentitySummary(ctx)
+	slowestRequests := viper.GetInt("slowest-requests")
+	if slowestRequests == 0 {
+		slowestRequests = DefaultSlowestRequests
+	}
+	r.writeSlowest(ctx, slowestRequests)
+
+	if viper.GetString("elastic.server") != "" && viper.GetBool("baseline-lookup") {
+		return r.compareWithBaseline(ctx)
+	}
 	return nil
 }
 
@@ -197,3 +401,19 @@ func buildRamper(ctx context.Context, currentTestRamp string, confHelper *config
 	}
 	return currentRampDuration, ramper
 }
+
+// buildCapturePolicy resolves the response body capture settings for a test,
+// falling back to the global values.
+func buildCapturePolicy(ctx context.Context, confHelper *config.ConfigHelper, testName string, defaults results.CapturePolicy, r *Runner) results.CapturePolicy {
+	currentMode := confHelper.ResolveStringConfig(ctx, string(defaults.Mode), fmt.Sprintf("%s.body-capture", testName))
+	mode, err := results.ParseCaptureMode(currentMode)
+	if err != nil {
+		r.logger.Warn(ctx, "%s for test %s. Capturing only the bodies of failed requests.", err, testName)
+	}
+	return results.CapturePolicy{
+		Mode:            mode,
+		MaxBytes:        confHelper.ResolveIntConfig(ctx, defaults.MaxBytes, fmt.Sprintf("%s.body-max-bytes", testName)),
+		SamplePercent:   confHelper.ResolveIntConfig(ctx, defaults.SamplePercent, fmt.Sprintf("%s.body-sample-percent", testName)),
+		HeaderAllowList: confHelper.ResolveStringSliceConfig(ctx, defaults.HeaderAllowList, fmt.Sprintf("%s.header-allow-list", testName)),
+	}
+}
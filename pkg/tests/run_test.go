@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/config"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/ocm"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	"github.com/spf13/viper"
+)
+
+func TestRunner_runTest(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("tests", map[string]interface{}{"list-clusters": map[string]interface{}{}})
+	viper.Set("rate", "1/s")
+	viper.Set("duration", 1)
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	clients := []*ocm.Client{
+		{Identity: results.Identity{Connection: "auth-0"}},
+		{Identity: results.Identity{Connection: "auth-1", ConnectionIndex: 1}},
+	}
+	runner := NewRunner("run", t.TempDir(), logger, clients)
+	test := types.TestOptions{
+		TestName: "list-clusters",
+		Handler: func(ctx context.Context, options *types.TestOptions) error {
+			return fmt.Errorf("no cluster to list")
+		},
+	}
+
+	done := make(chan bool)
+	go func() {
+		done <- runner.runTest(context.TODO(), runner.settings(), test, noSegment)
+	}()
+	select {
+	case finished := <-done:
+		if finished {
+			t.Errorf("runTest() = true, want the test reported as not finished")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("runTest() did not return after the handlers failed")
+	}
+
+	test.Handler = func(ctx context.Context, options *types.TestOptions) error {
+		return nil
+	}
+	if !runner.runTest(context.TODO(), runner.settings(), test, noSegment) {
+		t.Errorf("runTest() = false, want the test finished by every client")
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if runner.runTest(ctx, runner.settings(), test, noSegment) {
+		t.Errorf("runTest() = true, want the test cancelled reported as not finished")
+	}
+}
+
+func Test_buildCapturePolicy(t *testing.T) {
+	conf := viper.New()
+	conf.Set("quiet-test", map[string]interface{}{"body-capture": "none"})
+	conf.Set("broken-test", map[string]interface{}{"body-capture": "everything"})
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	confHelper := config.NewConfigHelper(logger, conf)
+	r := &Runner{logger: logger}
+	defaults := results.CapturePolicy{Mode: results.CaptureAll}
+
+	tests := []struct {
+		name     string
+		testName string
+		want     results.CaptureMode
+	}{
+		{"defaults", "other-test", results.CaptureAll},
+		{"override", "quiet-test", results.CaptureNone},
+		{"invalid", "broken-test", results.CaptureErrors},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildCapturePolicy(context.TODO(), confHelper, tt.testName, defaults, r)
+			if got.Mode != tt.want {
+				t.Errorf("buildCapturePolicy() mode = %s, want %s", got.Mode, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunner_coolDown(t *testing.T) {
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	r := &Runner{logger: logger}
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	began := time.Now()
+	r.coolDown(ctx, runSettings{cooldown: 60})
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("coolDown() took %s with a cancelled context, want it to return at once", elapsed)
+	}
+}
@@ -0,0 +1,346 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+)
+
+const (
+	// DefaultSoakSummaryInterval is the time between two interim summaries of
+	// a soak run, when `soak-summary-interval` is not set.
+	DefaultSoakSummaryInterval = 15 * time.Minute
+
+	// DefaultSoakLedgerInterval is the time between two saves of the cleanup
+	// ledger to the checkpoint while a test of a soak run is running.
+	DefaultSoakLedgerInterval = 30 * time.Second
+)
+
+// SoakConfig configures a soak run, see Runner.Soak.
+type SoakConfig struct {
+	// Deadline is the time after which no test is started.
+	Deadline time.Time
+	// SummaryInterval is the time between two interim summaries.
+	SummaryInterval time.Duration
+	// LedgerInterval is the time between two saves of the cleanup ledger
+	// while a test is running, DefaultSoakLedgerInterval when not set.
+	LedgerInterval time.Duration
+}
+
+// ParseSoakDeadline parses the deadline of a soak run, given as a duration
+// from now, such as 48h, or as an RFC 3339 time.
+func ParseSoakDeadline(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("invalid soak deadline %q: the duration must be positive", value)
+		}
+		return now.Add(d), nil
+	}
+	deadline, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid soak deadline %q: expected a duration such as 48h or an RFC 3339 time", value)
+	}
+	return deadline, nil
+}
+
+// soakCheckpoint is the progress of a soak run, saved to
+// `<test-id>_checkpoint.json` so a restarted run with the same test ID
+// continues where it stopped.
+type soakCheckpoint struct {
+	TestID   string    `json:"test_id"`
+	Started  time.Time `json:"started"`
+	Deadline time.Time `json:"deadline"`
+	// Iterations is the number of loops over the tests.
+	Iterations int `json:"iterations"`
+	// Segments is the number of result file segments used so far.
+	Segments int `json:"segments"`
+	// Runs is the number of runs of each test.
+	Runs map[string]int `json:"runs"`
+	// Restarts is the number of times the run was resumed.
+	Restarts    int                   `json:"restarts"`
+	LastSummary time.Time             `json:"last_summary"`
+	Cleanup     helpers.CleanupLedger `json:"cleanup"`
+
+	path string
+	mu   sync.Mutex
+}
+
+func checkpointPath(outputDirectory, testID string) string {
+	return filepath.Join(outputDirectory, fmt.Sprintf("%s_checkpoint.json", testID))
+}
+
+// loadCheckpoint reads the checkpoint of a soak run, returning nil when the
+// run has none.
+func loadCheckpoint(outputDirectory, testID string) (*soakCheckpoint, error) {
+	path := checkpointPath(outputDirectory, testID)
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &soakCheckpoint{}
+	err = json.Unmarshal(b, checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", path, err)
+	}
+	if checkpoint.TestID != testID {
+		return nil, fmt.Errorf("checkpoint %s belongs to test ID %s", path, checkpoint.TestID)
+	}
+	if checkpoint.Runs == nil {
+		checkpoint.Runs = map[string]int{}
+	}
+	checkpoint.path = path
+	return checkpoint, nil
+}
+
+// update applies fn to the checkpoint and saves it, replacing the previous
+// file only once the new one is written.
+func (c *soakCheckpoint) update(fn func(c *soakCheckpoint)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(c)
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	err = os.WriteFile(tmp, b, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func (c *soakCheckpoint) soaking(ctx context.Context) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ctx.Err() == nil && time.Now().Before(c.Deadline)
+}
+
+// RunSoak executes a soak run and reports on its results.
+func (r *Runner) RunSoak(ctx context.Context, conf SoakConfig) error {
+	err := r.Soak(ctx, conf)
+	if err != nil {
+		return err
+	}
+	return r.Report(ctx)
+}
+
+// Soak runs the selected tests in a loop until the deadline. Every run of a
+// test writes its results to a new segment of the result files,
+// `<test-id>_<test-name>_<idx>.<segment>.json`, the resources created are
+// cleaned up after each loop, and the results of every summary interval are
+// appended to `<test-id>_soak_summary.txt`.
+//
+// The progress is saved to a checkpoint. When it exists, the run continues
+// with its deadline, counters and cleanup ledger instead of starting over.
+func (r *Runner) Soak(ctx context.Context, conf SoakConfig) error {
+	r.logger.Info(ctx, "UUID: %s", r.testID)
+	checkpoint, err := loadCheckpoint(r.outputDirectory, r.testID)
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		now := time.Now()
+		checkpoint = &soakCheckpoint{
+			TestID:      r.testID,
+			Started:     now,
+			Deadline:    conf.Deadline,
+			Runs:        map[string]int{},
+			LastSummary: now,
+			path:        checkpointPath(r.outputDirectory, r.testID),
+		}
+		r.logger.Info(ctx, "Soaking until %s", conf.Deadline.Format(time.RFC3339))
+	} else {
+		checkpoint.Restarts++
+		helpers.RestoreLedger(checkpoint.Cleanup)
+		r.logger.Info(ctx, "Resuming soak run after %d iterations, until %s", checkpoint.Iterations, checkpoint.Deadline.Format(time.RFC3339))
+	}
+	err = checkpoint.update(func(*soakCheckpoint) {})
+	if err != nil {
+		return fmt.Errorf("saving checkpoint: %v", err)
+	}
+
+	s := r.settings()
+	r.setRunHeaders(s)
+	selected := []types.TestOptions{}
+	for _, t := range tests {
+		if s.selected(t) {
+			selected = append(selected, t)
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no tests selected for the soak run")
+	}
+
+	interval := conf.SummaryInterval
+	if interval <= 0 {
+		interval = DefaultSoakSummaryInterval
+	}
+	ledgerInterval := conf.LedgerInterval
+	if ledgerInterval <= 0 {
+		ledgerInterval = DefaultSoakLedgerInterval
+	}
+	summaryCtx, stopSummaries := context.WithCancel(ctx)
+	summariesDone := make(chan struct{})
+	go func() {
+		defer close(summariesDone)
+		r.writeSoakSummaries(summaryCtx, checkpoint, interval)
+	}()
+
+	for checkpoint.soaking(ctx) {
+		for _, t := range selected {
+			if !checkpoint.soaking(ctx) {
+				break
+			}
+			segment := 0
+			r.saveCheckpoint(ctx, checkpoint, func(c *soakCheckpoint) {
+				segment = c.Segments
+				c.Segments++
+			})
+			r.runSoakTest(ctx, s, t, segment, checkpoint, ledgerInterval)
+			r.saveCheckpoint(ctx, checkpoint, func(c *soakCheckpoint) {
+				c.Runs[t.TestName]++
+				c.Cleanup = helpers.Ledger()
+			})
+
+			r.coolDown(ctx, s)
+		}
+		r.cleanup(ctx)
+		r.saveCheckpoint(ctx, checkpoint, func(c *soakCheckpoint) {
+			c.Iterations++
+			c.Cleanup = helpers.Ledger()
+		})
+		r.logger.Info(ctx, "Soak iteration %d over", checkpoint.Iterations)
+	}
+	stopSummaries()
+	<-summariesDone
+	r.writeSoakSummary(ctx, checkpoint, time.Now())
+
+	r.cleanup(ctx)
+	segment := 0
+	r.saveCheckpoint(ctx, checkpoint, func(c *soakCheckpoint) {
+		c.Cleanup = helpers.Ledger()
+		segment = c.Segments
+		c.Segments++
+	})
+	r.closeClients(ctx, segment)
+	return ctx.Err()
+}
+
+// runSoakTest runs a test, saving the cleanup ledger to the checkpoint every
+// interval while it runs, so a run killed in the middle of a test cleans up
+// the resources the test created once resumed. The token requests sent until
+// the end of the test are written to its segment.
+func (r *Runner) runSoakTest(ctx context.Context, s runSettings, t types.TestOptions, segment int, checkpoint *soakCheckpoint, interval time.Duration) {
+	done := make(chan struct{})
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.saveCheckpoint(ctx, checkpoint, func(c *soakCheckpoint) {
+					c.Cleanup = helpers.Ledger()
+				})
+			case <-done:
+				return
+			}
+		}
+	}()
+	r.runTest(ctx, s, t, segment)
+	close(done)
+	<-saved
+	r.writeTokenResults(ctx, segment)
+}
+
+// saveCheckpoint updates the checkpoint, logging the errors so a soak run is
+// not stopped by them.
+func (r *Runner) saveCheckpoint(ctx context.Context, checkpoint *soakCheckpoint, fn func(c *soakCheckpoint)) {
+	err := checkpoint.update(fn)
+	if err != nil {
+		r.logger.Error(ctx, "saving checkpoint: %v", err)
+	}
+}
+
+// cleanup deletes the resources created by the tests so far.
+func (r *Runner) cleanup(ctx context.Context) {
+	if len(r.clients) == 0 || helpers.Ledger().Empty() {
+		return
+	}
+	helpers.Cleanup(ctx, r.clients[0].Connection)
+}
+
+// writeSoakSummaries writes an interim summary every interval until ctx is
+// done.
+func (r *Runner) writeSoakSummaries(ctx context.Context, checkpoint *soakCheckpoint, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.writeSoakSummary(ctx, checkpoint, now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeSoakSummary appends the summary of the results sent since the last
+// interim summary to `<test-id>_soak_summary.txt`.
+func (r *Runner) writeSoakSummary(ctx context.Context, checkpoint *soakCheckpoint, now time.Time) {
+	checkpoint.mu.Lock()
+	from := checkpoint.LastSummary
+	checkpoint.mu.Unlock()
+
+	summaries, err := results.LoadWindow(r.outputDirectory, r.testID, from, now)
+	if err != nil {
+		r.logger.Warn(ctx, "summarizing soak results: %v", err)
+		return
+	}
+	r.saveCheckpoint(ctx, checkpoint, func(c *soakCheckpoint) {
+		c.LastSummary = now
+	})
+	if len(summaries) == 0 {
+		r.logger.Info(ctx, "No results since %s", from.Format(time.RFC3339))
+		return
+	}
+	keys := make([]string, 0, len(summaries))
+	for k := range summaries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sorted := make([]*results.Summary, 0, len(keys))
+	for _, k := range keys {
+		sorted = append(sorted, summaries[k])
+	}
+
+	name := filepath.Join(r.outputDirectory, fmt.Sprintf("%s_soak_summary.txt", r.testID))
+	summaryFile, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		r.logger.Error(ctx, "writing soak summary: %v", err)
+		return
+	}
+	defer summaryFile.Close()
+	fmt.Fprintf(summaryFile, "%s - %s\n", from.Format(time.RFC3339), now.Format(time.RFC3339))
+	err = results.WriteSummaries(summaryFile, sorted)
+	if err != nil {
+		r.logger.Error(ctx, "writing soak summary: %v", err)
+		return
+	}
+	fmt.Fprintln(summaryFile)
+	r.logger.Info(ctx, "Soak summary written to: %s", summaryFile.Name())
+}
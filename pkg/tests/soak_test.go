@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/ocm"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestParseSoakDeadline(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "48h", want: now.Add(48 * time.Hour)},
+		{value: "2022-06-03T12:00:00Z", want: now.Add(48 * time.Hour)},
+		{value: "-1h", wantErr: true},
+		{value: "tomorrow", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSoakDeadline(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSoakDeadline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseSoakDeadline() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunner_SoakResume(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("tests", map[string]interface{}{"list-clusters": map[string]interface{}{}})
+
+	dir := t.TempDir()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	started := time.Now().Add(-time.Hour)
+	deadline := time.Now().Add(-time.Minute)
+	checkpoint := &soakCheckpoint{
+		TestID:      "soak",
+		Started:     started,
+		Deadline:    deadline,
+		Iterations:  3,
+		Segments:    3,
+		Runs:        map[string]int{"list-clusters": 3},
+		LastSummary: started,
+		path:        checkpointPath(dir, "soak"),
+	}
+	if err := checkpoint.update(func(*soakCheckpoint) {}); err != nil {
+		t.Fatalf("saving checkpoint: %v", err)
+	}
+	runner := NewRunner("soak", dir, logger, nil)
+	res := []*vegeta.Result{{Attack: "list-clusters", Code: 200, Timestamp: time.Now().Add(-time.Minute), Latency: time.Second}}
+	if err := runner.writeResults(runner.resultFileName("list-clusters", 0, 2), results.Identity{}, res); err != nil {
+		t.Fatalf("writeResults() error = %v", err)
+	}
+
+	// The deadline of the checkpoint wins over the one of the restarted run.
+	err := runner.Soak(context.TODO(), SoakConfig{Deadline: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Soak() error = %v", err)
+	}
+
+	resumed, err := loadCheckpoint(dir, "soak")
+	if err != nil {
+		t.Fatalf("loadCheckpoint() error = %v", err)
+	}
+	if resumed.Restarts != 1 || resumed.Iterations != 3 || resumed.Runs["list-clusters"] != 3 || !resumed.Deadline.Equal(deadline) {
+		t.Errorf("checkpoint = %+v, want the saved one resumed once", resumed)
+	}
+	if !resumed.LastSummary.After(started) {
+		t.Errorf("LastSummary = %s, want the time of the final summary", resumed.LastSummary)
+	}
+	summary, err := os.ReadFile(filepath.Join(dir, "soak_soak_summary.txt"))
+	if err != nil {
+		t.Fatalf("reading soak summary: %v", err)
+	}
+	if !strings.Contains(string(summary), "list-clusters") {
+		t.Errorf("soak summary = %s, want the list-clusters results", summary)
+	}
+
+	if _, err := loadCheckpoint(dir, "other"); err != nil {
+		t.Errorf("loadCheckpoint() of a run without checkpoint error = %v", err)
+	}
+}
+
+func TestRunner_runSoakTest(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("tests", map[string]interface{}{"list-clusters": map[string]interface{}{}})
+	viper.Set("rate", "1/s")
+	viper.Set("duration", 1)
+
+	dir := t.TempDir()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	// The ledger saved by a previous run, with resources cleaned up since.
+	checkpoint := &soakCheckpoint{
+		TestID:  "soak",
+		Runs:    map[string]int{},
+		Cleanup: helpers.CleanupLedger{Services: []string{"svc-1"}},
+		path:    checkpointPath(dir, "soak"),
+	}
+	if err := checkpoint.update(func(*soakCheckpoint) {}); err != nil {
+		t.Fatalf("saving checkpoint: %v", err)
+	}
+	runner := NewRunner("soak", dir, logger, []*ocm.Client{{Identity: results.Identity{Connection: "auth-0"}}})
+	saved := false
+	test := types.TestOptions{
+		TestName: "list-clusters",
+		Handler: func(ctx context.Context, options *types.TestOptions) error {
+			timeout := time.After(5 * time.Second)
+			for {
+				checkpoint, err := loadCheckpoint(dir, "soak")
+				if err == nil && checkpoint.Cleanup.Empty() {
+					saved = true
+					return nil
+				}
+				select {
+				case <-timeout:
+					return nil
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		},
+	}
+
+	runner.runSoakTest(context.TODO(), runner.settings(), test, 0, checkpoint, 20*time.Millisecond)
+	if !saved {
+		t.Errorf("the cleanup ledger was not saved while the test was running")
+	}
+}
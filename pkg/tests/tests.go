@@ -29,7 +29,7 @@ var tests = []types.TestOptions{
 		TestName: "list-subscriptions",
 		Path:     "/api/accounts_mgmt/v1/subscriptions",
 		Method:   http.MethodGet,
-		Handler:  handlers.TestStaticEndpoint,
+		Handler:  handlers.TestListQuery,
 	},
 	{
 		TestName: "access-review",
@@ -60,7 +60,73 @@ var tests = []types.TestOptions{
 		TestName: "list-clusters",
 		Path:     "/api/clusters_mgmt/v1/clusters",
 		Method:   http.MethodGet,
-		Handler:  handlers.TestStaticEndpoint,
+		Handler:  handlers.TestListQuery,
+	},
+	{
+		TestName: "get-cluster",
+		Path:     "/api/clusters_mgmt/v1/clusters/{clusterId}",
+		Method:   http.MethodGet,
+		Handler:  handlers.TestGetCluster,
+	},
+	{
+		TestName: "cluster-lifecycle",
+		Path:     "/api/clusters_mgmt/v1/clusters",
+		Method:   http.MethodPost,
+		Handler:  handlers.TestClusterLifecycle,
+	},
+	{
+		TestName: "create-machine-pools",
+		Path:     "/api/clusters_mgmt/v1/clusters/{clusterId}/machine_pools",
+		Method:   http.MethodPost,
+		Handler:  handlers.TestCreateMachinePools,
+	},
+	{
+		TestName: "list-machine-pools",
+		Path:     "/api/clusters_mgmt/v1/clusters/{clusterId}/machine_pools",
+		Method:   http.MethodGet,
+		Handler:  handlers.TestListMachinePools,
+	},
+	{
+		TestName: "patch-machine-pools",
+		Path:     "/api/clusters_mgmt/v1/clusters/{clusterId}/machine_pools/{machinePoolId}",
+		Method:   http.MethodPatch,
+		Handler:  handlers.TestPatchMachinePools,
+	},
+	{
+		TestName: "delete-machine-pools",
+		Path:     "/api/clusters_mgmt/v1/clusters/{clusterId}/machine_pools/{machinePoolId}",
+		Method:   http.MethodDelete,
+		Handler:  handlers.TestDeleteMachinePools,
+	},
+	{
+		TestName: "create-addons",
+		Path:     "/api/clusters_mgmt/v1/clusters/{clusterId}/addons",
+		Method:   http.MethodPost,
+		Handler:  handlers.TestCreateAddOns,
+	},
+	{
+		TestName: "create-service-logs",
+		Path:     "/api/service_logs/v1/cluster_logs",
+		Method:   http.MethodPost,
+		Handler:  handlers.TestCreateServiceLogs,
+	},
+	{
+		TestName: "list-service-logs",
+		Path:     "/api/service_logs/v1/cluster_logs",
+		Method:   http.MethodGet,
+		Handler:  handlers.TestListServiceLogs,
+	},
+	{
+		TestName: "walk-subscriptions",
+		Path:     "/api/accounts_mgmt/v1/subscriptions",
+		Method:   http.MethodGet,
+		Handler:  handlers.TestPaginationWalk,
+	},
+	{
+		TestName: "walk-clusters",
+		Path:     "/api/clusters_mgmt/v1/clusters",
+		Method:   http.MethodGet,
+		Handler:  handlers.TestPaginationWalk,
 	},
 	{
 		TestName: "get-current-account",
@@ -74,6 +140,42 @@ var tests = []types.TestOptions{
 		Method:   http.MethodGet,
 		Handler:  handlers.TestQuotaCost,
 	},
+	{
+		TestName: "get-organization",
+		Path:     "/api/accounts_mgmt/v1/organizations/{orgId}",
+		Method:   http.MethodGet,
+		Handler:  handlers.TestOrganizationEndpoint,
+	},
+	{
+		TestName: "list-resource-quota",
+		Path:     "/api/accounts_mgmt/v1/organizations/{orgId}/resource_quota",
+		Method:   http.MethodGet,
+		Handler:  handlers.TestOrganizationEndpoint,
+	},
+	{
+		TestName: "create-role-bindings",
+		Path:     "/api/accounts_mgmt/v1/role_bindings",
+		Method:   http.MethodPost,
+		Handler:  handlers.TestCreateRoleBindings,
+	},
+	{
+		TestName: "list-role-bindings",
+		Path:     "/api/accounts_mgmt/v1/role_bindings",
+		Method:   http.MethodGet,
+		Handler:  handlers.TestListQuery,
+	},
+	{
+		TestName: "delete-role-bindings",
+		Path:     "/api/accounts_mgmt/v1/role_bindings/{roleBindingId}",
+		Method:   http.MethodDelete,
+		Handler:  handlers.TestDeleteRoleBindings,
+	},
+	{
+		TestName: "quota-authorizations",
+		Path:     "/api/accounts_mgmt/v1/quota_authorizations",
+		Method:   http.MethodPost,
+		Handler:  handlers.TestQuotaAuthorizations,
+	},
 	{
 		TestName: "resource-review",
 		Path:     "/api/authorizations/v1/resource_review",
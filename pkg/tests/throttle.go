@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/config"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// ThrottlePolicy defines what a test does when the gateway throttles it.
+type ThrottlePolicy string
+
+const (
+	// ThrottleContinue keeps sending requests at the rate of the test, to
+	// load the rate limiter itself.
+	ThrottleContinue ThrottlePolicy = "continue"
+	// ThrottleBackoff pauses the attacks of the test for the time asked by
+	// the gateway, so the test runs at the rate the gateway allows.
+	ThrottleBackoff ThrottlePolicy = "backoff"
+
+	// DefaultThrottleBackoff is the wait after a throttled request without
+	// Retry-After or rate limit headers.
+	DefaultThrottleBackoff = time.Second
+)
+
+// throttleConfig holds the throttle policy of a test.
+type throttleConfig struct {
+	Policy ThrottlePolicy
+	// Backoff is the wait when the gateway does not say how long to wait.
+	Backoff time.Duration
+}
+
+// buildThrottleConfig resolves the throttle policy of a test, falling back to
+// the global values.
+func buildThrottleConfig(ctx context.Context, confHelper *config.ConfigHelper, testName string, defaults throttleConfig, r *Runner) throttleConfig {
+	throttle := throttleConfig{Policy: ThrottleContinue, Backoff: defaults.Backoff}
+	policy := ThrottlePolicy(confHelper.ResolveStringConfig(ctx, string(defaults.Policy), fmt.Sprintf("%s.throttle-policy", testName)))
+	switch policy {
+	case "", ThrottleContinue:
+	case ThrottleBackoff:
+		throttle.Policy = policy
+	default:
+		r.logger.Warn(ctx, "unknown throttle policy %q for test %s (continue, backoff). Using continue", policy, testName)
+	}
+	currentBackoff := confHelper.ResolveStringConfig(ctx, "", fmt.Sprintf("%s.throttle-backoff", testName))
+	if currentBackoff != "" {
+		d, err := time.ParseDuration(currentBackoff)
+		if err != nil {
+			r.logger.Warn(ctx, "error parsing throttle backoff for test %s: %s. Using default", testName, err)
+		} else {
+			throttle.Backoff = d
+		}
+	}
+	if throttle.Backoff <= 0 {
+		throttle.Backoff = DefaultThrottleBackoff
+	}
+	return throttle
+}
+
+// wrap returns the transport of a test applying the policy, and the pacer of
+// its attacks, nil when they keep the rate of the test, see
+// types.TestOptions.Pacer.
+func (c throttleConfig) wrap(wrapped http.RoundTripper) (http.RoundTripper, func(vegeta.Rate, time.Duration) vegeta.Pacer) {
+	if c.Policy != ThrottleBackoff {
+		return wrapped, nil
+	}
+	b := &backoff{wrapped: wrapped, backoff: c.Backoff}
+	return b, b.pacer
+}
+
+// backoff paces the attacks of a test at the rate the gateway allows. Once a
+// request is throttled, the attack sends no request until the wait asked by
+// the Retry-After or rate limit headers of the response is over, then resumes
+// at the rate of the test without catching up on the requests it skipped.
+// Vegeta waits for the pacer before a request, so the request due within one
+// interval of the rate after the throttled response is still sent. The pause
+// is added to the response headers of the first request sent after it, see
+// results.BackoffHeader.
+type backoff struct {
+	wrapped http.RoundTripper
+	backoff time.Duration
+
+	mu    sync.Mutex
+	until time.Time
+	// paused is the pause not reported yet.
+	paused time.Duration
+}
+
+func (b *backoff) RoundTrip(request *http.Request) (*http.Response, error) {
+	var paused time.Duration
+	b.mu.Lock()
+	if !time.Now().Before(b.until) {
+		paused = b.paused
+		b.paused = 0
+	}
+	b.mu.Unlock()
+
+	response, err := b.wrapped.RoundTrip(request)
+	if err != nil {
+		return response, err
+	}
+	if paused > 0 {
+		response.Header.Set(results.BackoffHeader, paused.String())
+	}
+	if response.StatusCode == http.StatusTooManyRequests {
+		now := time.Now()
+		d, ok := results.RetryAfter(response.Header, now)
+		if !ok {
+			d = b.backoff
+		}
+		b.mu.Lock()
+		if until := now.Add(d); until.After(b.until) {
+			b.until = until
+		}
+		b.mu.Unlock()
+	}
+	return response, nil
+}
+
+// pacer returns the pacer of an attack at rate for duration, 0 for no limit.
+func (b *backoff) pacer(rate vegeta.Rate, duration time.Duration) vegeta.Pacer {
+	return &backoffPacer{backoff: b, rate: rate, duration: duration}
+}
+
+// backoffPacer paces an attack at its rate, leaving out of the elapsed time of
+// the attack the pauses asked by the gateway.
+type backoffPacer struct {
+	backoff  *backoff
+	rate     vegeta.Rate
+	duration time.Duration
+
+	// until is the end of the last pause of the attack and paused the time
+	// spent in pauses.
+	until  time.Time
+	paused time.Duration
+}
+
+func (p *backoffPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	now := time.Now()
+	p.backoff.mu.Lock()
+	until := p.backoff.until
+	if until.After(now) && until.After(p.until) {
+		start := now
+		if p.until.After(now) {
+			start = p.until
+		}
+		p.paused += until.Sub(start)
+		p.backoff.paused += until.Sub(start)
+		p.until = until
+	}
+	p.backoff.mu.Unlock()
+
+	if wait := until.Sub(now); wait > 0 {
+		// No request can be sent before the end of the attack.
+		if p.duration > 0 && elapsed+wait > p.duration {
+			return 0, true
+		}
+		return wait, false
+	}
+	return p.rate.Pace(elapsed-p.paused, hits)
+}
+
+func (p *backoffPacer) Rate(elapsed time.Duration) float64 {
+	if time.Now().Before(p.until) {
+		return 0
+	}
+	return p.rate.Rate(elapsed - p.paused)
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/config"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/types"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func Test_buildThrottleConfig(t *testing.T) {
+	conf := viper.New()
+	conf.Set("limiter-test", map[string]interface{}{"throttle-policy": "continue"})
+	conf.Set("polite-test", map[string]interface{}{"throttle-policy": "backoff", "throttle-backoff": "5s"})
+	conf.Set("broken-test", map[string]interface{}{"throttle-policy": "retry", "throttle-backoff": "later"})
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	confHelper := config.NewConfigHelper(logger, conf)
+	r := &Runner{logger: logger}
+	defaults := throttleConfig{Policy: ThrottleBackoff}
+
+	tests := []struct {
+		name     string
+		testName string
+		want     throttleConfig
+	}{
+		{"defaults", "other-test", throttleConfig{Policy: ThrottleBackoff, Backoff: DefaultThrottleBackoff}},
+		{"continue", "limiter-test", throttleConfig{Policy: ThrottleContinue, Backoff: DefaultThrottleBackoff}},
+		{"overrides", "polite-test", throttleConfig{Policy: ThrottleBackoff, Backoff: 5 * time.Second}},
+		{"invalid", "broken-test", throttleConfig{Policy: ThrottleContinue, Backoff: DefaultThrottleBackoff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildThrottleConfig(context.TODO(), confHelper, tt.testName, defaults, r)
+			if got != tt.want {
+				t.Errorf("buildThrottleConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_backoff(t *testing.T) {
+	var mu sync.Mutex
+	throttled := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if throttled {
+			throttled = false
+			w.Header().Set("Retry-After", "0.5")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport, pace := throttleConfig{Policy: ThrottleBackoff, Backoff: time.Minute}.wrap(http.DefaultTransport)
+	options := types.TestOptions{
+		Rate:     vegeta.Rate{Freq: 20, Per: time.Second},
+		Duration: time.Second,
+		Throttle: pace,
+		Attacker: vegeta.NewAttacker(vegeta.Client(&http.Client{Transport: transport})),
+	}
+	targeter := vegeta.NewStaticTargeter(vegeta.Target{Method: http.MethodGet, URL: server.URL})
+	var hits []*vegeta.Result
+	for res := range options.Attacker.Attack(targeter, options.Pacer(), options.Duration, "list-clusters") {
+		hits = append(hits, res)
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Seq < hits[j].Seq })
+
+	if len(hits) < 2 || hits[0].Code != http.StatusTooManyRequests {
+		t.Fatalf("%d requests sent, want a throttled request followed by others", len(hits))
+	}
+	// The attack pauses for the Retry-After, then resumes at its rate without
+	// catching up on the requests it skipped.
+	if len(hits) > 13 {
+		t.Errorf("%d requests sent, want at most 13 once paused for half of the attack", len(hits))
+	}
+	// Requests due within an interval of the rate after the throttled
+	// response are sent, the next ones only after the pause.
+	throttledAt := hits[0].Timestamp.Add(hits[0].Latency)
+	var resumed *vegeta.Result
+	for _, res := range hits[1:] {
+		if res.Headers.Get(results.BackoffHeader) != "" {
+			if resumed != nil {
+				t.Errorf("request %d reports a pause already reported by request %d", res.Seq, resumed.Seq)
+			}
+			resumed = res
+			continue
+		}
+		if res.Timestamp.After(throttledAt.Add(60*time.Millisecond)) && res.Timestamp.Before(throttledAt.Add(450*time.Millisecond)) {
+			t.Errorf("request %d sent %s after the throttled response, want the Retry-After pause", res.Seq, res.Timestamp.Sub(throttledAt))
+		}
+	}
+	if resumed == nil {
+		t.Fatalf("no request reports the pause")
+	}
+	paused, err := time.ParseDuration(resumed.Headers.Get(results.BackoffHeader))
+	if err != nil || paused < 400*time.Millisecond || paused > 600*time.Millisecond {
+		t.Errorf("resumed request backoff = %q, want the Retry-After pause", resumed.Headers.Get(results.BackoffHeader))
+	}
+	if resumed.Timestamp.Before(throttledAt.Add(450 * time.Millisecond)) {
+		t.Errorf("resumed request sent %s after the throttled response, want the Retry-After pause", resumed.Timestamp.Sub(throttledAt))
+	}
+	if resumed.Latency > 400*time.Millisecond {
+		t.Errorf("resumed request latency = %s, want the pause left out", resumed.Latency)
+	}
+
+	transport, pace = throttleConfig{Policy: ThrottleContinue}.wrap(http.DefaultTransport)
+	if transport != http.DefaultTransport || pace != nil {
+		t.Errorf("the continue policy should neither wrap the transport nor pace the attacks")
+	}
+}
+
+func Test_backoffPacer(t *testing.T) {
+	b := &backoff{}
+	rate := vegeta.Rate{Freq: 10, Per: time.Second}
+	pacer := b.pacer(rate, 10*time.Second)
+
+	wantWait, _ := rate.Pace(time.Second, 5)
+	if wait, stop := pacer.Pace(time.Second, 5); wait != wantWait || stop {
+		t.Errorf("Pace() = %s, %v, want the rate of the attack", wait, stop)
+	}
+	b.until = time.Now().Add(2 * time.Second)
+	if wait, stop := pacer.Pace(time.Second, 11); wait < time.Second || stop {
+		t.Errorf("Pace() = %s, %v, want a pause until the wait asked by the gateway", wait, stop)
+	}
+	// Once resumed, the requests of the pause are not caught up on.
+	b.until = time.Now()
+	if wait, stop := pacer.Pace(3*time.Second, 11); wait < 50*time.Millisecond || stop {
+		t.Errorf("Pace() = %s, %v, want the rate without the pause", wait, stop)
+	}
+	// A pause past the end of the attack stops it.
+	b.until = time.Now().Add(time.Minute)
+	if _, stop := pacer.Pace(3*time.Second, 12); !stop {
+		t.Errorf("Pace() should stop the attack paused past its end")
+	}
+}
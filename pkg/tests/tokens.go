@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/elastic"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/ocm"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/spf13/viper"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// writeTokenResults writes the token requests of each connection sent since
+// the last call to their own result file, `<test-id>_sso-token_<idx>.<segment>.json`,
+// and indexes them like the results of a test. They are written after every
+// test, so they are not held in memory for the whole run.
+func (r *Runner) writeTokenResults(ctx context.Context, segment int) {
+	for i, client := range r.clients {
+		if client.Tokens == nil {
+			continue
+		}
+		tokenResults := client.Tokens.Drain()
+		if len(tokenResults) == 0 {
+			continue
+		}
+		fileName := r.resultFileName(ocm.TokenAttack, i, segment)
+		err := r.writeResults(fileName, client.Identity, tokenResults)
+		if err != nil {
+			r.logger.Error(ctx, "writing token results: %v", err)
+			continue
+		}
+		if viper.GetString("elastic.server") != "" {
+			indexer, err := elastic.NewESIndexer(ctx, r.logger)
+			if err != nil {
+				r.logger.Error(ctx, "obtaining indexer: %s", err)
+				continue
+			}
+			serverVersion := helpers.GetServerVersion(ctx, client.Connection)
+			err = indexer.IndexFile(ctx, r.testID, serverVersion, filepath.Join(r.outputDirectory, fileName), r.logger)
+			if err != nil {
+				r.logger.Error(ctx, "Error during ES indexing: %s", err)
+			}
+		}
+	}
+}
+
+// writeTokenSummary writes a summary of the token requests of every token
+// result file to `<test-id>_sso-token_summary.txt`.
+func (r *Runner) writeTokenSummary(ctx context.Context) {
+	files, err := results.TestResultFiles(r.outputDirectory, r.testID, ocm.TokenAttack)
+	if err != nil {
+		r.logger.Warn(ctx, "summarizing token requests: %v", err)
+		return
+	}
+	collector := results.NewCollector()
+	for _, f := range files {
+		err := collector.DecodeFile(f)
+		if err != nil {
+			r.logger.Warn(ctx, "summarizing token requests: %v", err)
+			return
+		}
+	}
+	summary, ok := collector.Summaries()[ocm.TokenAttack]
+	if !ok {
+		r.logger.Info(ctx, "No token requests were sent during the run")
+		return
+	}
+	r.logger.Info(ctx, "Token requests: %d, errors: %.2f%%, mean: %s, p99: %s, max: %s",
+		summary.Requests, summary.ErrorRatio*100, summary.Mean, summary.P99, summary.Max)
+	summaryFile, err := helpers.CreateFile(fmt.Sprintf("%s_%s_summary.txt", r.testID, ocm.TokenAttack), r.outputDirectory)
+	if err != nil {
+		r.logger.Error(ctx, "writing token summary: %v", err)
+		return
+	}
+	defer summaryFile.Close()
+	err = results.WriteSummaries(summaryFile, []*results.Summary{summary})
+	if err != nil {
+		r.logger.Error(ctx, "writing token summary: %v", err)
+		return
+	}
+	r.logger.Info(ctx, "Token summary written to: %s", summaryFile.Name())
+}
+
+// writeResults encodes the results sent by the identity into a file of the
+// output directory. Token requests have no bodies, but errors are still
+// redacted.
+func (r *Runner) writeResults(fileName string, identity results.Identity, res []*vegeta.Result) error {
+	file, err := helpers.CreateFile(fileName, r.outputDirectory)
+	if err != nil {
+		return err
+	}
+	encoder := results.NewCaptureEncoder(results.NewLabeledEncoder(file, identity), results.CapturePolicy{Mode: results.CaptureNone})
+	for _, result := range res {
+		if err := encoder.Encode(result); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/ocm"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+	"github.com/spf13/viper"
+)
+
+func TestRunner_writeTokenResults(t *testing.T) {
+	viper.Reset()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"secret"}`))
+	}))
+	defer server.Close()
+
+	recorder := ocm.NewTokenRecorder(server.URL + "/token")
+	client := &http.Client{Transport: recorder.Wrap(http.DefaultTransport)}
+	for i := 0; i < 3; i++ {
+		response, err := client.Post(server.URL+"/token", "application/x-www-form-urlencoded", strings.NewReader("grant_type=refresh_token"))
+		if err != nil {
+			t.Fatalf("requesting token: %v", err)
+		}
+		response.Body.Close()
+	}
+
+	dir := t.TempDir()
+	logger, _ := logging.NewGoLoggerBuilder().Build()
+	runner := NewRunner("run", dir, logger, []*ocm.Client{
+		{Identity: results.Identity{Connection: "auth-0"}, Tokens: ocm.NewTokenRecorder("")},
+		{Identity: results.Identity{Connection: "auth-1", ConnectionIndex: 1}, Tokens: recorder},
+	})
+	runner.writeTokenResults(context.TODO(), 0)
+	// The requests written are not written again with the next test.
+	runner.writeTokenResults(context.TODO(), 1)
+	runner.writeTokenSummary(context.TODO())
+
+	summaries, err := results.LoadDir(dir, "run")
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	summary, ok := summaries[ocm.TokenAttack]
+	if !ok || summary.Requests != 3 {
+		t.Errorf("token summary = %+v, want 3 requests", summary)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "run_sso-token_1.0.json")); err != nil {
+		t.Errorf("token results of the second connection not written: %v", err)
+	}
+	for _, name := range []string{"run_sso-token_0.0.json", "run_sso-token_1.1.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s written without token requests", name)
+		}
+	}
+	report, err := os.ReadFile(filepath.Join(dir, "run_sso-token_summary.txt"))
+	if err != nil {
+		t.Fatalf("reading token summary: %v", err)
+	}
+	if !strings.Contains(string(report), ocm.TokenAttack) {
+		t.Errorf("token summary = %s", report)
+	}
+}
@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/helpers"
+	"github.com/cloud-bulldozer/ocm-api-load/pkg/results"
+)
+
+// DefaultSlowestRequests is the number of slowest requests per test listed
+// when `slowest-requests` is not set.
+const DefaultSlowestRequests = 10
+
+// writeSlowest writes the n slowest requests of every test, with their trace
+// and operation IDs, to `<test-id>_slowest.txt`.
+func (r *Runner) writeSlowest(ctx context.Context, n int) {
+	slowest, err := results.SlowestRequests(r.outputDirectory, r.testID, n)
+	if err != nil {
+		r.logger.Warn(ctx, "looking up the slowest requests: %v", err)
+		return
+	}
+	slowestFile, err := helpers.CreateFile(fmt.Sprintf("%s_slowest.txt", r.testID), r.outputDirectory)
+	if err != nil {
+		r.logger.Error(ctx, "writing slowest requests: %v", err)
+		return
+	}
+	defer slowestFile.Close()
+	err = results.WriteSlowest(slowestFile, slowest)
+	if err != nil {
+		r.logger.Error(ctx, "writing slowest requests: %v", err)
+		return
+	}
+	r.logger.Info(ctx, "Slowest requests written to: %s", slowestFile.Name())
+}
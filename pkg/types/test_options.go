@@ -2,6 +2,7 @@ package types
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	"github.com/cloud-bulldozer/ocm-api-load/pkg/logging"
@@ -23,11 +24,33 @@ type TestOptions struct {
 	Rate     vegeta.Rate
 	Duration time.Duration
 
+	// Ramping, the handler is called once per step of the ramp
+	RampStep  int // zero based index of the current step
+	RampSteps int // number of steps, 0 when the test is not ramped
+
 	// Test "Infrastructure"
 	ID         string                                          // Unique UUID of a given test-suite execution.
 	Handler    func(context.Context, *TestOptions) (err error) // Function which tests the given endpoint
 	Attacker   *vegeta.Attacker
+	Throttle   func(vegeta.Rate, time.Duration) vegeta.Pacer // Paces the attacks when throttled, nil to keep the Rate
 	Connection *sdk.Connection
+	Client     *http.Client    // Sends the requests of the handlers not using the Attacker, as the Attacker does
+	MaxWorkers int             // Concurrent iterations of such handlers, no limit when 0
 	Encoder    *vegeta.Encoder // Encodes results and writes them to a File
 	Logger     logging.Logger
 }
+
+// LastStep tells if the handler is called for the last time in the test,
+// so shared resources kept across the ramp steps can be released.
+func (o *TestOptions) LastStep() bool {
+	return o.RampStep+1 >= o.RampSteps
+}
+
+// Pacer returns the pacer of the attacks of the test: the Rate, paced by the
+// throttle policy of the test when it has one.
+func (o *TestOptions) Pacer() vegeta.Pacer {
+	if o.Throttle == nil {
+		return o.Rate
+	}
+	return o.Throttle(o.Rate, o.Duration)
+}
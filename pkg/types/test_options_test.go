@@ -0,0 +1,25 @@
+package types
+
+import "testing"
+
+func TestTestOptions_LastStep(t *testing.T) {
+	tests := []struct {
+		name      string
+		rampStep  int
+		rampSteps int
+		want      bool
+	}{
+		{"not ramped", 0, 0, true},
+		{"first step", 0, 3, false},
+		{"middle step", 1, 3, false},
+		{"last step", 2, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &TestOptions{RampStep: tt.rampStep, RampSteps: tt.rampSteps}
+			if got := options.LastStep(); got != tt.want {
+				t.Errorf("LastStep() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}